@@ -1,35 +1,91 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"math"
+	"math/rand"
 	"os"
 	"sort"
+	"time"
 
+	"github.com/octo/portfolio-mcmc/chart"
+	"github.com/octo/portfolio-mcmc/output"
 	"github.com/octo/portfolio-mcmc/portfolio"
 	"github.com/octo/portfolio-mcmc/timeseries"
 )
 
 var (
-	input = flag.String("input", "history.csv", "file containing historic returns")
+	input      = flag.String("input", "history.csv", "file containing historic returns")
+	validate   = flag.Bool("validate", false, "validate the input file before simulating")
+	holding    = flag.Int("holding-period", 0, "also evaluate every holding period of this length [months]")
+	calendar   = flag.Bool("calendar", false, "also show calendar-year and monthly returns of the portfolio and each position")
+	chartFile  = flag.String("chart", "", "write a chart to this file; the format is determined by the extension (.svg or .png)")
+	chartType  = flag.String("chart-type", "equity", `type of chart, one of "equity", "drawdown" and "histogram"`)
+	format     = flag.String("format", output.Text, `output format, one of "text", "json", "csv" and "ndjson"`)
+	taxModel   = flag.Bool("tax", false, "apply the German tax model and report after-tax returns and wealth")
+	allowance  = flag.Float64("tax-allowance", portfolio.DefaultTax.Allowance, "annual tax allowance (Sparerpauschbetrag)")
+	baseRate   = flag.Float64("tax-base-rate", portfolio.DefaultTax.BaseRate, "base rate for the Vorabpauschale [%]")
+	fundType   = flag.String("fund-type", portfolio.Equity, "fund type of positions without one; determines the partial tax exemption")
+	cashRate   = flag.Float64("cash-rate", 0, `annual interest rate of the "CASH" position [%]`)
+	cashRates  = flag.String("cash-series", "", `series of monthly returns used as the interest rate of the "CASH" position`)
+	spread     = flag.Float64("borrow-spread", 0, `annual interest paid on top of the cash rate if "CASH" is negative [%]`)
+	margin     = flag.Float64("maintenance", 0, "sell assets when the portfolio's value falls below this share of its assets [%]; 0 disables margin calls")
+	age        = flag.Float64("age", 0, "age at the start of the simulation, for -glide steps given by age")
+	confidence = flag.Float64("confidence", 95, "confidence level of the Sharpe ratio's interval and of the tests against the benchmark [%]")
+	bootstrap  = flag.Int("bootstrap", 1000, "number of resamples for the bootstrap test of the Sharpe ratio against the benchmark; 0 disables the test")
 
-	pf = portfolio.Portfolio{}
+	pf      = portfolio.Portfolio{}
+	bench   = portfolio.Portfolio{}
+	glide   portfolio.GlidePath
+	rules   portfolio.Strategy
+	derived timeseries.Derivations
 )
 
 func main() {
-	flag.Func("pos", `position as "name:weight"`, pf.FlagFunc())
+	flag.Func("pos", `position as "name:weight[:ter]"; ter is the annual expense ratio [%]`, pf.FlagFunc())
+	flag.Func("portfolio", `load the portfolio from a definition file, "file.json[:name]"`, pf.FileFlagFunc())
+	flag.Func("benchmark", `benchmark position as "name:weight[:ter]"`, bench.FlagFunc())
+	flag.Func("glide", `glide path step "<age or YYYY-MM>=name:weight[,name:weight]..."; the weights are interpolated between steps`, glide.FlagFunc())
+	flag.Func("strategy", `adjust the weights with a rule, e.g. "trend(10, CASH)", "momentum(12, CASH, WORLD, EMERGING MARKETS)" or "voltarget(12, 6, CASH)"`, func(flagValue string) (err error) {
+		rules, err = portfolio.ParseStrategy(flagValue)
+		return err
+	})
+	flag.Func("derive", `add a derived series "name=func(args)", e.g. "2X WORLD=leverage(WORLD, 2, 0.6)"`, derived.FlagFunc())
 	flag.Parse()
+	rand.Seed(time.Now().UnixNano())
 
-	f, err := os.Open(*input)
-	if err != nil {
-		log.Fatalf("os.Open(%q): %v", *input, err)
+	if err := output.CheckFormat(*format); err != nil {
+		log.Fatal(err)
+	}
+	if *confidence <= 0 || *confidence >= 100 {
+		log.Fatalf("-confidence: got %g, want a value between 0 and 100", *confidence)
+	}
+	if err := portfolio.CheckFundType(*fundType); err != nil {
+		log.Fatalf("-fund-type: %v", err)
+	}
+	applyTax(&pf)
+	applyTax(&bench)
+	applyCash(&pf)
+	applyCash(&bench)
+	if err := applyStrategy(&pf); err != nil {
+		log.Fatal(err)
 	}
-	defer f.Close()
 
-	hist, err := timeseries.Load(f)
+	hist, err := timeseries.LoadFile(*input, *validate)
 	if err != nil {
-		log.Fatalf("timeseries.Load(): %v", err)
+		log.Fatal(err)
+	}
+	if err := derived.Apply(hist); err != nil {
+		log.Fatalf("-derive: %v", err)
+	}
+
+	for _, p := range []portfolio.Portfolio{pf, bench} {
+		if err := p.Validate(hist); err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	if len(pf.Positions) == 0 {
@@ -39,7 +95,7 @@ func main() {
 		}
 		sort.Strings(names)
 
-		fmt.Println("ERROR: specify one or more -pos arguments.")
+		fmt.Println("ERROR: specify -portfolio or one or more -pos arguments.")
 		fmt.Println()
 		fmt.Println("Available time series:")
 		fmt.Println()
@@ -50,14 +106,359 @@ func main() {
 		return
 	}
 
-	res, err := pf.Eval(&timeseries.Backtest{
+	hist, err = timeseries.Align(hist, portfolio.Names(pf, bench)...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ev, err := pf.Evaluate(&timeseries.Backtest{
 		Data: hist,
 	})
 	if err != nil {
 		log.Fatal(err)
 	}
+	res := ev.Returns
 
-	fmt.Println("=== Backtest ===")
-	fmt.Printf("data %q (returns: %.1f%%; volatility: %.1f%%; sharpe ratio: %.2f)\n",
-		res, res.Returns(), res.Volatility(), res.SharpeRatio())
+	result := Result{
+		Parameters: Parameters{
+			Input:         *input,
+			HoldingPeriod: *holding,
+			Calendar:      *calendar,
+			Confidence:    *confidence,
+			Bootstrap:     *bootstrap,
+		},
+		Portfolio: output.NewPortfolio(pf),
+		First:     res.Data[0].Date.Format("2006-01"),
+		Last:      res.Data[len(res.Data)-1].Date.Format("2006-01"),
+		Months:    len(res.Data),
+		Metrics:   output.NewMetrics(res),
+	}
+	result.SharpeRatio = newSharpeInterval(res)
+	result.SyntheticMonths = timeseries.SyntheticMonths(hist, portfolio.Names(pf)...)
+	if pf.CashFlow.Months != 0 {
+		values, err := pf.EvalValues(&timeseries.Backtest{
+			Data: hist,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		v := values.Data[len(values.Data)-1].Value
+		result.FinalValue = &v
+	}
+	if pf.Position(portfolio.CashPosition) != 0 {
+		result.Leverage = &Leverage{
+			Leverage:    pf.Leverage(),
+			MarginCalls: ev.MarginCalls,
+		}
+	}
+	if pf.Tax != nil {
+		preTax, err := pf.WithoutTax().Eval(&timeseries.Backtest{
+			Data: hist,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		result.Tax = &Tax{
+			PreTaxReturns: output.Number(preTax.Returns()),
+			Returns:       output.Number(ev.AfterTaxReturns()),
+			Growth:        output.Number(ev.AfterTaxGrowth()),
+			Paid:          ev.TaxPaid,
+			Liquidation:   ev.LiquidationTax,
+		}
+	}
+	if pf.HasCosts() {
+		drag, err := pf.CostDrag(func() portfolio.QuoteProvider {
+			return &timeseries.Backtest{
+				Data: hist,
+			}
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		d := output.NewCostDrag(drag)
+		result.CostDrag = &d
+	}
+
+	charted := []timeseries.Data{res}
+	if len(bench.Positions) != 0 {
+		benchRes, err := bench.Eval(&timeseries.Backtest{
+			Data: hist,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		benchRes.Name = "Benchmark"
+		charted = append(charted, benchRes)
+
+		result.Benchmark = &Benchmark{
+			Portfolio: output.NewPortfolio(bench),
+			Metrics:   output.NewMetrics(benchRes),
+			Relative:  output.NewRelative(res.RelativeTo(benchRes)),
+		}
+		if result.Benchmark.SharpeRatio, err = compareSharpeRatios(res, benchRes); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *calendar {
+		result.Calendar = append(result.Calendar, newSeriesCalendar("portfolio", res))
+		for _, pos := range pf.Positions {
+			if pos.Name == portfolio.CashPosition {
+				continue
+			}
+			result.Calendar = append(result.Calendar, newSeriesCalendar(pos.Name, between(hist[pos.Name], res)))
+		}
+	}
+
+	if *holding > 0 {
+		if result.HoldingPeriods, err = holdingPeriods(*holding, hist); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	switch *format {
+	case output.Text:
+		printText(result, res.Name)
+	case output.JSON:
+		err = output.WriteJSON(os.Stdout, result)
+	case output.CSV:
+		err = writeCSV(os.Stdout, result)
+	case output.NDJSON:
+		err = writeNDJSON(os.Stdout, result)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *chartFile != "" {
+		if err := writeChart(charted); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+func writeChart(results []timeseries.Data) error {
+	var c *chart.Chart
+	switch *chartType {
+	case "equity":
+		var initial float64
+		for _, pos := range pf.Positions {
+			initial += pos.Value
+		}
+		c = chart.Equity("Backtest", initial, results...)
+	case "drawdown":
+		c = chart.Underwater("Drawdown", results...)
+	case "histogram":
+		var values []float64
+		for _, d := range results[0].Data {
+			values = append(values, 100*d.Value)
+		}
+		c = chart.Histogram("Monthly Returns", values, 40, chart.Percent)
+	default:
+		return fmt.Errorf("unknown chart type %q", *chartType)
+	}
+
+	return c.WriteFile(*chartFile)
+}
+
+func holdingPeriods(months int, hist map[string]timeseries.Data) (*HoldingPeriods, error) {
+	results, err := pf.HoldingPeriods(hist, months)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("holding period of %d months exceeds the available data", months)
+	}
+
+	ret := &HoldingPeriods{
+		Months:  months,
+		Periods: len(results),
+	}
+
+	if len(bench.Positions) != 0 {
+		benchResults, err := bench.HoldingPeriods(hist, months)
+		if err != nil {
+			return nil, err
+		}
+
+		var outperformed int
+		for i := range results {
+			if results[i].Growth() > benchResults[i].Growth() {
+				outperformed++
+			}
+		}
+		ret.Outperformed = &outperformed
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Returns() < results[j].Returns()
+	})
+
+	for _, p := range []int{50, 80, 90, 95, 99} {
+		idx := len(results) * (100 - p) / 100
+		ret.Percentiles = append(ret.Percentiles, HoldingPercentile{
+			Percentile: float64(p),
+			Returns:    output.Number(results[idx].Returns()),
+		})
+	}
+
+	for _, res := range results {
+		if res.Returns() < 0 {
+			ret.Losses++
+		}
+	}
+
+	ret.Worst = newPeriod(results[0])
+	ret.Best = newPeriod(results[len(results)-1])
+
+	return ret, nil
+}
+
+// between returns the months of h within the first and last month of ref.
+func between(h, ref timeseries.Data) timeseries.Data {
+	first, last := ref.Data[0].Date, ref.Data[len(ref.Data)-1].Date
+
+	ret := timeseries.Data{
+		Name: h.Name,
+	}
+	for _, d := range h.Data {
+		if !d.Date.Before(first) && !d.Date.After(last) {
+			ret.Data = append(ret.Data, d)
+		}
+	}
+	return ret
+}
+
+func newSeriesCalendar(name string, h timeseries.Data) SeriesCalendar {
+	ret := SeriesCalendar{
+		Series: name,
+		Years:  []CalendarYear{},
+	}
+
+	for _, y := range h.Calendar() {
+		cy := CalendarYear{
+			Year:    y.Year,
+			Returns: 100 * y.Returns,
+			Partial: y.Partial,
+		}
+		for _, v := range y.Months {
+			if math.IsNaN(v) {
+				cy.Months = append(cy.Months, nil)
+				continue
+			}
+			pct := 100 * v
+			cy.Months = append(cy.Months, &pct)
+		}
+		ret.Years = append(ret.Years, cy)
+
+		if y.Partial {
+			continue
+		}
+		ret.FullYears++
+		if y.Returns < 0 {
+			ret.NegativeYears++
+		}
+		if ret.Best == nil || cy.Returns > ret.Best.Returns {
+			ret.Best = &YearReturns{Year: cy.Year, Returns: cy.Returns}
+		}
+		if ret.Worst == nil || cy.Returns < ret.Worst.Returns {
+			ret.Worst = &YearReturns{Year: cy.Year, Returns: cy.Returns}
+		}
+	}
+
+	return ret
+}
+
+func newPeriod(res timeseries.Data) Period {
+	return Period{
+		First:   res.Data[0].Date.Format("2006-01"),
+		Last:    res.Data[len(res.Data)-1].Date.Format("2006-01"),
+		Metrics: output.NewMetrics(res),
+	}
+}
+
+// newSharpeInterval returns the annualized arithmetic Sharpe ratio of h and
+// its confidence interval.
+func newSharpeInterval(h timeseries.Data) SharpeInterval {
+	lo, hi := h.SharpeRatioInterval(*confidence / 100)
+	return SharpeInterval{
+		Value: output.Number(math.Sqrt(12) * h.MonthlySharpeRatio()),
+		Lower: output.Number(math.Sqrt(12) * lo),
+		Upper: output.Number(math.Sqrt(12) * hi),
+	}
+}
+
+// compareSharpeRatios tests whether h and the benchmark have the same Sharpe
+// ratio. The differences are annualized.
+func compareSharpeRatios(h, benchmark timeseries.Data) (SharpeComparison, error) {
+	annualize := func(t timeseries.SharpeTest) *output.SharpeTest {
+		t.Difference *= math.Sqrt(12)
+		t.Lower *= math.Sqrt(12)
+		t.Upper *= math.Sqrt(12)
+		ret := output.NewSharpeTest(t)
+		return &ret
+	}
+
+	ret := SharpeComparison{
+		JobsonKorkie: *annualize(timeseries.JobsonKorkie(h, benchmark, *confidence/100)),
+	}
+	if *bootstrap > 0 {
+		t, err := timeseries.BootstrapSharpeTest(h, benchmark, *bootstrap, *confidence/100)
+		if err != nil {
+			return ret, fmt.Errorf("-bootstrap: %w", err)
+		}
+		ret.Bootstrap = annualize(t)
+	}
+	return ret, nil
+}
+
+// applyStrategy sets the strategy of p from the command line flags, if given.
+func applyStrategy(p *portfolio.Portfolio) error {
+	if len(glide.Steps) != 0 && rules != nil {
+		return errors.New("specify either -glide or -strategy")
+	}
+	if len(glide.Steps) != 0 {
+		glide.Age = *age
+		p.Strategy = glide
+	}
+	if rules != nil {
+		p.Strategy = rules
+	}
+	return nil
+}
+
+// applyCash sets the interest and borrowing costs of p's cash position from
+// the command line flags, if given.
+func applyCash(p *portfolio.Portfolio) {
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "cash-rate":
+			p.Interest.Rate = *cashRate
+		case "cash-series":
+			p.Interest.Series = *cashRates
+		case "borrow-spread":
+			p.Borrowing.Spread = *spread
+		case "maintenance":
+			p.Borrowing.Maintenance = *margin
+		}
+	})
+}
+
+// applyTax sets the tax model of p if -tax is given and defaults the fund
+// type of its positions.
+func applyTax(p *portfolio.Portfolio) {
+	if *taxModel {
+		t := portfolio.DefaultTax
+		t.Allowance = *allowance
+		t.BaseRate = *baseRate
+		p.Tax = &t
+	}
+	if p.Tax == nil {
+		return
+	}
+	for i := range p.Positions {
+		if p.Positions[i].FundType == "" {
+			p.Positions[i].FundType = *fundType
+		}
+	}
 }
@@ -0,0 +1,490 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/octo/portfolio-mcmc/output"
+)
+
+// Result is the output of the backtest command. First and Last are the first
+// and last month of the backtest in the form "2006-01". FinalValue is only set
+// if the portfolio has cash flows; the metrics ignore cash flows. CostDrag is
+// only set if the portfolio has costs; the metrics are net of costs. Tax is
+// only set if the tax model is enabled; the metrics are net of the taxes paid
+// while holding the portfolio. Leverage is only set if the portfolio has a cash
+// position. SyntheticMonths is the number of months in which at least one
+// position uses backfilled data. SharpeRatio is the arithmetic Sharpe ratio
+// with its confidence interval.
+type Result struct {
+	Parameters      Parameters       `json:"parameters"`
+	Portfolio       output.Portfolio `json:"portfolio"`
+	First           string           `json:"first"`
+	Last            string           `json:"last"`
+	Months          int              `json:"months"`
+	SyntheticMonths int              `json:"synthetic_months,omitempty"`
+	Metrics         output.Metrics   `json:"metrics"`
+	SharpeRatio     SharpeInterval   `json:"sharpe_ratio"`
+	FinalValue      *float64         `json:"final_value,omitempty"`
+	CostDrag        *output.CostDrag `json:"cost_drag,omitempty"`
+	Tax             *Tax             `json:"tax,omitempty"`
+	Leverage        *Leverage        `json:"leverage,omitempty"`
+	Benchmark       *Benchmark       `json:"benchmark,omitempty"`
+	HoldingPeriods  *HoldingPeriods  `json:"holding_periods,omitempty"`
+	Calendar        []SeriesCalendar `json:"calendar,omitempty"`
+}
+
+// Parameters holds the command line flags that influence the result.
+type Parameters struct {
+	Input         string  `json:"input"`
+	HoldingPeriod int     `json:"holding_period"`
+	Calendar      bool    `json:"calendar"`
+	Confidence    float64 `json:"confidence"`
+	Bootstrap     int     `json:"bootstrap"`
+}
+
+// SharpeInterval is the annualized arithmetic Sharpe ratio, i.e. the average
+// monthly return divided by the standard deviation of the monthly returns,
+// times the square root of 12. Unlike the Sharpe ratio of the metrics, it
+// does not compound returns, which makes it suitable for statistical tests.
+// Lower and Upper bound its confidence interval.
+type SharpeInterval struct {
+	Value output.Number `json:"value"`
+	Lower output.Number `json:"lower"`
+	Upper output.Number `json:"upper"`
+}
+
+// SharpeComparison holds the tests whether the portfolio and the benchmark
+// have the same arithmetic Sharpe ratio. Differences are annualized like
+// SharpeInterval. Bootstrap is only set if the bootstrap test is enabled.
+type SharpeComparison struct {
+	JobsonKorkie output.SharpeTest  `json:"jobson_korkie"`
+	Bootstrap    *output.SharpeTest `json:"bootstrap,omitempty"`
+}
+
+// Tax summarizes the effect of taxes. Returns and Growth assume that the
+// portfolio is sold at the end of the backtest and the tax on the gains,
+// Liquidation, is paid. Paid is the sum of the taxes paid while holding the
+// portfolio, e.g. on the Vorabpauschale. Returns are annualized and in
+// percent.
+type Tax struct {
+	PreTaxReturns output.Number `json:"pre_tax_returns"`
+	Returns       output.Number `json:"returns"`
+	Growth        output.Number `json:"growth"`
+	Paid          float64       `json:"paid"`
+	Liquidation   float64       `json:"liquidation"`
+}
+
+// Leverage holds the initial leverage, i.e. the value of the assets relative to
+// the portfolio's value, and the number of months with margin calls.
+type Leverage struct {
+	Leverage    float64 `json:"leverage"`
+	MarginCalls int     `json:"margin_calls"`
+}
+
+// Benchmark holds the benchmark's metrics and the portfolio's statistics
+// relative to the benchmark.
+type Benchmark struct {
+	Portfolio   output.Portfolio `json:"portfolio"`
+	Metrics     output.Metrics   `json:"metrics"`
+	Relative    output.Relative  `json:"relative"`
+	SharpeRatio SharpeComparison `json:"sharpe_ratio"`
+}
+
+// HoldingPeriods summarizes all periods of Months consecutive months.
+// Outperformed is only set if a benchmark is given.
+type HoldingPeriods struct {
+	Months       int                 `json:"months"`
+	Periods      int                 `json:"periods"`
+	Percentiles  []HoldingPercentile `json:"percentiles"`
+	Worst        Period              `json:"worst"`
+	Best         Period              `json:"best"`
+	Losses       int                 `json:"losses"`
+	Outperformed *int                `json:"outperformed,omitempty"`
+}
+
+// HoldingPercentile is the annualized return, in percent, that Percentile
+// percent of the holding periods did better than.
+type HoldingPercentile struct {
+	Percentile float64       `json:"percentile"`
+	Returns    output.Number `json:"returns"`
+}
+
+// Period is a single holding period.
+type Period struct {
+	First   string         `json:"first"`
+	Last    string         `json:"last"`
+	Metrics output.Metrics `json:"metrics"`
+}
+
+// SeriesCalendar holds the calendar-year returns of the portfolio or one of
+// its positions. Best, Worst, NegativeYears and FullYears only consider full
+// calendar years; Best and Worst are omitted if there are none.
+type SeriesCalendar struct {
+	Series        string         `json:"series"`
+	Years         []CalendarYear `json:"years"`
+	Best          *YearReturns   `json:"best,omitempty"`
+	Worst         *YearReturns   `json:"worst,omitempty"`
+	NegativeYears int            `json:"negative_years"`
+	FullYears     int            `json:"full_years"`
+}
+
+// CalendarYear holds the returns of a calendar year in percent. Months has
+// twelve elements, January first; months without data are null.
+type CalendarYear struct {
+	Year    int        `json:"year"`
+	Months  []*float64 `json:"months"`
+	Returns float64    `json:"returns"`
+	Partial bool       `json:"partial"`
+}
+
+// YearReturns identifies a calendar year and its returns in percent.
+type YearReturns struct {
+	Year    int     `json:"year"`
+	Returns float64 `json:"returns"`
+}
+
+func printText(r Result, name string) {
+	fmt.Println("=== Backtest ===")
+	printMetrics(name, r.Metrics)
+	fmt.Printf("arithmetic sharpe ratio: %.2f (%g%% CI: %.2f – %.2f)\n",
+		r.SharpeRatio.Value, r.Parameters.Confidence, r.SharpeRatio.Lower, r.SharpeRatio.Upper)
+	if r.SyntheticMonths != 0 {
+		fmt.Printf("synthetic history: %d of %d months\n", r.SyntheticMonths, r.Months)
+	}
+	if r.FinalValue != nil {
+		fmt.Printf("final value after cash flows: %.0f\n", *r.FinalValue)
+	}
+	if t := r.Tax; t != nil {
+		wealth := fmt.Sprintf("terminal wealth: %.0f", float64(t.Growth)*r.Portfolio.Value)
+		if r.FinalValue != nil {
+			wealth = fmt.Sprintf("final value: %.0f", *r.FinalValue-t.Liquidation)
+		}
+		fmt.Printf("after tax: returns: %.1f%% (pre-tax: %.1f%%); %s; taxes paid: %.0f; tax on sale: %.0f\n",
+			t.Returns, t.PreTaxReturns, wealth, t.Paid, t.Liquidation)
+	}
+	if l := r.Leverage; l != nil {
+		fmt.Printf("leverage: %.2f; margin calls: %d\n", l.Leverage, l.MarginCalls)
+	}
+	if d := r.CostDrag; d != nil {
+		fmt.Printf("cost drag: %.2f%% p.a. (TER and tracking difference: %.2f%%; trading: %.2f%%)\n",
+			d.Total, d.Fund, d.Trading)
+	}
+
+	if b := r.Benchmark; b != nil {
+		fmt.Println()
+		fmt.Println("=== Benchmark ===")
+		printMetrics("Benchmark", b.Metrics)
+		fmt.Printf("beta: %.2f; alpha: %.1f%%; tracking error: %.1f%%; information ratio: %.2f; up capture: %.0f%%; down capture: %.0f%%\n",
+			b.Relative.Beta, b.Relative.Alpha, b.Relative.TrackingError, b.Relative.InformationRatio, b.Relative.UpCapture, b.Relative.DownCapture)
+		jk := b.SharpeRatio.JobsonKorkie
+		fmt.Printf("sharpe ratio difference: %.2f; Jobson-Korkie: z = %.2f, p = %.3f (%g%% CI: %.2f – %.2f)\n",
+			jk.Difference, jk.Z, jk.PValue, r.Parameters.Confidence, jk.Lower, jk.Upper)
+		if bs := b.SharpeRatio.Bootstrap; bs != nil {
+			fmt.Printf("bootstrap (%d resamples): p = %.3f (%g%% CI: %.2f – %.2f)\n",
+				r.Parameters.Bootstrap, bs.PValue, r.Parameters.Confidence, bs.Lower, bs.Upper)
+		}
+	}
+
+	if hp := r.HoldingPeriods; hp != nil {
+		fmt.Println()
+		fmt.Printf("=== Holding Period (%d months, %d periods) ===\n", hp.Months, hp.Periods)
+		for _, p := range hp.Percentiles {
+			fmt.Printf("[P%g] returns: %.1f%%\n", p.Percentile, p.Returns)
+		}
+
+		fmt.Println()
+		printPeriod("worst", hp.Worst)
+		printPeriod("best", hp.Best)
+		fmt.Printf("probability of loss: %.1f%% (%d of %d periods)\n",
+			100*float64(hp.Losses)/float64(hp.Periods), hp.Losses, hp.Periods)
+		if hp.Outperformed != nil {
+			fmt.Printf("probability of outperforming the benchmark: %.1f%% (%d of %d periods)\n",
+				100*float64(*hp.Outperformed)/float64(hp.Periods), *hp.Outperformed, hp.Periods)
+		}
+	}
+
+	if len(r.Calendar) != 0 {
+		fmt.Println()
+		printCalendarYears(r.Calendar)
+		for _, c := range r.Calendar {
+			fmt.Println()
+			printMonths(c)
+		}
+	}
+}
+
+// printCalendarYears prints one row per year and one column per series,
+// followed by the best and worst year and the number of negative years.
+func printCalendarYears(calendar []SeriesCalendar) {
+	widths := make([]int, len(calendar))
+	for i, c := range calendar {
+		widths[i] = len(c.Series)
+		if widths[i] < 14 {
+			widths[i] = 14
+		}
+	}
+
+	fmt.Println("=== Calendar Years ===")
+	fmt.Printf("%-8s", "year")
+	for i, c := range calendar {
+		fmt.Printf(" %*s", widths[i], c.Series)
+	}
+	fmt.Println()
+
+	var partial bool
+	for j, y := range calendar[0].Years {
+		label := strconv.Itoa(y.Year)
+		if y.Partial {
+			label += "*"
+			partial = true
+		}
+		fmt.Printf("%-8s", label)
+		for i, c := range calendar {
+			fmt.Printf(" %*s", widths[i], fmt.Sprintf("%.1f%%", c.Years[j].Returns))
+		}
+		fmt.Println()
+	}
+
+	fmt.Println()
+	for _, row := range []struct {
+		label string
+		year  func(SeriesCalendar) *YearReturns
+	}{
+		{"best", func(c SeriesCalendar) *YearReturns { return c.Best }},
+		{"worst", func(c SeriesCalendar) *YearReturns { return c.Worst }},
+	} {
+		fmt.Printf("%-8s", row.label)
+		for i, c := range calendar {
+			cell := "-"
+			if y := row.year(c); y != nil {
+				cell = fmt.Sprintf("%.1f%% (%d)", y.Returns, y.Year)
+			}
+			fmt.Printf(" %*s", widths[i], cell)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("%-8s", "negative")
+	for i, c := range calendar {
+		fmt.Printf(" %*s", widths[i], fmt.Sprintf("%d of %d", c.NegativeYears, c.FullYears))
+	}
+	fmt.Println()
+
+	if partial {
+		fmt.Println()
+		fmt.Println("* partial year, not considered for best, worst and negative years")
+	}
+}
+
+// printMonths prints a grid of monthly returns with one row per year.
+func printMonths(c SeriesCalendar) {
+	fmt.Printf("=== Monthly Returns: %s ===\n", c.Series)
+	fmt.Printf("%-4s", "year")
+	for m := time.January; m <= time.December; m++ {
+		fmt.Printf(" %6s", m.String()[:3])
+	}
+	fmt.Printf(" %7s\n", "total")
+
+	for _, y := range c.Years {
+		fmt.Printf("%-4d", y.Year)
+		for _, v := range y.Months {
+			if v == nil {
+				fmt.Printf(" %6s", "")
+				continue
+			}
+			fmt.Printf(" %5.1f%%", *v)
+		}
+		fmt.Printf(" %6.1f%%\n", y.Returns)
+	}
+}
+
+func printMetrics(name string, m output.Metrics) {
+	fmt.Printf("data %q (returns: %.1f%%; volatility: %.1f%%; sharpe ratio: %.2f)\n",
+		name, m.Returns, m.Volatility, m.SharpeRatio)
+}
+
+func printPeriod(label string, p Period) {
+	fmt.Printf("%s: %s – %s (returns: %.1f%%; volatility: %.1f%%; sharpe ratio: %.2f)\n",
+		label, p.First, p.Last, p.Metrics.Returns, p.Metrics.Volatility, p.Metrics.SharpeRatio)
+}
+
+// writeCSV writes the result as a sequence of CSV tables: parameters,
+// portfolio composition, metrics and, if computed, relative statistics and
+// holding periods.
+func writeCSV(w io.Writer, r Result) error {
+	cw := output.NewCSVWriter(w)
+
+	cw.Table("parameter", "value")
+	cw.Write("input", r.Parameters.Input)
+	cw.Write("holding_period", strconv.Itoa(r.Parameters.HoldingPeriod))
+	cw.Write("calendar", strconv.FormatBool(r.Parameters.Calendar))
+	cw.Write("confidence", output.Float(r.Parameters.Confidence))
+	cw.Write("bootstrap", strconv.Itoa(r.Parameters.Bootstrap))
+	cw.Write("first", r.First)
+	cw.Write("last", r.Last)
+	cw.Write("months", strconv.Itoa(r.Months))
+	if r.SyntheticMonths != 0 {
+		cw.Write("synthetic_months", strconv.Itoa(r.SyntheticMonths))
+	}
+	if r.FinalValue != nil {
+		cw.Write("final_value", output.Float(*r.FinalValue))
+	}
+
+	cw.Table(output.PositionsHeader...)
+	cw.Positions("portfolio", r.Portfolio)
+	if b := r.Benchmark; b != nil {
+		cw.Positions("benchmark", b.Portfolio)
+	}
+
+	cw.Table(append([]string{"series"}, output.MetricsHeader...)...)
+	cw.Write(append([]string{"portfolio"}, r.Metrics.Record()...)...)
+	if b := r.Benchmark; b != nil {
+		cw.Write(append([]string{"benchmark"}, b.Metrics.Record()...)...)
+
+		rel := b.Relative
+		cw.Table("beta", "alpha", "tracking_error", "information_ratio", "up_capture", "down_capture")
+		cw.Write(output.Float(float64(rel.Beta)), output.Float(float64(rel.Alpha)), output.Float(float64(rel.TrackingError)),
+			output.Float(float64(rel.InformationRatio)), output.Float(float64(rel.UpCapture)), output.Float(float64(rel.DownCapture)))
+
+		cw.Table("test", "difference", "z", "p_value", "lower", "upper")
+		jk := b.SharpeRatio.JobsonKorkie
+		cw.Write("jobson_korkie", output.Float(float64(jk.Difference)), output.Float(float64(jk.Z)), output.Float(float64(jk.PValue)),
+			output.Float(float64(jk.Lower)), output.Float(float64(jk.Upper)))
+		if bs := b.SharpeRatio.Bootstrap; bs != nil {
+			cw.Write("bootstrap", output.Float(float64(bs.Difference)), "", output.Float(float64(bs.PValue)),
+				output.Float(float64(bs.Lower)), output.Float(float64(bs.Upper)))
+		}
+	}
+
+	if t := r.Tax; t != nil {
+		cw.Table("pre_tax_returns", "after_tax_returns", "after_tax_growth", "tax_paid", "tax_liquidation")
+		cw.Write(output.Float(float64(t.PreTaxReturns)), output.Float(float64(t.Returns)), output.Float(float64(t.Growth)),
+			output.Float(t.Paid), output.Float(t.Liquidation))
+	}
+	if l := r.Leverage; l != nil {
+		cw.Table("leverage", "margin_calls")
+		cw.Write(output.Float(l.Leverage), strconv.Itoa(l.MarginCalls))
+	}
+	if d := r.CostDrag; d != nil {
+		cw.Table("cost_drag_total", "cost_drag_fund", "cost_drag_trading")
+		cw.Write(output.Float(float64(d.Total)), output.Float(float64(d.Fund)), output.Float(float64(d.Trading)))
+	}
+
+	if hp := r.HoldingPeriods; hp != nil {
+		cw.Table("percentile", "returns")
+		for _, p := range hp.Percentiles {
+			cw.Write(output.Float(p.Percentile), output.Float(float64(p.Returns)))
+		}
+
+		cw.Table(append([]string{"period", "first", "last"}, output.MetricsHeader...)...)
+		for _, p := range []struct {
+			label string
+			Period
+		}{
+			{"worst", hp.Worst},
+			{"best", hp.Best},
+		} {
+			cw.Write(append([]string{p.label, p.First, p.Last}, p.Metrics.Record()...)...)
+		}
+
+		outperformed := ""
+		if hp.Outperformed != nil {
+			outperformed = strconv.Itoa(*hp.Outperformed)
+		}
+		cw.Table("months", "periods", "losses", "outperformed")
+		cw.Write(strconv.Itoa(hp.Months), strconv.Itoa(hp.Periods), strconv.Itoa(hp.Losses), outperformed)
+	}
+
+	if len(r.Calendar) != 0 {
+		header := []string{"series", "year"}
+		for m := time.January; m <= time.December; m++ {
+			header = append(header, strings.ToLower(m.String()[:3]))
+		}
+		cw.Table(append(header, "returns", "partial")...)
+		for _, c := range r.Calendar {
+			for _, y := range c.Years {
+				record := []string{c.Series, strconv.Itoa(y.Year)}
+				for _, v := range y.Months {
+					if v == nil {
+						record = append(record, "")
+						continue
+					}
+					record = append(record, output.Float(*v))
+				}
+				cw.Write(append(record, output.Float(y.Returns), strconv.FormatBool(y.Partial))...)
+			}
+		}
+
+		cw.Table("series", "best_year", "best_returns", "worst_year", "worst_returns", "negative_years", "full_years")
+		for _, c := range r.Calendar {
+			record := []string{c.Series}
+			for _, y := range []*YearReturns{c.Best, c.Worst} {
+				if y == nil {
+					record = append(record, "", "")
+					continue
+				}
+				record = append(record, strconv.Itoa(y.Year), output.Float(y.Returns))
+			}
+			cw.Write(append(record, strconv.Itoa(c.NegativeYears), strconv.Itoa(c.FullYears))...)
+		}
+	}
+
+	return cw.Flush()
+}
+
+// writeNDJSON writes one line per part of the result.
+func writeNDJSON(w io.Writer, r Result) error {
+	type metrics struct {
+		Series string `json:"series"`
+		First  string `json:"first"`
+		Last   string `json:"last"`
+		Months int    `json:"months"`
+		output.Metrics
+		FinalValue *float64 `json:"final_value,omitempty"`
+	}
+	type composition struct {
+		Series string `json:"series"`
+		output.Portfolio
+	}
+
+	var (
+		enc = output.NewNDJSONWriter(w)
+		err error
+	)
+	write := func(typ string, v interface{}) {
+		if err == nil {
+			err = enc.Write(typ, v)
+		}
+	}
+
+	write("parameters", r.Parameters)
+	write("portfolio", composition{"portfolio", r.Portfolio})
+	write("metrics", metrics{"portfolio", r.First, r.Last, r.Months, r.Metrics, r.FinalValue})
+	write("sharpe_ratio", r.SharpeRatio)
+	if r.CostDrag != nil {
+		write("cost_drag", r.CostDrag)
+	}
+	if r.Tax != nil {
+		write("tax", r.Tax)
+	}
+	if r.Leverage != nil {
+		write("leverage", r.Leverage)
+	}
+	if b := r.Benchmark; b != nil {
+		write("portfolio", composition{"benchmark", b.Portfolio})
+		write("metrics", metrics{"benchmark", r.First, r.Last, r.Months, b.Metrics, nil})
+		write("relative", b.Relative)
+		write("sharpe_test", b.SharpeRatio)
+	}
+	if hp := r.HoldingPeriods; hp != nil {
+		write("holding_periods", hp)
+	}
+	for _, c := range r.Calendar {
+		write("calendar", c)
+	}
+
+	return err
+}
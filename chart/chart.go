@@ -0,0 +1,275 @@
+// Package chart renders simple charts of time series without external
+// dependencies.
+package chart
+
+import (
+	"fmt"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Default size of charts, in pixels.
+const (
+	DefaultWidth  = 800
+	DefaultHeight = 450
+)
+
+// Chart is a chart that can be written to a file.
+type Chart struct {
+	Title         string
+	Width, Height int
+
+	draw func(c canvas)
+}
+
+func newChart(title string, draw func(c canvas)) *Chart {
+	return &Chart{
+		Title:  title,
+		Width:  DefaultWidth,
+		Height: DefaultHeight,
+		draw:   draw,
+	}
+}
+
+func (c *Chart) render(cv canvas) {
+	cv.text(float64(c.Width)/2, 24, c.Title, anchorMiddle, 16)
+	c.draw(cv)
+}
+
+// WriteSVG renders the chart as SVG.
+func (c *Chart) WriteSVG(w io.Writer) error {
+	svg := newSVGCanvas(c.Width, c.Height)
+	c.render(svg)
+
+	_, err := io.WriteString(w, svg.String())
+	return err
+}
+
+// WritePNG renders the chart as PNG.
+func (c *Chart) WritePNG(w io.Writer) error {
+	cv := newPNGCanvas(c.Width, c.Height)
+	c.render(cv)
+
+	return png.Encode(w, cv.img)
+}
+
+// WriteFile renders the chart to path. The format is determined by the file
+// extension.
+func (c *Chart) WriteFile(path string) error {
+	var write func(io.Writer) error
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".svg":
+		write = c.WriteSVG
+	case ".png":
+		write = c.WritePNG
+	default:
+		return fmt.Errorf("unsupported file format %q", ext)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+type point struct {
+	x, y float64
+}
+
+type anchor int
+
+const (
+	anchorStart anchor = iota
+	anchorMiddle
+	anchorEnd
+)
+
+// canvas is implemented by the output formats. Coordinates are in pixels, the
+// origin is the top left corner.
+type canvas interface {
+	line(points []point, c color.RGBA, width float64)
+	polygon(points []point, fill color.RGBA)
+	text(x, y float64, s string, a anchor, size float64)
+}
+
+var (
+	black     = color.RGBA{0x00, 0x00, 0x00, 0xff}
+	gray      = color.RGBA{0x99, 0x99, 0x99, 0xff}
+	lightGray = color.RGBA{0xe5, 0xe5, 0xe5, 0xff}
+	blue      = color.RGBA{0x1f, 0x77, 0xb4, 0xff}
+	red       = color.RGBA{0xd6, 0x27, 0x28, 0xff}
+
+	// palette is used for charts with multiple series.
+	palette = []color.RGBA{
+		blue,
+		{0xff, 0x7f, 0x0e, 0xff},
+		{0x2c, 0xa0, 0x2c, 0xff},
+		red,
+		{0x94, 0x67, 0xbd, 0xff},
+		{0x8c, 0x56, 0x4b, 0xff},
+		{0xe3, 0x77, 0xc2, 0xff},
+		{0x7f, 0x7f, 0x7f, 0xff},
+	}
+)
+
+func paletteColor(i int) color.RGBA {
+	return palette[i%len(palette)]
+}
+
+// rect returns the corners of a rectangle.
+func rect(x0, y0, x1, y1 float64) []point {
+	return []point{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}}
+}
+
+// plot maps data coordinates to canvas coordinates and draws the axes.
+type plot struct {
+	width, height          float64
+	xMin, xMax, yMin, yMax float64
+}
+
+// Margins around the plot area, in pixels.
+const (
+	marginLeft   = 80
+	marginRight  = 20
+	marginTop    = 40
+	marginBottom = 40
+)
+
+func newPlot(c *Chart, xMin, xMax, yMin, yMax float64) *plot {
+	if xMin == xMax {
+		xMin, xMax = xMin-1, xMax+1
+	}
+	if yMin == yMax {
+		yMin, yMax = yMin-1, yMax+1
+	}
+
+	return &plot{
+		width:  float64(c.Width),
+		height: float64(c.Height),
+		xMin:   xMin,
+		xMax:   xMax,
+		yMin:   yMin,
+		yMax:   yMax,
+	}
+}
+
+func (p *plot) x(v float64) float64 {
+	return marginLeft + (v-p.xMin)/(p.xMax-p.xMin)*(p.width-marginLeft-marginRight)
+}
+
+func (p *plot) y(v float64) float64 {
+	return p.height - marginBottom - (v-p.yMin)/(p.yMax-p.yMin)*(p.height-marginTop-marginBottom)
+}
+
+func (p *plot) point(x, y float64) point {
+	return point{p.x(x), p.y(y)}
+}
+
+// legend draws a colored box and the name of each series in the top left
+// corner of the plot area.
+func (p *plot) legend(c canvas, names []string) {
+	for i, name := range names {
+		y := marginTop + 8 + 16*float64(i)
+		c.polygon(rect(marginLeft+8, y, marginLeft+18, y+10), paletteColor(i))
+		c.text(marginLeft+24, y+9, name, anchorStart, 12)
+	}
+}
+
+// axes draws grid lines and labels for both axes. formatX and formatY
+// format the tick labels.
+func (p *plot) axes(c canvas, xTicks, yTicks []float64, formatX, formatY func(float64) string) {
+	for _, t := range yTicks {
+		c.line([]point{p.point(p.xMin, t), p.point(p.xMax, t)}, lightGray, 1)
+		c.text(marginLeft-6, p.y(t)+4, formatY(t), anchorEnd, 12)
+	}
+	for _, t := range xTicks {
+		c.line([]point{p.point(t, p.yMin), p.point(t, p.yMax)}, lightGray, 1)
+		c.text(p.x(t), p.height-marginBottom+18, formatX(t), anchorMiddle, 12)
+	}
+
+	c.line([]point{
+		p.point(p.xMin, p.yMax),
+		p.point(p.xMin, p.yMin),
+		p.point(p.xMax, p.yMin),
+	}, gray, 1)
+}
+
+// ticks returns "nice" tick positions between min and max.
+func ticks(min, max float64, n int) []float64 {
+	if max <= min || n < 1 {
+		return nil
+	}
+
+	step := math.Pow(10, math.Floor(math.Log10((max-min)/float64(n))))
+	for _, f := range []float64{1, 2, 5, 10} {
+		if (max-min)/(f*step) <= float64(n) {
+			step *= f
+			break
+		}
+	}
+
+	var ret []float64
+	for t := math.Ceil(min/step) * step; t <= max+step/1e6; t += step {
+		ret = append(ret, t)
+	}
+	return ret
+}
+
+// finite returns true if v is neither NaN nor infinite.
+func finite(v float64) bool {
+	return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+// decimalYear converts t to a number suitable for the x axis.
+func decimalYear(t time.Time) float64 {
+	return float64(t.Year()) + float64(t.Month()-1)/12
+}
+
+// yearTicks returns ticks at the start of years.
+func yearTicks(min, max float64) []float64 {
+	var ret []float64
+	for _, t := range ticks(min, max, 8) {
+		if t == math.Trunc(t) {
+			ret = append(ret, t)
+		}
+	}
+	return ret
+}
+
+func formatYear(v float64) string {
+	return fmt.Sprintf("%.0f", v)
+}
+
+// Amount formats monetary amounts, e.g. "250k".
+func Amount(v float64) string {
+	switch a := math.Abs(v); {
+	case a >= 1e6:
+		return fmt.Sprintf("%gM", math.Round(v/1e4)/100)
+	case a >= 1e3:
+		return fmt.Sprintf("%gk", math.Round(v/10)/100)
+	default:
+		return fmt.Sprintf("%g", math.Round(v*100)/100)
+	}
+}
+
+// Percent formats values that are already in percent, e.g. "7.5%".
+func Percent(v float64) string {
+	return fmt.Sprintf("%.4g%%", v)
+}
+
+// Number formats plain numbers, e.g. ratios.
+func Number(v float64) string {
+	return fmt.Sprintf("%.4g", v)
+}
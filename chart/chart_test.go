@@ -0,0 +1,101 @@
+package chart
+
+import (
+	"bytes"
+	"image/color"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/octo/portfolio-mcmc/timeseries"
+)
+
+func newSeries(name string, values ...float64) timeseries.Data {
+	h := timeseries.Data{Name: name}
+	for i, v := range values {
+		h.Data = append(h.Data, timeseries.Datum{
+			Date:  time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC).AddDate(0, i, 0),
+			Value: v,
+		})
+	}
+	return h
+}
+
+func TestRender(t *testing.T) {
+	nan := math.NaN()
+
+	inputs := []struct {
+		name   string
+		series []timeseries.Data
+	}{
+		{name: "empty", series: []timeseries.Data{newSeries("empty")}},
+		{name: "one point", series: []timeseries.Data{newSeries("one", .01)}},
+		{name: "NaN", series: []timeseries.Data{newSeries("nan", .01, nan, -.02, .03), newSeries("all nan", nan, nan)}},
+		{name: "mixed", series: []timeseries.Data{newSeries("empty"), newSeries("two", .01, -.01)}},
+	}
+
+	for _, in := range inputs {
+		var values []float64
+		var points []Point
+		for _, h := range in.series {
+			for _, d := range h.Data {
+				values = append(values, d.Value)
+			}
+			points = append(points, Point{Label: h.Name, Volatility: h.Volatility(), Returns: h.Returns()})
+		}
+
+		charts := map[string]*Chart{
+			"Lines":      Lines("Lines", Percent, in.series...),
+			"Equity":     Equity("Equity", 100, in.series...),
+			"Underwater": Underwater("Underwater", in.series...),
+			"Fan":        Fan("Fan", in.series),
+			"Histogram":  Histogram("Histogram", values, 10, Percent),
+			"Frontier":   Frontier("Frontier", points),
+		}
+
+		for name, c := range charts {
+			t.Run(in.name+"/"+name, func(t *testing.T) {
+				var svg bytes.Buffer
+				if err := c.WriteSVG(&svg); err != nil {
+					t.Fatalf("WriteSVG() = %v", err)
+				}
+				if s := svg.String(); strings.Contains(s, "NaN") || strings.Contains(s, "Inf") {
+					t.Errorf("WriteSVG() contains non-finite coordinates:\n%s", s)
+				}
+
+				var png bytes.Buffer
+				if err := c.WritePNG(&png); err != nil {
+					t.Fatalf("WritePNG() = %v", err)
+				}
+			})
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	// Without the NaN, the values end up in two bins of equal height.
+	c := Histogram("Histogram", []float64{1, math.NaN(), 2, math.Inf(1)}, 2, Number)
+	cv := &recordingCanvas{}
+	c.render(cv)
+
+	if got, want := len(cv.polygons), 2; got != want {
+		t.Fatalf("got %d bars, want %d", got, want)
+	}
+	if top0, top1 := cv.polygons[0][0].y, cv.polygons[1][0].y; top0 != top1 {
+		t.Errorf("bars have different heights: %g and %g", top0, top1)
+	}
+}
+
+// recordingCanvas records the polygons drawn on it.
+type recordingCanvas struct {
+	polygons [][]point
+}
+
+func (c *recordingCanvas) line([]point, color.RGBA, float64) {}
+
+func (c *recordingCanvas) polygon(points []point, _ color.RGBA) {
+	c.polygons = append(c.polygons, points)
+}
+
+func (c *recordingCanvas) text(float64, float64, string, anchor, float64) {}
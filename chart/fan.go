@@ -0,0 +1,72 @@
+package chart
+
+import (
+	"image/color"
+
+	"github.com/octo/portfolio-mcmc/timeseries"
+)
+
+// Fan returns a fan chart of the given bands, e.g. the result of
+// timeseries.WealthBands. The bands must be ordered from the highest to the
+// lowest or from the lowest to the highest percentile. The area between the
+// first and last band is shaded lightly, the area between the second and
+// second-to-last band a bit darker, and so on. With an odd number of bands,
+// the middle band is drawn as a line.
+func Fan(title string, bands []timeseries.Data) *Chart {
+	c := newChart(title, nil)
+	c.draw = func(cv canvas) {
+		p := timePlot(c, bands, true)
+		if p == nil {
+			return
+		}
+		p.axes(cv, yearTicks(p.xMin, p.xMax), ticks(p.yMin, p.yMax, 6), formatYear, Amount)
+
+		pairs := len(bands) / 2
+		for i := 0; i < pairs; i++ {
+			outer, inner := bands[i], bands[len(bands)-1-i]
+
+			points := seriesPoints(p, outer)
+			innerPoints := seriesPoints(p, inner)
+			for j := len(innerPoints) - 1; j >= 0; j-- {
+				points = append(points, innerPoints[j])
+			}
+
+			cv.polygon(points, shade(blue, float64(i+1)/float64(pairs+1)))
+		}
+
+		if len(bands)%2 == 1 {
+			cv.line(seriesPoints(p, bands[pairs]), blue, 2)
+		}
+
+		for _, b := range bands {
+			points := seriesPoints(p, b)
+			if len(points) == 0 {
+				continue
+			}
+			last := points[len(points)-1]
+			cv.text(last.x-4, last.y-4, b.Name, anchorEnd, 11)
+		}
+	}
+
+	return c
+}
+
+// seriesPoints converts h to canvas coordinates. NaN and infinite values are
+// skipped.
+func seriesPoints(p *plot, h timeseries.Data) []point {
+	var ret []point
+	for _, d := range h.Data {
+		if finite(d.Value) {
+			ret = append(ret, p.point(decimalYear(d.Date), d.Value))
+		}
+	}
+	return ret
+}
+
+// shade mixes c with white. f = 0 returns white, f = 1 returns c.
+func shade(c color.RGBA, f float64) color.RGBA {
+	mix := func(v uint8) uint8 {
+		return uint8(255 - f*(255-float64(v)))
+	}
+	return color.RGBA{mix(c.R), mix(c.G), mix(c.B), 0xff}
+}
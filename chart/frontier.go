@@ -0,0 +1,81 @@
+package chart
+
+import (
+	"math"
+	"sort"
+)
+
+// Point is a portfolio in risk/return space.
+type Point struct {
+	Label      string
+	Volatility float64
+	Returns    float64
+}
+
+// Frontier returns a scatter plot of the points with volatility on the x axis
+// and returns on the y axis, both in percent. The efficient frontier, i.e. the
+// points for which no other point has higher returns at lower or equal
+// volatility, is connected by a line. Labels are drawn next to their points.
+// Points with NaN or infinite coordinates are ignored.
+func Frontier(title string, points []Point) *Chart {
+	c := newChart(title, nil)
+	c.draw = func(cv canvas) {
+		var sorted []Point
+		for _, pt := range points {
+			if finite(pt.Volatility) && finite(pt.Returns) {
+				sorted = append(sorted, pt)
+			}
+		}
+		if len(sorted) == 0 {
+			return
+		}
+
+		sort.Slice(sorted, func(i, j int) bool {
+			if sorted[i].Volatility == sorted[j].Volatility {
+				return sorted[i].Returns > sorted[j].Returns
+			}
+			return sorted[i].Volatility < sorted[j].Volatility
+		})
+
+		xMin, xMax := sorted[0].Volatility, sorted[len(sorted)-1].Volatility
+		yMin, yMax := sorted[0].Returns, sorted[0].Returns
+		for _, pt := range sorted {
+			if pt.Returns < yMin {
+				yMin = pt.Returns
+			}
+			if pt.Returns > yMax {
+				yMax = pt.Returns
+			}
+		}
+		// leave some room around the outermost points.
+		dx, dy := (xMax-xMin)*0.05, (yMax-yMin)*0.05
+		p := newPlot(c, xMin-dx, xMax+dx, yMin-dy, yMax+dy)
+		p.axes(cv, ticks(p.xMin, p.xMax, 8), ticks(p.yMin, p.yMax, 6), Percent, Percent)
+
+		var (
+			efficient []point
+			best      = math.Inf(-1)
+		)
+		for _, pt := range sorted {
+			if pt.Returns > best {
+				efficient = append(efficient, p.point(pt.Volatility, pt.Returns))
+				best = pt.Returns
+			}
+		}
+		cv.line(efficient, paletteColor(1), 1.5)
+
+		for _, pt := range sorted {
+			x, y := p.x(pt.Volatility), p.y(pt.Returns)
+			cv.polygon(rect(x-2.5, y-2.5, x+2.5, y+2.5), blue)
+			switch {
+			case pt.Label == "":
+			case x > 0.7*float64(c.Width):
+				cv.text(x-6, y-4, pt.Label, anchorEnd, 11)
+			default:
+				cv.text(x+6, y-4, pt.Label, anchorStart, 11)
+			}
+		}
+	}
+
+	return c
+}
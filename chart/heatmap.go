@@ -0,0 +1,54 @@
+package chart
+
+import (
+	"fmt"
+	"math"
+	"unicode/utf8"
+)
+
+// Heatmap returns a chart of a matrix with values between -1 and 1, e.g. a
+// correlation matrix. Positive values are drawn in blue, negative values in
+// red. Rows are labeled with "[i] name", columns with "[i]".
+func Heatmap(title string, names []string, values [][]float64) *Chart {
+	c := newChart(title, nil)
+	c.draw = func(cv canvas) {
+		n := len(names)
+		if n == 0 {
+			return
+		}
+
+		var labels []string
+		labelWidth := 0
+		for i, name := range names {
+			l := fmt.Sprintf("[%d] %s", i+1, name)
+			labels = append(labels, l)
+			if w := utf8.RuneCountInString(l); w > labelWidth {
+				labelWidth = w
+			}
+		}
+
+		left := float64(7*labelWidth + 16)
+		top := float64(marginTop + 20)
+		size := math.Min((float64(c.Width)-left-marginRight)/float64(n),
+			(float64(c.Height)-top-marginBottom/2)/float64(n))
+
+		for i := range names {
+			y := top + float64(i)*size
+			cv.text(left-6, y+size/2+4, labels[i], anchorEnd, 12)
+			cv.text(left+float64(i)*size+size/2, top-6, fmt.Sprintf("[%d]", i+1), anchorMiddle, 12)
+
+			for j, v := range values[i] {
+				x := left + float64(j)*size
+
+				col := blue
+				if v < 0 {
+					col = red
+				}
+				cv.polygon(rect(x+1, y+1, x+size-1, y+size-1), shade(col, math.Min(math.Abs(v), 1)))
+				cv.text(x+size/2, y+size/2+4, fmt.Sprintf("%.2f", v), anchorMiddle, 11)
+			}
+		}
+	}
+
+	return c
+}
@@ -0,0 +1,61 @@
+package chart
+
+import (
+	"math"
+)
+
+// Histogram returns a histogram of values with the given number of bins.
+// format formats the labels of the x axis. NaN and infinite values are
+// ignored.
+func Histogram(title string, values []float64, bins int, format func(float64) string) *Chart {
+	var finiteValues []float64
+	for _, v := range values {
+		if finite(v) {
+			finiteValues = append(finiteValues, v)
+		}
+	}
+	values = finiteValues
+
+	c := newChart(title, nil)
+	c.draw = func(cv canvas) {
+		if len(values) == 0 || bins < 1 {
+			return
+		}
+
+		lo, hi := values[0], values[0]
+		for _, v := range values {
+			lo = math.Min(lo, v)
+			hi = math.Max(hi, v)
+		}
+		if lo == hi {
+			lo, hi = lo-1, hi+1
+		}
+		width := (hi - lo) / float64(bins)
+
+		counts := make([]int, bins)
+		var max int
+		for _, v := range values {
+			i := int((v - lo) / width)
+			if i >= bins {
+				i = bins - 1
+			}
+			counts[i]++
+			if counts[i] > max {
+				max = counts[i]
+			}
+		}
+
+		// the y axis shows the share of values in percent.
+		total := float64(len(values))
+		p := newPlot(c, lo, hi, 0, 100*float64(max)/total)
+		p.axes(cv, ticks(lo, hi, 8), ticks(p.yMin, p.yMax, 5), format, Percent)
+
+		for i, n := range counts {
+			x0, x1 := lo+float64(i)*width, lo+float64(i+1)*width
+			top := 100 * float64(n) / total
+			cv.polygon(rect(p.x(x0)+1, p.y(top), p.x(x1)-1, p.y(0)), blue)
+		}
+	}
+
+	return c
+}
@@ -0,0 +1,160 @@
+package chart
+
+import (
+	"github.com/octo/portfolio-mcmc/timeseries"
+)
+
+// Lines returns a line chart of the series' values over time. format formats
+// the labels of the y axis.
+func Lines(title string, format func(float64) string, series ...timeseries.Data) *Chart {
+	c := newChart(title, nil)
+	c.draw = func(cv canvas) {
+		p := timePlot(c, series, false)
+		if p == nil {
+			return
+		}
+		p.axes(cv, yearTicks(p.xMin, p.xMax), ticks(p.yMin, p.yMax, 6), formatYear, format)
+
+		var names []string
+		for i, h := range series {
+			cv.line(seriesPoints(p, h), paletteColor(i), 2)
+			names = append(names, h.Name)
+		}
+		if len(series) > 1 {
+			p.legend(cv, names)
+		}
+	}
+
+	return c
+}
+
+// Equity returns a chart of the value of an investment of initial in each of
+// the series over time.
+func Equity(title string, initial float64, series ...timeseries.Data) *Chart {
+	var wealth []timeseries.Data
+	for _, h := range series {
+		w := h.Wealth(initial)
+		if len(h.Data) != 0 {
+			// prepend the initial investment one month before the first return.
+			w.Data = append([]timeseries.Datum{{
+				Date:  h.Data[0].Date.AddDate(0, -1, 0),
+				Value: initial,
+			}}, w.Data...)
+		}
+		wealth = append(wealth, w)
+	}
+
+	return Lines(title, Amount, wealth...)
+}
+
+// Underwater returns a chart of the series' drawdowns, i.e. the decline from
+// the previous peak, over time.
+func Underwater(title string, series ...timeseries.Data) *Chart {
+	var drawdowns []timeseries.Data
+	for _, h := range series {
+		uw := h.Underwater()
+		for i := range uw.Data {
+			uw.Data[i].Value *= 100
+		}
+		drawdowns = append(drawdowns, uw)
+	}
+
+	c := newChart(title, nil)
+	c.draw = func(cv canvas) {
+		p := timePlot(c, drawdowns, true)
+		if p == nil {
+			return
+		}
+		p.axes(cv, yearTicks(p.xMin, p.xMax), ticks(p.yMin, p.yMax, 6), formatYear, Percent)
+
+		var names []string
+		for i, h := range drawdowns {
+			points := seriesPoints(p, h)
+			if i == 0 && len(points) != 0 {
+				// only fill the first series so that others remain visible.
+				area := append([]point{{points[0].x, p.y(0)}}, points...)
+				area = append(area, point{points[len(points)-1].x, p.y(0)})
+				cv.polygon(area, shade(paletteColor(i), 0.4))
+			}
+			cv.line(points, paletteColor(i), 1.5)
+			names = append(names, h.Name)
+		}
+		if len(drawdowns) > 1 {
+			p.legend(cv, names)
+		}
+	}
+
+	return c
+}
+
+// timePlot returns a plot covering the dates and values of all series. If
+// includeZero is true, the y axis always includes zero. Returns nil if there
+// is no data.
+func timePlot(c *Chart, series []timeseries.Data, includeZero bool) *plot {
+	var (
+		first      = true
+		xMin, xMax float64
+		yMin, yMax float64
+	)
+	for _, h := range series {
+		if len(h.Data) == 0 {
+			continue
+		}
+
+		x0, x1 := decimalYear(h.Data[0].Date), decimalYear(h.Data[len(h.Data)-1].Date)
+		y0, y1, ok := valueRange(h)
+		if !ok {
+			continue
+		}
+		if first {
+			xMin, xMax, yMin, yMax = x0, x1, y0, y1
+			first = false
+			continue
+		}
+
+		if x0 < xMin {
+			xMin = x0
+		}
+		if x1 > xMax {
+			xMax = x1
+		}
+		if y0 < yMin {
+			yMin = y0
+		}
+		if y1 > yMax {
+			yMax = y1
+		}
+	}
+	if first {
+		return nil
+	}
+
+	if includeZero {
+		if yMin > 0 {
+			yMin = 0
+		}
+		if yMax < 0 {
+			yMax = 0
+		}
+	}
+
+	return newPlot(c, xMin, xMax, yMin, yMax)
+}
+
+// valueRange returns the minimum and maximum of the finite values of h. ok is
+// false if there are none.
+func valueRange(h timeseries.Data) (min, max float64, ok bool) {
+	for _, d := range h.Data {
+		if !finite(d.Value) {
+			continue
+		}
+		if !ok || d.Value < min {
+			min = d.Value
+		}
+		if !ok || d.Value > max {
+			max = d.Value
+		}
+		ok = true
+	}
+	return min, max, ok
+}
@@ -0,0 +1,125 @@
+package chart
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"unicode/utf8"
+)
+
+// pngCanvas renders to an image.RGBA. All colors are drawn opaque.
+type pngCanvas struct {
+	img *image.RGBA
+}
+
+func newPNGCanvas(width, height int) *pngCanvas {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	return &pngCanvas{img: img}
+}
+
+func (c *pngCanvas) line(points []point, col color.RGBA, width float64) {
+	r := width / 2
+	for i := 1; i < len(points); i++ {
+		p0, p1 := points[i-1], points[i]
+		steps := int(math.Ceil(2 * math.Hypot(p1.x-p0.x, p1.y-p0.y)))
+		if steps == 0 {
+			steps = 1
+		}
+
+		for s := 0; s <= steps; s++ {
+			f := float64(s) / float64(steps)
+			c.dot(p0.x+f*(p1.x-p0.x), p0.y+f*(p1.y-p0.y), r, col)
+		}
+	}
+}
+
+// dot fills a square of "radius" r around (x, y); at least one pixel.
+func (c *pngCanvas) dot(x, y, r float64, col color.RGBA) {
+	x0, x1 := int(math.Round(x-r)), int(math.Round(x+r))
+	y0, y1 := int(math.Round(y-r)), int(math.Round(y+r))
+	if x1 == x0 {
+		x1++
+	}
+	if y1 == y0 {
+		y1++
+	}
+
+	for py := y0; py < y1; py++ {
+		for px := x0; px < x1; px++ {
+			c.img.SetRGBA(px, py, col)
+		}
+	}
+}
+
+// polygon fills the polygon using the even-odd rule.
+func (c *pngCanvas) polygon(points []point, fill color.RGBA) {
+	if len(points) < 3 {
+		return
+	}
+
+	yMin, yMax := points[0].y, points[0].y
+	for _, p := range points {
+		yMin = math.Min(yMin, p.y)
+		yMax = math.Max(yMax, p.y)
+	}
+
+	for py := int(math.Floor(yMin)); py <= int(math.Ceil(yMax)); py++ {
+		y := float64(py) + 0.5
+
+		var xs []float64
+		for i := range points {
+			p0, p1 := points[i], points[(i+1)%len(points)]
+			if (p0.y <= y) == (p1.y <= y) {
+				continue
+			}
+			xs = append(xs, p0.x+(y-p0.y)/(p1.y-p0.y)*(p1.x-p0.x))
+		}
+		sort.Float64s(xs)
+
+		for i := 0; i+1 < len(xs); i += 2 {
+			for px := int(math.Round(xs[i])); px < int(math.Round(xs[i+1])); px++ {
+				c.img.SetRGBA(px, py, fill)
+			}
+		}
+	}
+}
+
+// text renders s using the built-in 5x7 pixel font. The font is scaled by an
+// integer factor to approximate size.
+func (c *pngCanvas) text(x, y float64, s string, a anchor, size float64) {
+	scale := int(math.Round(size / 10))
+	if scale < 1 {
+		scale = 1
+	}
+
+	width := float64(6*scale*utf8.RuneCountInString(s) - scale)
+	switch a {
+	case anchorMiddle:
+		x -= width / 2
+	case anchorEnd:
+		x -= width
+	}
+
+	left, top := int(math.Round(x)), int(math.Round(y))-7*scale
+	for _, r := range s {
+		if g, ok := glyph(r); ok {
+			for row, bits := range g {
+				for col, bit := range bits {
+					if bit != '1' {
+						continue
+					}
+					for dy := 0; dy < scale; dy++ {
+						for dx := 0; dx < scale; dx++ {
+							c.img.SetRGBA(left+col*scale+dx, top+row*scale+dy, black)
+						}
+					}
+				}
+			}
+		}
+		left += 6 * scale
+	}
+}
@@ -0,0 +1,61 @@
+package chart
+
+import (
+	"fmt"
+	"html"
+	"image/color"
+	"strings"
+)
+
+// svgCanvas renders to a hand-written SVG document.
+type svgCanvas struct {
+	b strings.Builder
+}
+
+func newSVGCanvas(width, height int) *svgCanvas {
+	c := &svgCanvas{}
+	fmt.Fprintf(&c.b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif">`+"\n",
+		width, height, width, height)
+	fmt.Fprintf(&c.b, `<rect width="%d" height="%d" fill="white"/>`+"\n", width, height)
+	return c
+}
+
+func (c *svgCanvas) String() string {
+	return c.b.String() + "</svg>\n"
+}
+
+func (c *svgCanvas) line(points []point, col color.RGBA, width float64) {
+	fmt.Fprintf(&c.b, `<polyline points="%s" fill="none" stroke="%s" stroke-opacity="%s" stroke-width="%g"/>`+"\n",
+		svgPoints(points), svgColor(col), svgOpacity(col), width)
+}
+
+func (c *svgCanvas) polygon(points []point, fill color.RGBA) {
+	fmt.Fprintf(&c.b, `<polygon points="%s" fill="%s" fill-opacity="%s"/>`+"\n",
+		svgPoints(points), svgColor(fill), svgOpacity(fill))
+}
+
+func (c *svgCanvas) text(x, y float64, s string, a anchor, size float64) {
+	anchors := map[anchor]string{
+		anchorStart:  "start",
+		anchorMiddle: "middle",
+		anchorEnd:    "end",
+	}
+	fmt.Fprintf(&c.b, `<text x="%.1f" y="%.1f" text-anchor="%s" font-size="%g">%s</text>`+"\n",
+		x, y, anchors[a], size, html.EscapeString(s))
+}
+
+func svgPoints(points []point) string {
+	var fields []string
+	for _, p := range points {
+		fields = append(fields, fmt.Sprintf("%.1f,%.1f", p.x, p.y))
+	}
+	return strings.Join(fields, " ")
+}
+
+func svgColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+func svgOpacity(c color.RGBA) string {
+	return fmt.Sprintf("%.2f", float64(c.A)/255)
+}
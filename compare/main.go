@@ -0,0 +1,245 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/octo/portfolio-mcmc/chart"
+	"github.com/octo/portfolio-mcmc/output"
+	"github.com/octo/portfolio-mcmc/portfolio"
+	"github.com/octo/portfolio-mcmc/timeseries"
+)
+
+var (
+	input       = flag.String("input", "history.csv", "file containing historic returns")
+	validate    = flag.Bool("validate", false, "validate the input file before simulating")
+	iterations  = flag.Int("iterations", 10000, "number of scenarios")
+	percentiles = flag.String("percentiles", "50,80,90,95,99", "comma separated list of percentiles to report")
+	chartFile   = flag.String("chart", "", "write a chart of the portfolios' median volatility and returns to this file; the format is determined by the extension (.svg or .png)")
+
+	portfolios []portfolio.Portfolio
+	strategies = map[string]portfolio.Strategy{}
+	derived    timeseries.Derivations
+)
+
+func main() {
+	flag.Func("p", `portfolio as "name=pos:weight,pos:weight,..."; repeat for each portfolio`, func(flagValue string) error {
+		p, err := portfolio.Parse(flagValue)
+		if err != nil {
+			return err
+		}
+		if p.Name == "" {
+			p.Name = string('A' + rune(len(portfolios)))
+		}
+
+		portfolios = append(portfolios, p)
+		return nil
+	})
+	flag.Func("portfolio", `load portfolios from a definition file, "file.json[:name]"; without a name, all portfolios in the file are loaded; may be repeated`, func(flagValue string) error {
+		ps, err := portfolio.LoadFile(flagValue)
+		if err != nil {
+			return err
+		}
+		for _, p := range ps {
+			if p.Name == "" {
+				p.Name = string('A' + rune(len(portfolios)))
+			}
+			portfolios = append(portfolios, p)
+		}
+		return nil
+	})
+	flag.Func("strategy", `adjust the weights of a portfolio with a rule, "name=rule", e.g. "A=trend(10, CASH)" or "B=voltarget(12, 6, CASH)"`, func(flagValue string) error {
+		i := strings.Index(flagValue, "=")
+		if i == -1 {
+			return fmt.Errorf(`got %q, want "<portfolio>=<rule>"`, flagValue)
+		}
+		s, err := portfolio.ParseStrategy(flagValue[i+1:])
+		if err != nil {
+			return err
+		}
+		strategies[flagValue[:i]] = s
+		return nil
+	})
+	flag.Func("derive", `add a derived series "name=func(args)", e.g. "2X WORLD=leverage(WORLD, 2, 0.6)"`, derived.FlagFunc())
+	flag.Parse()
+	rand.Seed(time.Now().UnixNano())
+
+	if len(portfolios) < 2 {
+		log.Fatal("specify two or more portfolios with -p or -portfolio")
+	}
+	pcts, err := output.ParsePercentiles(*percentiles)
+	if err != nil {
+		log.Fatalf("-percentiles: %v", err)
+	}
+	for name, s := range strategies {
+		found := false
+		for i := range portfolios {
+			if portfolios[i].Name == name {
+				portfolios[i].Strategy = s
+				found = true
+			}
+		}
+		if !found {
+			log.Fatalf("-strategy: no portfolio named %q", name)
+		}
+	}
+
+	hist, err := timeseries.LoadFile(*input, *validate)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := derived.Apply(hist); err != nil {
+		log.Fatalf("-derive: %v", err)
+	}
+
+	for _, p := range portfolios {
+		if err := p.Validate(hist); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	hist, err = timeseries.Align(hist, portfolio.Names(portfolios...)...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	results, err := simulate(hist)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("=== Portfolios ===")
+	for _, p := range portfolios {
+		fmt.Printf("%s: %s\n", p.Name, p)
+	}
+
+	for _, m := range timeseries.Metrics {
+		fmt.Println()
+		printMetric(m, pcts, results)
+	}
+
+	fmt.Println()
+	printWinRates(results)
+
+	if *chartFile != "" {
+		var points []chart.Point
+		for i, p := range portfolios {
+			points = append(points, chart.Point{
+				Label:      p.Name,
+				Volatility: timeseries.NewDistribution(timeseries.Volatility, results[i]).Percentile(50),
+				Returns:    timeseries.NewDistribution(timeseries.Returns, results[i]).Percentile(50),
+			})
+		}
+		if err := chart.Frontier("Median Risk and Return", points).WriteFile(*chartFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// simulate generates one set of scenarios and evaluates every portfolio on
+// it. results[i][j] is the result of portfolio i in scenario j.
+func simulate(hist map[string]timeseries.Data) ([][]timeseries.Data, error) {
+	names := portfolio.Names(portfolios...)
+	results := make([][]timeseries.Data, len(portfolios))
+
+	for i := 0; i < *iterations; i++ {
+		scenario, err := timeseries.Generate(names, &timeseries.MonteCarlo{
+			Data: hist,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Generate: %w", err)
+		}
+
+		for j, p := range portfolios {
+			res, err := p.Eval(&timeseries.Backtest{
+				Data: scenario,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("Eval(%q): %w", p.Name, err)
+			}
+
+			results[j] = append(results[j], res)
+		}
+	}
+
+	return results, nil
+}
+
+func printMetric(m timeseries.Metric, pcts []float64, results [][]timeseries.Data) {
+	var dists []timeseries.Distribution
+	for _, res := range results {
+		dists = append(dists, timeseries.NewDistribution(m, res))
+	}
+
+	fmt.Printf("=== %s ===\n", m.Name)
+	fmt.Printf("%-6s", "")
+	for _, p := range portfolios {
+		fmt.Printf(" %*s", columnWidth(p), p.Name)
+	}
+	fmt.Println()
+
+	for _, pct := range pcts {
+		fmt.Printf("%-6s", fmt.Sprintf("[P%g]", pct))
+		for i, d := range dists {
+			fmt.Printf(" %*s", columnWidth(portfolios[i]), formatValue(m, d.Percentile(pct)))
+		}
+		fmt.Println()
+	}
+}
+
+// printWinRates prints, for each pair of portfolios, the share of scenarios in
+// which the portfolio in the row ends up with more wealth than the portfolio
+// in the column.
+func printWinRates(results [][]timeseries.Data) {
+	fmt.Println("=== Win Rates ===")
+
+	nameWidth := 0
+	for _, p := range portfolios {
+		if len(p.Name) > nameWidth {
+			nameWidth = len(p.Name)
+		}
+	}
+
+	fmt.Printf("%-*s", nameWidth, "")
+	for _, p := range portfolios {
+		fmt.Printf(" %*s", columnWidth(p), p.Name)
+	}
+	fmt.Println()
+
+	for i, p := range portfolios {
+		fmt.Printf("%-*s", nameWidth, p.Name)
+		for j := range portfolios {
+			if i == j {
+				fmt.Printf(" %*s", columnWidth(portfolios[j]), "-")
+				continue
+			}
+
+			var wins int
+			for k := range results[i] {
+				if results[i][k].Growth() > results[j][k].Growth() {
+					wins++
+				}
+			}
+			fmt.Printf(" %*.1f%%", columnWidth(portfolios[j])-1, 100*float64(wins)/float64(len(results[i])))
+		}
+		fmt.Println()
+	}
+}
+
+func columnWidth(p portfolio.Portfolio) int {
+	if len(p.Name) < 8 {
+		return 8
+	}
+	return len(p.Name)
+}
+
+func formatValue(m timeseries.Metric, v float64) string {
+	if m.Percent {
+		return fmt.Sprintf("%.1f%%", v)
+	}
+	return fmt.Sprintf("%.2f", v)
+}
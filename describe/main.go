@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/octo/portfolio-mcmc/chart"
+	"github.com/octo/portfolio-mcmc/portfolio"
+	"github.com/octo/portfolio-mcmc/timeseries"
+)
+
+var (
+	input     = flag.String("input", "history.csv", "file containing historic returns")
+	validate  = flag.Bool("validate", false, "validate the input file before describing it")
+	format    = flag.String("format", "table", `output format, one of "table", "csv" and "json"`)
+	chartFile = flag.String("chart", "", "write a chart to this file; the format is determined by the extension (.svg or .png)")
+	chartType = flag.String("chart-type", "correlation", `type of chart, one of "correlation" and "risk-return"`)
+
+	pf      = portfolio.Portfolio{}
+	derived timeseries.Derivations
+)
+
+// Series holds the statistics of a single time series. Returns, volatility,
+// best and worst month and drawdown are in percent. Synthetic is the number of
+// backfilled months.
+type Series struct {
+	Name            string  `json:"name"`
+	First           string  `json:"first"`
+	Last            string  `json:"last"`
+	Months          int     `json:"months"`
+	Synthetic       int     `json:"synthetic"`
+	Returns         float64 `json:"returns"`
+	Volatility      float64 `json:"volatility"`
+	SharpeRatio     float64 `json:"sharpe_ratio"`
+	BestMonth       float64 `json:"best_month"`
+	WorstMonth      float64 `json:"worst_month"`
+	MaxDrawdown     float64 `json:"max_drawdown"`
+	Skewness        float64 `json:"skewness"`
+	Kurtosis        float64 `json:"kurtosis"`
+	Autocorrelation float64 `json:"autocorrelation"`
+}
+
+// Matrix is a symmetric matrix with one row and column per series.
+type Matrix struct {
+	Names  []string    `json:"names"`
+	Values [][]float64 `json:"values"`
+}
+
+// Description is the output of the describe command. The covariance is
+// annualized, i.e. the covariance of monthly returns times 12.
+type Description struct {
+	Series      []Series `json:"series"`
+	Correlation Matrix   `json:"correlation"`
+	Covariance  Matrix   `json:"covariance"`
+}
+
+func main() {
+	flag.Func("portfolio", `only describe the positions of the portfolio in this definition file, "file.json[:name]"`, pf.FileFlagFunc())
+	flag.Func("derive", `add a derived series "name=func(args)", e.g. "2X WORLD=leverage(WORLD, 2, 0.6)"`, derived.FlagFunc())
+	flag.Parse()
+
+	hist, err := timeseries.LoadFile(*input, *validate)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := derived.Apply(hist); err != nil {
+		log.Fatalf("-derive: %v", err)
+	}
+
+	if len(pf.Positions) != 0 {
+		if err := pf.Validate(hist); err != nil {
+			log.Fatal(err)
+		}
+		selected := map[string]timeseries.Data{}
+		for _, name := range portfolio.Names(pf) {
+			selected[name] = hist[name]
+		}
+		hist = selected
+	}
+
+	desc := describe(hist)
+
+	switch *format {
+	case "table":
+		printTable(os.Stdout, desc)
+	case "csv":
+		err = writeCSV(os.Stdout, desc)
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(desc)
+	default:
+		log.Fatalf("unknown format %q", *format)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *chartFile != "" {
+		if err := writeChart(desc); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+func writeChart(desc Description) error {
+	var c *chart.Chart
+	switch *chartType {
+	case "correlation":
+		c = chart.Heatmap("Correlation", desc.Correlation.Names, desc.Correlation.Values)
+	case "risk-return":
+		var points []chart.Point
+		for _, s := range desc.Series {
+			points = append(points, chart.Point{
+				Label:      s.Name,
+				Volatility: s.Volatility,
+				Returns:    s.Returns,
+			})
+		}
+		c = chart.Frontier("Risk and Return", points)
+	default:
+		return fmt.Errorf("unknown chart type %q", *chartType)
+	}
+
+	return c.WriteFile(*chartFile)
+}
+
+func describe(hist map[string]timeseries.Data) Description {
+	var names []string
+	for name := range hist {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var desc Description
+	for _, name := range names {
+		h := hist[name]
+		if len(h.Data) == 0 {
+			continue
+		}
+
+		desc.Series = append(desc.Series, Series{
+			Name:            name,
+			First:           h.Data[0].Date.Format("2006-01"),
+			Last:            h.Data[len(h.Data)-1].Date.Format("2006-01"),
+			Months:          len(h.Data),
+			Synthetic:       h.Synthetic(),
+			Returns:         h.Returns(),
+			Volatility:      h.Volatility(),
+			SharpeRatio:     h.SharpeRatio(),
+			BestMonth:       100 * h.Max(),
+			WorstMonth:      100 * h.Min(),
+			MaxDrawdown:     h.MaxDrawdown(),
+			Skewness:        h.Skewness(),
+			Kurtosis:        h.Kurtosis(),
+			Autocorrelation: h.Autocorrelation(1),
+		})
+	}
+
+	desc.Correlation.Names = names
+	desc.Covariance.Names = names
+	for _, a := range names {
+		var corr, cov []float64
+		for _, b := range names {
+			corr = append(corr, timeseries.Correlation(hist[a], hist[b]))
+			cov = append(cov, 12*timeseries.Covariance(hist[a], hist[b]))
+		}
+		desc.Correlation.Values = append(desc.Correlation.Values, corr)
+		desc.Covariance.Values = append(desc.Covariance.Values, cov)
+	}
+
+	return desc
+}
+
+func printTable(w io.Writer, desc Description) {
+	fmt.Fprintln(w, "=== Series ===")
+	fmt.Fprintf(w, "%-32s %-7s   %-7s %5s %7s %7s %6s %7s %7s %7s %6s %6s %6s\n",
+		"name", "from", "to", "synth", "returns", "vola", "sharpe", "best", "worst", "max dd", "skew", "kurt", "ac(1)")
+	for _, s := range desc.Series {
+		fmt.Fprintf(w, "%-32s %s – %s %5d %6.1f%% %6.1f%% %6.2f %6.1f%% %6.1f%% %6.1f%% %6.2f %6.2f %6.2f\n",
+			s.Name, s.First, s.Last, s.Synthetic, s.Returns, s.Volatility, s.SharpeRatio,
+			s.BestMonth, s.WorstMonth, s.MaxDrawdown, s.Skewness, s.Kurtosis, s.Autocorrelation)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "=== Correlation ===")
+	printMatrix(w, desc.Correlation, "%6.2f")
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "=== Covariance (annualized) ===")
+	printMatrix(w, desc.Covariance, "%6.3f")
+}
+
+func printMatrix(w io.Writer, m Matrix, valueFormat string) {
+	fmt.Fprintf(w, "%-36s", "")
+	for i := range m.Names {
+		fmt.Fprintf(w, " %6s", fmt.Sprintf("[%d]", i+1))
+	}
+	fmt.Fprintln(w)
+
+	for i, name := range m.Names {
+		fmt.Fprintf(w, "%-36s", fmt.Sprintf("[%d] %s", i+1, name))
+		for _, v := range m.Values[i] {
+			fmt.Fprintf(w, " "+valueFormat, v)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// writeCSV writes the series statistics, the correlation matrix and the
+// covariance matrix as three CSV tables, separated by empty lines.
+func writeCSV(w io.Writer, desc Description) error {
+	cw := csv.NewWriter(w)
+
+	cw.Write([]string{"name", "first", "last", "months", "synthetic", "returns", "volatility", "sharpe_ratio",
+		"best_month", "worst_month", "max_drawdown", "skewness", "kurtosis", "autocorrelation"})
+	for _, s := range desc.Series {
+		cw.Write([]string{s.Name, s.First, s.Last, strconv.Itoa(s.Months), strconv.Itoa(s.Synthetic),
+			formatFloat(s.Returns), formatFloat(s.Volatility), formatFloat(s.SharpeRatio),
+			formatFloat(s.BestMonth), formatFloat(s.WorstMonth), formatFloat(s.MaxDrawdown),
+			formatFloat(s.Skewness), formatFloat(s.Kurtosis), formatFloat(s.Autocorrelation)})
+	}
+
+	for _, m := range []struct {
+		name string
+		Matrix
+	}{
+		{"correlation", desc.Correlation},
+		{"covariance", desc.Covariance},
+	} {
+		cw.Flush()
+		fmt.Fprintln(w)
+
+		cw.Write(append([]string{m.name}, m.Names...))
+		for i, name := range m.Names {
+			record := []string{name}
+			for _, v := range m.Values[i] {
+				record = append(record, formatFloat(v))
+			}
+			cw.Write(record)
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
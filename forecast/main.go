@@ -1,40 +1,100 @@
 package main
 
 import (
+	"encoding/csv"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"math/rand"
 	"os"
 	"sort"
+	"strconv"
 	"time"
 
+	"github.com/octo/portfolio-mcmc/chart"
+	"github.com/octo/portfolio-mcmc/output"
 	"github.com/octo/portfolio-mcmc/portfolio"
 	"github.com/octo/portfolio-mcmc/timeseries"
 )
 
-const iterations = 10000
-
 var (
-	input = flag.String("input", "history.csv", "file containing historic returns")
+	input       = flag.String("input", "history.csv", "file containing historic returns")
+	validate    = flag.Bool("validate", false, "validate the input file before simulating")
+	iterations  = flag.Int("iterations", 10000, "number of simulated paths")
+	percentiles = flag.String("percentiles", "50,80,90,95,99", "comma separated list of percentiles to report")
+	confidence  = flag.Float64("confidence", 95, "confidence level of the reported intervals [%]")
+	bands       = flag.String("bands", "5,25,50,75,95", "comma separated list of percentiles for the fan chart")
+	fanCSV      = flag.String("fan-csv", "", "write the Monte Carlo wealth percentiles for each month to this CSV file")
+	fanSVG      = flag.String("fan-svg", "", "write a fan chart of the Monte Carlo wealth percentiles to this SVG file")
+	chartFile   = flag.String("chart", "", "write a chart of the Monte Carlo results to this file; the format is determined by the extension (.svg or .png)")
+	chartType   = flag.String("chart-type", "fan", `type of chart, one of "fan" and "histogram"`)
+	format      = flag.String("format", output.Text, `output format, one of "text", "json", "csv" and "ndjson"`)
+	taxModel    = flag.Bool("tax", false, "apply the German tax model and report after-tax returns and wealth")
+	allowance   = flag.Float64("tax-allowance", portfolio.DefaultTax.Allowance, "annual tax allowance (Sparerpauschbetrag)")
+	baseRate    = flag.Float64("tax-base-rate", portfolio.DefaultTax.BaseRate, "base rate for the Vorabpauschale [%]")
+	fundType    = flag.String("fund-type", portfolio.Equity, "fund type of positions without one; determines the partial tax exemption")
+	cashRate    = flag.Float64("cash-rate", 0, `annual interest rate of the "CASH" position [%]`)
+	cashRates   = flag.String("cash-series", "", `series of monthly returns used as the interest rate of the "CASH" position`)
+	spread      = flag.Float64("borrow-spread", 0, `annual interest paid on top of the cash rate if "CASH" is negative [%]`)
+	margin      = flag.Float64("maintenance", 0, "sell assets when the portfolio's value falls below this share of its assets [%]; 0 disables margin calls")
+	age         = flag.Float64("age", 0, "age at the start of the simulation, for -glide steps given by age")
 
-	pf = portfolio.Portfolio{}
+	pf      = portfolio.Portfolio{}
+	bench   = portfolio.Portfolio{}
+	glide   portfolio.GlidePath
+	rules   portfolio.Strategy
+	derived timeseries.Derivations
 )
 
 func main() {
-	flag.Func("pos", `position as "name:weight"`, pf.FlagFunc())
+	flag.Func("pos", `position as "name:weight[:ter]"; ter is the annual expense ratio [%]`, pf.FlagFunc())
+	flag.Func("portfolio", `load the portfolio from a definition file, "file.json[:name]"`, pf.FileFlagFunc())
+	flag.Func("benchmark", `benchmark position as "name:weight[:ter]"; evaluated on the same Monte Carlo paths`, bench.FlagFunc())
+	flag.Func("glide", `glide path step "<age or YYYY-MM>=name:weight[,name:weight]..."; the weights are interpolated between steps`, glide.FlagFunc())
+	flag.Func("strategy", `adjust the weights with a rule, e.g. "trend(10, CASH)", "momentum(12, CASH, WORLD, EMERGING MARKETS)" or "voltarget(12, 6, CASH)"`, func(flagValue string) (err error) {
+		rules, err = portfolio.ParseStrategy(flagValue)
+		return err
+	})
+	flag.Func("derive", `add a derived series "name=func(args)", e.g. "2X WORLD=leverage(WORLD, 2, 0.6)"`, derived.FlagFunc())
 	flag.Parse()
 	rand.Seed(time.Now().UnixNano())
 
-	f, err := os.Open(*input)
+	if err := output.CheckFormat(*format); err != nil {
+		log.Fatal(err)
+	}
+	if err := portfolio.CheckFundType(*fundType); err != nil {
+		log.Fatalf("-fund-type: %v", err)
+	}
+	applyTax(&pf)
+	applyTax(&bench)
+	applyCash(&pf)
+	applyCash(&bench)
+	if err := applyStrategy(&pf); err != nil {
+		log.Fatal(err)
+	}
+
+	pcts, err := output.ParsePercentiles(*percentiles)
 	if err != nil {
-		log.Fatalf("os.Open(%q): %v", *input, err)
+		log.Fatalf("-percentiles: %v", err)
+	}
+	bandPcts, err := output.ParsePercentiles(*bands)
+	if err != nil {
+		log.Fatalf("-bands: %v", err)
 	}
-	defer f.Close()
 
-	hist, err := timeseries.Load(f)
+	hist, err := timeseries.LoadFile(*input, *validate)
 	if err != nil {
-		log.Fatalf("timeseries.Load(): %v", err)
+		log.Fatal(err)
+	}
+	if err := derived.Apply(hist); err != nil {
+		log.Fatalf("-derive: %v", err)
+	}
+
+	for _, p := range []portfolio.Portfolio{pf, bench} {
+		if err := p.Validate(hist); err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	if len(pf.Positions) == 0 {
@@ -44,7 +104,7 @@ func main() {
 		}
 		sort.Strings(names)
 
-		fmt.Println("ERROR: specify one or more -pos arguments.")
+		fmt.Println("ERROR: specify -portfolio or one or more -pos arguments.")
 		fmt.Println()
 		fmt.Println("Available time series:")
 		fmt.Println()
@@ -55,65 +115,348 @@ func main() {
 		return
 	}
 
-	fmt.Println(pf)
+	hist, err = timeseries.Align(hist, portfolio.Names(pf, bench)...)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	fmt.Println()
-	fmt.Println("=== Monte Carlo ===")
-	var results []timeseries.Data
-	for i := 0; i < iterations; i++ {
-		res, err := pf.Eval(&timeseries.MonteCarlo{
+	var (
+		result = Result{
+			Parameters: Parameters{
+				Input:       *input,
+				Iterations:  *iterations,
+				Percentiles: pcts,
+				Confidence:  *confidence,
+			},
+			Portfolio: output.NewPortfolio(pf),
+		}
+		paths        *output.NDJSONWriter
+		results      []timeseries.Data
+		values       []timeseries.Data
+		relative     []timeseries.Relative
+		outperformed int
+		evaluations  []portfolio.Evaluation
+		names        = portfolio.Names(pf, bench)
+	)
+	if len(bench.Positions) != 0 {
+		b := output.NewPortfolio(bench)
+		result.Benchmark = &b
+	}
+	if *format == output.NDJSON {
+		// Stream the result of every path while simulating.
+		paths = output.NewNDJSONWriter(os.Stdout)
+		if err := writeNDJSONHeader(paths, result); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	for i := 0; i < *iterations; i++ {
+		// Generate the scenario first so that the benchmark can be
+		// evaluated on the same path.
+		scenario, err := timeseries.Generate(names, &timeseries.MonteCarlo{
 			Data: hist,
 		})
+		if err != nil {
+			log.Fatal("Generate: ", err)
+		}
+
+		ev, err := pf.Evaluate(&timeseries.Backtest{
+			Data: scenario,
+		})
 		if err != nil {
 			log.Fatal("Eval: ", err)
 		}
 
+		res := ev.Returns
 		results = append(results, res)
+		values = append(values, ev.Values)
+		evaluations = append(evaluations, ev)
+		if paths != nil {
+			if err := writePath(paths, monteCarlo, i, res); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		if len(bench.Positions) == 0 {
+			continue
+		}
+
+		benchRes, err := bench.Eval(&timeseries.Backtest{
+			Data: scenario,
+		})
+		if err != nil {
+			log.Fatal("Eval: ", err)
+		}
+
+		relative = append(relative, res.RelativeTo(benchRes))
+		if res.Growth() > benchRes.Growth() {
+			outperformed++
+		}
 	}
 
-	sort.Sort(timeseries.BySharpeRatio(results))
+	result.MonteCarlo.Percentiles = metricPercentiles(pcts, results)
+	if pf.Tax != nil {
+		result.MonteCarlo.Percentiles = append(result.MonteCarlo.Percentiles, afterTaxPercentiles(pcts, evaluations)...)
+	}
+	if pf.CashFlow.Months != 0 {
+		result.MonteCarlo.Percentiles = append(result.MonteCarlo.Percentiles, terminalPercentiles(pcts, evaluations)...)
+	}
+	if len(bench.Positions) != 0 {
+		result.MonteCarlo.Benchmark = &Relative{
+			Median:       output.NewRelative(medianRelative(relative)),
+			Outperformed: 100 * float64(outperformed) / float64(len(relative)),
+		}
+	}
 
-	printResult(50, results)
-	printResult(80, results)
-	printResult(90, results)
-	printResult(95, results)
-	printResult(99, results)
+	if *fanCSV != "" || *fanSVG != "" {
+		wb := timeseries.ValueBands(values, bandPcts)
+		if *fanCSV != "" {
+			if err := writeBandsCSV(*fanCSV, wb); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if *fanSVG != "" {
+			if err := chart.Fan("Wealth (Monte Carlo)", wb).WriteFile(*fanSVG); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
 
-	fmt.Println()
-	fmt.Println("=== Markov Chain ===")
+	if *chartFile != "" {
+		if err := writeChart(results, values, bandPcts); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	data, err := pf.Eval(&timeseries.Backtest{
+	// data is net of TER, tracking difference and interest on loans and
+	// follows the strategy already, but is gross of taxes.
+	data, err := pf.WithoutTax().Eval(&timeseries.Backtest{
 		Data: hist,
 	})
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	results = nil
-	for i := 0; i < iterations; i++ {
-		res, err := pf.Eval(timeseries.NewMarkovChain(data))
+	results, evaluations = nil, nil
+	for i := 0; i < *iterations; i++ {
+		ev, err := pf.WithoutFundCosts().WithoutCash().WithoutStrategy().Evaluate(timeseries.NewMarkovChain(data))
 		if err != nil {
 			log.Fatal("Eval: ", err)
 		}
 
+		res := ev.Returns
 		results = append(results, res)
+		evaluations = append(evaluations, ev)
+		if paths != nil {
+			if err := writePath(paths, markovChain, i, res); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	result.MarkovChain.Percentiles = metricPercentiles(pcts, results)
+	if pf.Tax != nil {
+		result.MarkovChain.Percentiles = append(result.MarkovChain.Percentiles, afterTaxPercentiles(pcts, evaluations)...)
+	}
+	if pf.CashFlow.Months != 0 {
+		result.MarkovChain.Percentiles = append(result.MarkovChain.Percentiles, terminalPercentiles(pcts, evaluations)...)
+	}
+
+	switch *format {
+	case output.Text:
+		printText(result)
+	case output.JSON:
+		err = output.WriteJSON(os.Stdout, result)
+	case output.CSV:
+		err = writeCSV(os.Stdout, result)
+	case output.NDJSON:
+		err = writeNDJSONResults(paths, result)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// metricPercentiles calculates the requested percentiles of each metric. The
+// percentiles are determined independently for each metric, i.e. they may
+// stem from different paths.
+func metricPercentiles(pcts []float64, results []timeseries.Data) []output.Percentile {
+	var ret []output.Percentile
+	for _, m := range timeseries.Metrics {
+		d := timeseries.NewDistribution(m, results)
+		ret = append(ret, output.Percentiles(d, pcts, *confidence/100)...)
+	}
+	return ret
+}
+
+// After-tax metrics assume that the portfolio is sold at the end of the path.
+var (
+	afterTaxReturns        = timeseries.Metric{Name: "after tax returns", Percent: true}
+	afterTaxGrowth         = timeseries.Metric{Name: "after tax growth"}
+	afterTaxTerminalWealth = timeseries.Metric{Name: "after tax terminal wealth"}
+)
+
+// terminalPercentiles calculates the requested percentiles of the terminal
+// wealth, which, unlike the growth, includes the cash flows. With the tax
+// model, the terminal wealth after the liquidation tax is added.
+func terminalPercentiles(pcts []float64, evaluations []portfolio.Evaluation) []output.Percentile {
+	ret := output.Percentiles(portfolio.TerminalWealthDistribution(evaluations), pcts, *confidence/100)
+	if pf.Tax == nil {
+		return ret
+	}
+
+	afterTax := timeseries.Distribution{Metric: afterTaxTerminalWealth}
+	for _, ev := range evaluations {
+		if n := len(ev.Values.Data); n > 0 {
+			afterTax.Values = append(afterTax.Values, ev.Values.Data[n-1].Value-ev.LiquidationTax)
+		}
+	}
+	sort.Float64s(afterTax.Values)
+	return append(ret, output.Percentiles(afterTax, pcts, *confidence/100)...)
+}
+
+// afterTaxPercentiles calculates the requested percentiles of the after-tax
+// returns and growth.
+func afterTaxPercentiles(pcts []float64, evaluations []portfolio.Evaluation) []output.Percentile {
+	returns := timeseries.Distribution{Metric: afterTaxReturns}
+	growth := timeseries.Distribution{Metric: afterTaxGrowth}
+	for _, ev := range evaluations {
+		returns.Values = append(returns.Values, ev.AfterTaxReturns())
+		growth.Values = append(growth.Values, ev.AfterTaxGrowth())
+	}
+	sort.Float64s(returns.Values)
+	sort.Float64s(growth.Values)
+
+	return append(output.Percentiles(returns, pcts, *confidence/100),
+		output.Percentiles(growth, pcts, *confidence/100)...)
+}
+
+// applyStrategy sets the strategy of p from the command line flags, if given.
+func applyStrategy(p *portfolio.Portfolio) error {
+	if len(glide.Steps) != 0 && rules != nil {
+		return errors.New("specify either -glide or -strategy")
+	}
+	if len(glide.Steps) != 0 {
+		glide.Age = *age
+		p.Strategy = glide
+	}
+	if rules != nil {
+		p.Strategy = rules
+	}
+	return nil
+}
+
+// applyCash sets the interest and borrowing costs of p's cash position from
+// the command line flags, if given.
+func applyCash(p *portfolio.Portfolio) {
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "cash-rate":
+			p.Interest.Rate = *cashRate
+		case "cash-series":
+			p.Interest.Series = *cashRates
+		case "borrow-spread":
+			p.Borrowing.Spread = *spread
+		case "maintenance":
+			p.Borrowing.Maintenance = *margin
+		}
+	})
+}
+
+// applyTax sets the tax model of p if -tax is given and defaults the fund
+// type of its positions.
+func applyTax(p *portfolio.Portfolio) {
+	if *taxModel {
+		t := portfolio.DefaultTax
+		t.Allowance = *allowance
+		t.BaseRate = *baseRate
+		p.Tax = &t
+	}
+	if p.Tax == nil {
+		return
+	}
+	for i := range p.Positions {
+		if p.Positions[i].FundType == "" {
+			p.Positions[i].FundType = *fundType
+		}
+	}
+}
+
+func initialValue() float64 {
+	var ret float64
+	for _, pos := range pf.Positions {
+		ret += pos.Value
 	}
+	return ret
+}
 
-	sort.Sort(timeseries.BySharpeRatio(results))
+// writeChart plots the Monte Carlo results. The fan chart shows the values,
+// which include cash flows.
+func writeChart(results, values []timeseries.Data, bandPcts []float64) error {
+	var c *chart.Chart
+	switch *chartType {
+	case "fan":
+		c = chart.Fan("Wealth (Monte Carlo)", timeseries.ValueBands(values, bandPcts))
+	case "histogram":
+		var values []float64
+		for _, res := range results {
+			values = append(values, res.Returns())
+		}
+		c = chart.Histogram("Annualized Returns (Monte Carlo)", values, 50, chart.Percent)
+	default:
+		return fmt.Errorf("unknown chart type %q", *chartType)
+	}
 
-	printResult(50, results)
-	printResult(80, results)
-	printResult(90, results)
-	printResult(95, results)
-	printResult(99, results)
+	return c.WriteFile(*chartFile)
 }
 
-func printResult(p int, results []timeseries.Data) {
-	idx := len(results) * (100 - p) / 100
+// writeBandsCSV writes one row per month and one column per band.
+func writeBandsCSV(path string, bands []timeseries.Data) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	header := []string{"date"}
+	for _, b := range bands {
+		header = append(header, b.Name)
+	}
+	w.Write(header)
 
-	fmt.Printf("[P%d] returns: %.1f%%; volatility: %.1f%%; sharpe ratio: %.2f\n",
-		p,
-		results[idx].Returns(),
-		results[idx].Volatility(),
-		results[idx].SharpeRatio())
+	for i, d := range bands[0].Data {
+		record := []string{d.Date.Format("2006-01-02")}
+		for _, b := range bands {
+			record = append(record, strconv.FormatFloat(b.Data[i].Value, 'f', 2, 64))
+		}
+		w.Write(record)
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// medianRelative returns the median of each statistic. The medians are
+// determined independently, i.e. they may stem from different paths.
+func medianRelative(relative []timeseries.Relative) timeseries.Relative {
+	median := func(f func(r timeseries.Relative) float64) float64 {
+		var values []float64
+		for _, r := range relative {
+			values = append(values, f(r))
+		}
+		sort.Float64s(values)
+		return values[len(values)/2]
+	}
+
+	return timeseries.Relative{
+		Beta:             median(func(r timeseries.Relative) float64 { return r.Beta }),
+		Alpha:            median(func(r timeseries.Relative) float64 { return r.Alpha }),
+		TrackingError:    median(func(r timeseries.Relative) float64 { return r.TrackingError }),
+		InformationRatio: median(func(r timeseries.Relative) float64 { return r.InformationRatio }),
+		UpCapture:        median(func(r timeseries.Relative) float64 { return r.UpCapture }),
+		DownCapture:      median(func(r timeseries.Relative) float64 { return r.DownCapture }),
+	}
 }
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/octo/portfolio-mcmc/output"
+	"github.com/octo/portfolio-mcmc/portfolio"
+	"github.com/octo/portfolio-mcmc/timeseries"
+)
+
+// Result is the output of the forecast command.
+type Result struct {
+	Parameters  Parameters        `json:"parameters"`
+	Portfolio   output.Portfolio  `json:"portfolio"`
+	Benchmark   *output.Portfolio `json:"benchmark,omitempty"`
+	MonteCarlo  Simulation        `json:"monte_carlo"`
+	MarkovChain Simulation        `json:"markov_chain"`
+}
+
+// Parameters holds the command line flags that influence the result.
+// Confidence is the level of the confidence intervals in percent.
+type Parameters struct {
+	Input       string    `json:"input"`
+	Iterations  int       `json:"iterations"`
+	Percentiles []float64 `json:"percentiles"`
+	Confidence  float64   `json:"confidence"`
+}
+
+// Simulation holds the results of one simulation method. Benchmark is only
+// set for the Monte Carlo simulation and only if a benchmark is given.
+type Simulation struct {
+	Percentiles []output.Percentile `json:"percentiles"`
+	Benchmark   *Relative           `json:"benchmark,omitempty"`
+}
+
+// Relative holds the medians of the statistics relative to the benchmark and
+// the share of paths, in percent, in which the portfolio ended up with more
+// wealth than the benchmark.
+type Relative struct {
+	Median       output.Relative `json:"median"`
+	Outperformed float64         `json:"outperformed"`
+}
+
+// Names of the simulation methods in the CSV and NDJSON output.
+const (
+	monteCarlo  = "monte_carlo"
+	markovChain = "markov_chain"
+)
+
+func printText(r Result) {
+	fmt.Println(pf)
+
+	fmt.Println()
+	fmt.Println("=== Monte Carlo ===")
+	printPercentiles(r.MonteCarlo.Percentiles)
+
+	if b := r.MonteCarlo.Benchmark; b != nil {
+		fmt.Println()
+		fmt.Println("=== Benchmark (Monte Carlo) ===")
+		fmt.Println(bench)
+		fmt.Printf("[P50] beta: %.2f; alpha: %.1f%%; tracking error: %.1f%%; information ratio: %.2f; up capture: %.0f%%; down capture: %.0f%%\n",
+			b.Median.Beta, b.Median.Alpha, b.Median.TrackingError, b.Median.InformationRatio, b.Median.UpCapture, b.Median.DownCapture)
+		fmt.Printf("probability of outperforming the benchmark: %.1f%%\n", b.Outperformed)
+	}
+
+	fmt.Println()
+	fmt.Println("=== Markov Chain ===")
+	printPercentiles(r.MarkovChain.Percentiles)
+}
+
+// printPercentiles prints the percentiles grouped by metric. Without a cash
+// flow, growth is printed as terminal wealth; with one, the terminal wealth
+// is printed separately, because it includes the cash flows. After-tax
+// metrics are printed if the tax model is enabled.
+func printPercentiles(ps []output.Percentile) {
+	initial := initialValue()
+	cashFlow := pf.CashFlow.Months != 0
+
+	metrics := timeseries.Metrics
+	if pf.Tax != nil {
+		metrics = append(metrics[:len(metrics):len(metrics)], afterTaxReturns, afterTaxGrowth)
+	}
+	if cashFlow {
+		metrics = append(metrics[:len(metrics):len(metrics)], portfolio.TerminalWealth)
+		if pf.Tax != nil {
+			metrics = append(metrics, afterTaxTerminalWealth)
+		}
+	}
+
+	for i, m := range metrics {
+		if i != 0 {
+			fmt.Println()
+		}
+
+		name, scale, format := m.Name, 1.0, "%.2f"
+		switch {
+		case cashFlow && (m.Name == portfolio.TerminalWealth.Name || m.Name == afterTaxTerminalWealth.Name):
+			format = "%.0f"
+		case cashFlow:
+			if m.Percent {
+				format = "%.1f%%"
+			}
+		case m.Name == timeseries.Growth.Name:
+			name, scale, format = "terminal wealth", initial, "%.0f"
+		case m.Name == afterTaxGrowth.Name:
+			name, scale, format = "after tax terminal wealth", initial, "%.0f"
+		case m.Percent:
+			format = "%.1f%%"
+		}
+
+		fmt.Println(name)
+		for _, p := range ps {
+			if p.Metric != output.MetricName(m) {
+				continue
+			}
+			fmt.Printf("  %-6s %9s  (%g%% CI: %s – %s)\n",
+				fmt.Sprintf("[P%g]", p.Percentile),
+				fmt.Sprintf(format, scale*float64(p.Value)),
+				*confidence,
+				fmt.Sprintf(format, scale*float64(p.Lower)),
+				fmt.Sprintf(format, scale*float64(p.Upper)))
+		}
+	}
+}
+
+// writeCSV writes the result as a sequence of CSV tables: parameters,
+// portfolio composition, percentiles and, if a benchmark is given, relative
+// statistics.
+func writeCSV(w io.Writer, r Result) error {
+	cw := output.NewCSVWriter(w)
+
+	var pcts []string
+	for _, p := range r.Parameters.Percentiles {
+		pcts = append(pcts, output.Float(p))
+	}
+	cw.Table("parameter", "value")
+	cw.Write("input", r.Parameters.Input)
+	cw.Write("iterations", strconv.Itoa(r.Parameters.Iterations))
+	cw.Write("percentiles", strings.Join(pcts, ","))
+	cw.Write("confidence", output.Float(r.Parameters.Confidence))
+
+	cw.Table(output.PositionsHeader...)
+	cw.Positions("portfolio", r.Portfolio)
+	if r.Benchmark != nil {
+		cw.Positions("benchmark", *r.Benchmark)
+	}
+
+	cw.Table("method", "metric", "percentile", "value", "lower", "upper")
+	for _, s := range []struct {
+		method string
+		Simulation
+	}{
+		{monteCarlo, r.MonteCarlo},
+		{markovChain, r.MarkovChain},
+	} {
+		for _, p := range s.Percentiles {
+			cw.Write(s.method, p.Metric, output.Float(p.Percentile),
+				output.Float(float64(p.Value)), output.Float(float64(p.Lower)), output.Float(float64(p.Upper)))
+		}
+	}
+
+	if b := r.MonteCarlo.Benchmark; b != nil {
+		m := b.Median
+		cw.Table("method", "beta", "alpha", "tracking_error", "information_ratio", "up_capture", "down_capture", "outperformed")
+		cw.Write(monteCarlo, output.Float(float64(m.Beta)), output.Float(float64(m.Alpha)), output.Float(float64(m.TrackingError)),
+			output.Float(float64(m.InformationRatio)), output.Float(float64(m.UpCapture)), output.Float(float64(m.DownCapture)), output.Float(b.Outperformed))
+	}
+
+	return cw.Flush()
+}
+
+type composition struct {
+	Series string `json:"series"`
+	output.Portfolio
+}
+
+// writeNDJSONHeader writes the records that are known before simulating.
+func writeNDJSONHeader(enc *output.NDJSONWriter, r Result) error {
+	if err := enc.Write("parameters", r.Parameters); err != nil {
+		return err
+	}
+	if err := enc.Write("portfolio", composition{"portfolio", r.Portfolio}); err != nil {
+		return err
+	}
+	if r.Benchmark != nil {
+		return enc.Write("portfolio", composition{"benchmark", *r.Benchmark})
+	}
+	return nil
+}
+
+// writePath writes the metrics of a single simulated path.
+func writePath(enc *output.NDJSONWriter, method string, i int, res timeseries.Data) error {
+	return enc.Write("path", struct {
+		Method string `json:"method"`
+		Path   int    `json:"path"`
+		output.Metrics
+	}{method, i, output.NewMetrics(res)})
+}
+
+// writeNDJSONResults writes the records that are known after simulating.
+func writeNDJSONResults(enc *output.NDJSONWriter, r Result) error {
+	type percentile struct {
+		Method string `json:"method"`
+		output.Percentile
+	}
+
+	for _, s := range []struct {
+		method string
+		Simulation
+	}{
+		{monteCarlo, r.MonteCarlo},
+		{markovChain, r.MarkovChain},
+	} {
+		for _, p := range s.Percentiles {
+			if err := enc.Write("percentile", percentile{s.method, p}); err != nil {
+				return err
+			}
+		}
+	}
+
+	if b := r.MonteCarlo.Benchmark; b != nil {
+		return enc.Write("relative", struct {
+			Method string `json:"method"`
+			Relative
+		}{monteCarlo, *b})
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/octo/portfolio-mcmc/output"
+	"github.com/octo/portfolio-mcmc/portfolio"
+	"github.com/octo/portfolio-mcmc/timeseries"
+)
+
+// Parameters that can be solved for.
+const (
+	contribution = "contribution"
+	initial      = "initial"
+	horizon      = "horizon"
+	equityShare  = "equity-share"
+)
+
+var (
+	input        = flag.String("input", "history.csv", "file containing historic returns")
+	validate     = flag.Bool("validate", false, "validate the input file before simulating")
+	iterations   = flag.Int("iterations", 2000, "number of simulated paths")
+	solve        = flag.String("solve", contribution, `parameter to solve for, one of "contribution", "initial", "horizon" and "equity-share"`)
+	target       = flag.Float64("target", 0, "target wealth")
+	confidence   = flag.Float64("confidence", 90, "share of paths that have to reach the target [%]")
+	years        = flag.Int("years", 20, "horizon [years]")
+	maxYears     = flag.Int("max-years", 60, "longest horizon to consider when solving for the horizon [years]")
+	initialValue = flag.Float64("initial", 0, "initial wealth; defaults to the value of the portfolio")
+	monthly      = flag.Float64("contribution", 0, "monthly contribution; defaults to the cash flow of the portfolio")
+	equity       = flag.String("equity", "", `comma separated list of equity positions for -solve="equity-share"`)
+	percentiles  = flag.String("percentiles", "50,80,90,95,99", "comma separated list of percentiles of the terminal wealth to report")
+	format       = flag.String("format", output.Text, `output format, one of "text" and "json"`)
+
+	pf      = portfolio.Portfolio{}
+	derived timeseries.Derivations
+)
+
+// Result is the output of the goal command.
+type Result struct {
+	Parameters Parameters       `json:"parameters"`
+	Portfolio  output.Portfolio `json:"portfolio"`
+	Solution   Solution         `json:"solution"`
+	// Percentiles holds the terminal wealth at the solution.
+	Percentiles []output.Percentile `json:"percentiles"`
+}
+
+// Parameters holds the command line flags that influence the result.
+type Parameters struct {
+	Input      string  `json:"input"`
+	Iterations int     `json:"iterations"`
+	Solve      string  `json:"solve"`
+	Target     float64 `json:"target"`
+	Confidence float64 `json:"confidence"`
+}
+
+// Solution holds the solved parameter together with the other parameters of
+// the goal. Horizon is in months, EquityShare in percent.
+type Solution struct {
+	Initial      float64  `json:"initial"`
+	Contribution float64  `json:"contribution"`
+	Horizon      int      `json:"horizon"`
+	EquityShare  *float64 `json:"equity_share,omitempty"`
+}
+
+func main() {
+	flag.Func("pos", `position as "name:weight[:ter]"; ter is the annual expense ratio [%]`, pf.FlagFunc())
+	flag.Func("portfolio", `load the portfolio from a definition file, "file.json[:name]"`, pf.FileFlagFunc())
+	flag.Func("derive", `add a derived series "name=func(args)", e.g. "2X WORLD=leverage(WORLD, 2, 0.6)"`, derived.FlagFunc())
+	flag.Parse()
+	rand.Seed(time.Now().UnixNano())
+
+	if *format != output.Text && *format != output.JSON {
+		log.Fatalf("unknown format %q, want one of %q and %q", *format, output.Text, output.JSON)
+	}
+	switch *solve {
+	case contribution, initial, horizon, equityShare:
+	default:
+		log.Fatalf("-solve: unknown parameter %q, want one of %q, %q, %q and %q", *solve, contribution, initial, horizon, equityShare)
+	}
+	if *target <= 0 {
+		log.Fatal("-target: specify the target wealth")
+	}
+	var equityNames []string
+	if *solve == equityShare {
+		if *equity == "" {
+			log.Fatal("-equity: specify the equity positions")
+		}
+		for _, name := range strings.Split(*equity, ",") {
+			equityNames = append(equityNames, strings.TrimSpace(name))
+		}
+	}
+
+	pcts, err := output.ParsePercentiles(*percentiles)
+	if err != nil {
+		log.Fatalf("-percentiles: %v", err)
+	}
+
+	hist, err := timeseries.LoadFile(*input, *validate)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := derived.Apply(hist); err != nil {
+		log.Fatalf("-derive: %v", err)
+	}
+
+	if err := pf.Validate(hist); err != nil {
+		log.Fatal(err)
+	}
+
+	if len(pf.Positions) == 0 {
+		var names []string
+		for name := range hist {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Println("ERROR: specify -portfolio or one or more -pos arguments.")
+		fmt.Println()
+		fmt.Println("Available time series:")
+		fmt.Println()
+		for _, name := range names {
+			fmt.Println("  *", name)
+		}
+
+		return
+	}
+
+	hist, err = timeseries.Align(hist, portfolio.Names(pf)...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Only the terminal value is considered, not the tax due on selling.
+	pf = pf.WithoutTax()
+
+	set := map[string]bool{}
+	flag.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	if set["initial"] {
+		pf = pf.WithValue(*initialValue)
+	}
+	if set["contribution"] {
+		pf.CashFlow = portfolio.CashFlow{Amount: *monthly, Months: 1}
+	}
+
+	months := 12 * *years
+	if *solve == horizon {
+		months = 12 * *maxYears
+	}
+
+	// All candidates are evaluated on the same scenarios.
+	g := portfolio.Goal{
+		Target:     *target,
+		Confidence: *confidence,
+		Months:     12 * *years,
+	}
+	names := portfolio.Names(pf)
+	for i := 0; i < *iterations; i++ {
+		scenario, err := timeseries.Generate(names, &timeseries.MonteCarlo{
+			Data:   hist,
+			Months: months,
+		})
+		if err != nil {
+			log.Fatal("Generate: ", err)
+		}
+		g.Scenarios = append(g.Scenarios, scenario)
+	}
+
+	result := Result{
+		Parameters: Parameters{
+			Input:      *input,
+			Iterations: *iterations,
+			Solve:      *solve,
+			Target:     *target,
+			Confidence: *confidence,
+		},
+	}
+
+	switch *solve {
+	case contribution:
+		var amount float64
+		amount, err = g.Contribution(pf)
+		pf.CashFlow = portfolio.CashFlow{Amount: amount, Months: 1}
+	case initial:
+		var value float64
+		value, err = g.InitialValue(pf)
+		pf = pf.WithValue(value)
+	case horizon:
+		g.Months, err = g.Horizon(pf)
+	case equityShare:
+		var share float64
+		share, err = g.EquityShare(pf, equityNames)
+		if err == nil {
+			pf, err = pf.WithShare(equityNames, share)
+		}
+		result.Solution.EquityShare = &share
+	}
+	if errors.Is(err, portfolio.ErrUnreachable) {
+		log.Fatalf("%.0f cannot be reached with %g%% confidence by changing the %s", *target, *confidence, *solve)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	d, err := g.Distribution(pf)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	result.Portfolio = output.NewPortfolio(pf)
+	result.Solution.Initial = result.Portfolio.Value
+	if pf.CashFlow.Months > 0 {
+		result.Solution.Contribution = pf.CashFlow.Amount / float64(pf.CashFlow.Months)
+	}
+	result.Solution.Horizon = g.Months
+	result.Percentiles = output.Percentiles(d, pcts, 0.95)
+
+	switch *format {
+	case output.Text:
+		printText(result)
+	case output.JSON:
+		err = output.WriteJSON(os.Stdout, result)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func printText(r Result) {
+	fmt.Println(pf)
+	fmt.Printf("goal: %.0f with %g%% confidence\n", r.Parameters.Target, r.Parameters.Confidence)
+	fmt.Println()
+
+	s := r.Solution
+	for _, row := range []struct {
+		param, label, value string
+	}{
+		{initial, "initial wealth", fmt.Sprintf("%.2f", s.Initial)},
+		{contribution, "monthly contribution", fmt.Sprintf("%.2f", s.Contribution)},
+		{horizon, "horizon", fmt.Sprintf("%d years, %d months", s.Horizon/12, s.Horizon%12)},
+	} {
+		marker := " "
+		if row.param == r.Parameters.Solve {
+			marker = "*"
+		}
+		fmt.Printf("%s %-21s %s\n", marker, row.label+":", row.value)
+	}
+	if s.EquityShare != nil {
+		fmt.Printf("* %-21s %.0f%%\n", "equity share:", *s.EquityShare)
+	}
+
+	fmt.Println()
+	fmt.Println("terminal wealth")
+	for _, p := range r.Percentiles {
+		fmt.Printf("  %-6s %12.0f  (95%% CI: %.0f – %.0f)\n",
+			fmt.Sprintf("[P%g]", p.Percentile), p.Value, p.Lower, p.Upper)
+	}
+}
@@ -7,36 +7,80 @@ import (
 	"math/rand"
 	"os"
 	"sort"
-	"strings"
 	"time"
 
+	"github.com/octo/portfolio-mcmc/chart"
+	"github.com/octo/portfolio-mcmc/output"
 	"github.com/octo/portfolio-mcmc/portfolio"
 	"github.com/octo/portfolio-mcmc/timeseries"
 )
 
 var (
 	input          = flag.String("input", "history.csv", "file containing historic returns")
+	validate       = flag.Bool("validate", false, "validate the input file before simulating")
 	populationSize = flag.Int("size", 100, "population size")
 	iterations     = flag.Int("iterations", 2000, "number of iterations")
 	positions      = flagStringList("pos", "positions to consider")
+	format         = flag.String("format", output.Text, `output format, one of "text", "json", "csv" and "ndjson"`)
+	blocks         = flag.Int("blocks", 16, "number of blocks the history is split into to estimate the probability of backtest overfitting")
+	trainMonths    = flag.Int("walk-forward", 0, "walk-forward analysis: optimize on this many months of history, evaluate the best portfolio on the following -test months and roll forward through the history")
+	testMonths     = flag.Int("test", 12, "number of unseen months to evaluate each walk-forward portfolio on")
+	rollingWindow  = flag.Bool("rolling", false, "train on the last -walk-forward months only, rather than on all months before the test window")
+	chartFile      = flag.String("chart", "", "write a chart of the final population's historic volatility and returns to this file; the format is determined by the extension (.svg or .png)")
+
+	portfolios []portfolio.Portfolio
+	derived    timeseries.Derivations
 )
 
 func main() {
+	flag.Func("portfolio", `consider the positions of the portfolios in this definition file, "file.json[:name]"`, func(flagValue string) error {
+		ps, err := portfolio.LoadFile(flagValue)
+		if err != nil {
+			return err
+		}
+		portfolios = append(portfolios, ps...)
+		*positions = append(*positions, portfolio.Names(ps...)...)
+		return nil
+	})
+	flag.Func("derive", `add a derived series "name=func(args)", e.g. "2X WORLD=leverage(WORLD, 2, 0.6)"`, derived.FlagFunc())
 	flag.Parse()
 	rand.Seed(time.Now().UnixNano())
 
-	f, err := os.Open(*input)
-	if err != nil {
-		log.Fatalf("os.Open(%q): %v", *input, err)
+	if err := output.CheckFormat(*format); err != nil {
+		log.Fatal(err)
+	}
+	if *trainMonths < 0 {
+		log.Fatalf("-walk-forward: got %d, want a positive number of months", *trainMonths)
+	}
+	if *testMonths <= 0 {
+		log.Fatalf("-test: got %d, want a positive number of months", *testMonths)
+	}
+	if *populationSize < 2 {
+		log.Fatalf("-size: got %d, want at least 2", *populationSize)
+	}
+	if err := timeseries.CheckBlocks(*blocks); err != nil {
+		log.Fatalf("-blocks: %v", err)
 	}
-	defer f.Close()
 
-	hist, err := timeseries.Load(f)
+	hist, err := timeseries.LoadFile(*input, *validate)
 	if err != nil {
-		log.Fatalf("timeseries.Load(): %v", err)
+		log.Fatal(err)
+	}
+	if err := derived.Apply(hist); err != nil {
+		log.Fatalf("-derive: %v", err)
+	}
+
+	for _, p := range portfolios {
+		if err := p.Validate(hist); err != nil {
+			log.Fatal(err)
+		}
 	}
 
 	if len(*positions) != 0 {
+		if err := portfolio.CheckNames(hist, *positions...); err != nil {
+			log.Fatalf("-pos: %v", err)
+		}
+
 		keep := map[string]bool{}
 		for _, k := range *positions {
 			keep[k] = true
@@ -55,9 +99,77 @@ func main() {
 		}
 	}
 
-	if err := evolve(hist); err != nil {
+	hist, err = timeseries.Align(hist)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *trainMonths > 0 {
+		if *chartFile != "" {
+			log.Fatal("-chart is not supported with -walk-forward")
+		}
+		if err := writeWalkForward(*format, os.Stdout, hist); err != nil {
+			log.Fatal("walk-forward: ", err)
+		}
+		return
+	}
+
+	out, err := newWriter(*format, os.Stdout, hist)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	t := newTrials(hist)
+	pop, err := evolve(hist, func(k int, pop *Population) error {
+		if err := t.record(pop); err != nil {
+			return err
+		}
+		return out.iteration(k, pop)
+	})
+	if err != nil {
 		log.Fatal("evolve: ", err)
 	}
+
+	ov, err := t.overfitting(pop.Individuals[len(pop.Individuals)-1].Portfolio)
+	if err != nil {
+		log.Fatal(err)
+	}
+	out.result.Overfitting = &ov
+
+	if err := out.finish(); err != nil {
+		log.Fatal(err)
+	}
+
+	if *chartFile != "" {
+		if err := writeChart(pop, hist); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// writeChart plots the backtest results of the population's portfolios. The
+// best individual of the last iteration is labeled.
+func writeChart(pop *Population, hist map[string]timeseries.Data) error {
+	var points []chart.Point
+	for i, ind := range pop.Individuals {
+		h, err := ind.Portfolio.Eval(&timeseries.Backtest{
+			Data: hist,
+		})
+		if err != nil {
+			return fmt.Errorf("Portfolio.Eval: %w", err)
+		}
+
+		pt := chart.Point{
+			Volatility: h.Volatility(),
+			Returns:    h.Returns(),
+		}
+		if i == len(pop.Individuals)-1 {
+			pt.Label = "best"
+		}
+		points = append(points, pt)
+	}
+
+	return chart.Frontier("Population (Backtest)", points).WriteFile(*chartFile)
 }
 
 type Individual struct {
@@ -86,18 +198,30 @@ func (p *Population) Swap(i, j int) {
 	p.Individuals[i], p.Individuals[j] = p.Individuals[j], p.Individuals[i]
 }
 
-func evolve(hist map[string]timeseries.Data) error {
-	var names []string
-	for name := range hist {
-		names = append(names, name)
+// evolve runs the genetic algorithm. After evaluating the population in
+// each iteration, it calls report with the population sorted by increasing
+// Sharpe ratio.
+func evolve(hist map[string]timeseries.Data, report func(k int, pop *Population) error) (*Population, error) {
+	names := seriesNames(hist)
+
+	// Positions loaded with -portfolio keep their TER and tracking
+	// difference; Recombine passes them on to the children.
+	costs := map[string]portfolio.Position{}
+	for _, p := range portfolios {
+		for _, pos := range p.Positions {
+			costs[pos.Name] = pos
+		}
 	}
-	sort.Strings(names)
-	fmt.Println(strings.Join(names, ","))
 
 	pop := &Population{}
 	for i := 0; i < *populationSize; i++ {
+		p := portfolio.Random(names)
+		for j, pos := range p.Positions {
+			p.Positions[j].TER = costs[pos.Name].TER
+			p.Positions[j].TrackingDifference = costs[pos.Name].TrackingDifference
+		}
 		pop.Individuals = append(pop.Individuals, &Individual{
-			Portfolio: portfolio.Random(names),
+			Portfolio: p,
 		})
 	}
 
@@ -106,7 +230,7 @@ func evolve(hist map[string]timeseries.Data) error {
 			Data: hist,
 		})
 		if err != nil {
-			return fmt.Errorf("timeseries.Generate: %w", err)
+			return nil, fmt.Errorf("timeseries.Generate: %w", err)
 		}
 
 		for _, ind := range pop.Individuals {
@@ -114,7 +238,7 @@ func evolve(hist map[string]timeseries.Data) error {
 				Data: genHist,
 			})
 			if err != nil {
-				return fmt.Errorf("Portfolio.Eval: %w", err)
+				return nil, fmt.Errorf("Portfolio.Eval: %w", err)
 			}
 
 			ind.Returns = h.Returns()
@@ -124,7 +248,9 @@ func evolve(hist map[string]timeseries.Data) error {
 
 		sort.Sort(pop)
 
-		fmt.Println(pop.Individuals[len(pop.Individuals)-1])
+		if err := report(k, pop); err != nil {
+			return nil, err
+		}
 		// fmt.Println(pop.Individuals[len(pop.Individuals)-1].Portfolio.CSV())
 
 		// replace the worse half of the population.
@@ -138,7 +264,16 @@ func evolve(hist map[string]timeseries.Data) error {
 		}
 	}
 
-	return nil
+	return pop, nil
+}
+
+func seriesNames(hist map[string]timeseries.Data) []string {
+	var names []string
+	for name := range hist {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 func flagStringList(name, usage string) *[]string {
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/octo/portfolio-mcmc/output"
+	"github.com/octo/portfolio-mcmc/timeseries"
+)
+
+// Result is the output of the optimize-allocation command.
+type Result struct {
+	Parameters Parameters  `json:"parameters"`
+	Series     []string    `json:"series"`
+	Iterations []Iteration `json:"iterations"`
+	Population []Candidate `json:"population"`
+	// Overfitting is only set after the optimizer finishes.
+	Overfitting *Overfitting `json:"overfitting,omitempty"`
+}
+
+// Parameters holds the command line flags that influence the result.
+type Parameters struct {
+	Input      string   `json:"input"`
+	Size       int      `json:"size"`
+	Iterations int      `json:"iterations"`
+	Positions  []string `json:"positions"`
+	// WalkForward, Test and Rolling are only set for walk-forward analyses.
+	WalkForward int  `json:"walk_forward,omitempty"`
+	Test        int  `json:"test,omitempty"`
+	Rolling     bool `json:"rolling,omitempty"`
+}
+
+func newParameters() Parameters {
+	ret := Parameters{
+		Input:      *input,
+		Size:       *populationSize,
+		Iterations: *iterations,
+		Positions:  append([]string{}, *positions...),
+	}
+	if *trainMonths > 0 {
+		ret.WalkForward = *trainMonths
+		ret.Test = *testMonths
+		ret.Rolling = *rollingWindow
+	}
+	return ret
+}
+
+// writeParameters writes a table of the parameters.
+func writeParameters(csv *output.CSVWriter) {
+	p := newParameters()
+	csv.Table("parameter", "value")
+	csv.Write("input", p.Input)
+	csv.Write("size", strconv.Itoa(p.Size))
+	csv.Write("iterations", strconv.Itoa(p.Iterations))
+	csv.Write("positions", strings.Join(p.Positions, ","))
+	if p.WalkForward > 0 {
+		csv.Write("walk_forward", strconv.Itoa(p.WalkForward))
+		csv.Write("test", strconv.Itoa(p.Test))
+		csv.Write("rolling", strconv.FormatBool(p.Rolling))
+	}
+}
+
+// Iteration holds the best portfolio of an iteration.
+type Iteration struct {
+	Iteration int `json:"iteration"`
+	Candidate
+}
+
+// Candidate is a portfolio and its metrics on the Monte Carlo history of the
+// iteration it was evaluated in.
+type Candidate struct {
+	Portfolio   output.Portfolio `json:"portfolio"`
+	Returns     output.Number    `json:"returns"`
+	Volatility  output.Number    `json:"volatility"`
+	SharpeRatio output.Number    `json:"sharpe_ratio"`
+}
+
+func newCandidate(ind *Individual) Candidate {
+	return Candidate{
+		Portfolio:   output.NewPortfolio(ind.Portfolio),
+		Returns:     output.Number(ind.Returns),
+		Volatility:  output.Number(ind.Volatility),
+		SharpeRatio: output.Number(ind.SharpeRatio),
+	}
+}
+
+// writer writes the results in one of the output formats. The text, CSV and
+// NDJSON formats are written while the optimizer runs; JSON is written once
+// it finishes.
+type writer struct {
+	format string
+	w      io.Writer
+	result Result
+
+	csv    *output.CSVWriter
+	ndjson *output.NDJSONWriter
+}
+
+func newWriter(format string, w io.Writer, hist map[string]timeseries.Data) (*writer, error) {
+	ret := &writer{
+		format: format,
+		w:      w,
+		result: Result{
+			Parameters: newParameters(),
+			Series:     seriesNames(hist),
+		},
+	}
+
+	switch format {
+	case output.Text:
+		fmt.Fprintln(w, strings.Join(ret.result.Series, ","))
+	case output.CSV:
+		ret.csv = output.NewCSVWriter(w)
+		writeParameters(ret.csv)
+
+		ret.csv.Table(ret.header("iteration")...)
+		return ret, ret.csv.Flush()
+	case output.NDJSON:
+		ret.ndjson = output.NewNDJSONWriter(w)
+		if err := ret.ndjson.Write("parameters", ret.result.Parameters); err != nil {
+			return nil, err
+		}
+		return ret, ret.ndjson.Write("series", struct {
+			Series []string `json:"series"`
+		}{ret.result.Series})
+	}
+
+	return ret, nil
+}
+
+// header returns the CSV header of a table of candidates. The weight of each
+// series, in percent, is written to a column named after the series.
+func (w *writer) header(first string) []string {
+	return append([]string{first, "returns", "volatility", "sharpe_ratio"}, w.result.Series...)
+}
+
+func (w *writer) record(first string, c Candidate) []string {
+	ret := []string{first, output.Float(float64(c.Returns)), output.Float(float64(c.Volatility)), output.Float(float64(c.SharpeRatio))}
+	for _, name := range w.result.Series {
+		var weight float64
+		for _, pos := range c.Portfolio.Positions {
+			if pos.Name == name {
+				weight = pos.Weight
+			}
+		}
+		ret = append(ret, output.Float(weight))
+	}
+	return ret
+}
+
+// iteration is called by evolve after each iteration.
+func (w *writer) iteration(k int, pop *Population) error {
+	best := pop.Individuals[len(pop.Individuals)-1]
+
+	if k == *iterations-1 {
+		// The worse half of the population is replaced after this call, so
+		// keep the evaluated population of the last iteration.
+		w.result.Population = nil
+		for i := len(pop.Individuals) - 1; i >= 0; i-- {
+			w.result.Population = append(w.result.Population, newCandidate(pop.Individuals[i]))
+		}
+	}
+
+	it := Iteration{
+		Iteration: k,
+		Candidate: newCandidate(best),
+	}
+
+	switch w.format {
+	case output.Text:
+		fmt.Fprintln(w.w, best)
+	case output.JSON:
+		w.result.Iterations = append(w.result.Iterations, it)
+	case output.CSV:
+		w.csv.Write(w.record(strconv.Itoa(k), it.Candidate)...)
+		return w.csv.Flush()
+	case output.NDJSON:
+		return w.ndjson.Write("iteration", it)
+	}
+
+	return nil
+}
+
+// finish writes the final population, ordered from best to worst, and the
+// overfitting statistics.
+func (w *writer) finish() error {
+	ov := w.result.Overfitting
+
+	switch w.format {
+	case output.Text:
+		fmt.Fprintln(w.w)
+		fmt.Fprint(w.w, ov)
+	case output.JSON:
+		return output.WriteJSON(w.w, w.result)
+	case output.CSV:
+		w.csv.Table(w.header("rank")...)
+		for i, c := range w.result.Population {
+			w.csv.Write(w.record(strconv.Itoa(i+1), c)...)
+		}
+		w.csv.Table("trials", "selected", "sharpe_ratio", "monthly_sharpe_ratio", "expected_max_sharpe_ratio",
+			"deflated_sharpe_ratio", "probability_of_overfitting", "blocks")
+		w.csv.Write(strconv.Itoa(ov.Trials), strconv.Itoa(ov.Selected), output.Float(float64(ov.SharpeRatio)),
+			output.Float(float64(ov.MonthlySharpeRatio)), output.Float(float64(ov.ExpectedMaxSharpeRatio)),
+			output.Float(float64(ov.DeflatedSharpeRatio)), output.Float(float64(ov.ProbabilityOfOverfitting)), strconv.Itoa(ov.Blocks))
+		return w.csv.Flush()
+	case output.NDJSON:
+		for i, c := range w.result.Population {
+			err := w.ndjson.Write("individual", struct {
+				Rank int `json:"rank"`
+				Candidate
+			}{i + 1, c})
+			if err != nil {
+				return err
+			}
+		}
+		return w.ndjson.Write("overfitting", ov)
+	}
+
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/octo/portfolio-mcmc/output"
+	"github.com/octo/portfolio-mcmc/portfolio"
+	"github.com/octo/portfolio-mcmc/timeseries"
+)
+
+// trials records the portfolios the optimizer evaluated, as the trials for
+// the overfitting statistics. Every distinct candidate, i.e. set of weights
+// rounded to 0.1 percentage points, counts as a trial and contributes its
+// historic monthly Sharpe ratio to the variance. The best candidate of every
+// generation is kept: these are the portfolios the optimizer actually
+// selected, including its final pick, and the probability of backtest
+// overfitting is estimated on them.
+type trials struct {
+	hist map[string]timeseries.Data
+	seen map[string]bool
+	// sharpeRatios holds the historic monthly Sharpe ratios of all
+	// distinct candidates.
+	sharpeRatios []float64
+	// best holds the historic returns of the distinct best candidates.
+	best     []timeseries.Data
+	bestSeen map[string]bool
+}
+
+func newTrials(hist map[string]timeseries.Data) *trials {
+	return &trials{
+		hist:     hist,
+		seen:     make(map[string]bool),
+		bestSeen: make(map[string]bool),
+	}
+}
+
+// record adds the evaluated population of one generation. The individuals
+// are sorted by increasing Sharpe ratio, so the last one is the generation's
+// best.
+func (t *trials) record(pop *Population) error {
+	for i, ind := range pop.Individuals {
+		key := ind.Portfolio.CSV()
+		isBest := i == len(pop.Individuals)-1 && !t.bestSeen[key]
+		if t.seen[key] && !isBest {
+			continue
+		}
+
+		h, err := ind.Portfolio.Eval(&timeseries.Backtest{Data: t.hist})
+		if err != nil {
+			return fmt.Errorf("Portfolio.Eval: %w", err)
+		}
+		if !t.seen[key] {
+			t.seen[key] = true
+			t.sharpeRatios = append(t.sharpeRatios, h.MonthlySharpeRatio())
+		}
+		if isBest {
+			t.bestSeen[key] = true
+			t.best = append(t.best, h)
+		}
+	}
+	return nil
+}
+
+// Overfitting holds statistics on the selection bias in the historic Sharpe
+// ratio of the optimizer's best portfolio. Monthly Sharpe ratios are neither
+// compounded nor annualized; probabilities are between 0 and 1.
+type Overfitting struct {
+	// Trials is the number of distinct portfolios the optimizer evaluated.
+	Trials int `json:"trials"`
+	// Selected is the number of distinct portfolios that were the best of
+	// a generation, on which the probability of overfitting is estimated.
+	Selected int `json:"selected"`
+	// SharpeRatio is the best portfolio's historic Sharpe ratio.
+	SharpeRatio        output.Number `json:"sharpe_ratio"`
+	MonthlySharpeRatio output.Number `json:"monthly_sharpe_ratio"`
+	// ExpectedMaxSharpeRatio is the monthly Sharpe ratio the best of the
+	// trials is expected to reach by chance.
+	ExpectedMaxSharpeRatio output.Number `json:"expected_max_sharpe_ratio"`
+	// DeflatedSharpeRatio is the probability that the best portfolio's
+	// Sharpe ratio is not due to selection bias.
+	DeflatedSharpeRatio output.Number `json:"deflated_sharpe_ratio"`
+	// ProbabilityOfOverfitting is the probability that the best of the
+	// selected portfolios in sample does worse than their median out of
+	// sample. It is NaN if fewer than two portfolios were selected.
+	ProbabilityOfOverfitting output.Number `json:"probability_of_overfitting"`
+	Blocks                   int           `json:"blocks"`
+}
+
+// overfitting evaluates best, the optimizer's pick, against the trials.
+func (t *trials) overfitting(best portfolio.Portfolio) (Overfitting, error) {
+	h, err := best.Eval(&timeseries.Backtest{Data: t.hist})
+	if err != nil {
+		return Overfitting{}, fmt.Errorf("Portfolio.Eval: %w", err)
+	}
+
+	pbo := math.NaN()
+	if len(t.best) >= 2 {
+		if pbo, err = timeseries.ProbabilityOfOverfitting(t.best, *blocks); err != nil {
+			return Overfitting{}, err
+		}
+	}
+
+	n, variance := len(t.sharpeRatios), timeseries.Variance(t.sharpeRatios)
+	return Overfitting{
+		Trials:                   n,
+		Selected:                 len(t.best),
+		SharpeRatio:              output.Number(h.SharpeRatio()),
+		MonthlySharpeRatio:       output.Number(h.MonthlySharpeRatio()),
+		ExpectedMaxSharpeRatio:   output.Number(timeseries.ExpectedMaxSharpeRatio(n, variance)),
+		DeflatedSharpeRatio:      output.Number(h.DeflatedSharpeRatio(n, variance)),
+		ProbabilityOfOverfitting: output.Number(pbo),
+		Blocks:                   *blocks,
+	}, nil
+}
+
+func (o Overfitting) String() string {
+	pbo := "n/a (fewer than two selected portfolios)"
+	if !math.IsNaN(float64(o.ProbabilityOfOverfitting)) {
+		pbo = fmt.Sprintf("%.0f%% (%d selected portfolios, %d blocks)", 100*o.ProbabilityOfOverfitting, o.Selected, o.Blocks)
+	}
+	return fmt.Sprintf("historic sharpe ratio of the best portfolio: %.2f (monthly: %.3f)\n"+
+		"expected maximum monthly sharpe ratio of %d trials without skill: %.3f\n"+
+		"deflated sharpe ratio: %.0f%%\n"+
+		"probability of backtest overfitting: %s\n",
+		o.SharpeRatio, o.MonthlySharpeRatio, o.Trials, o.ExpectedMaxSharpeRatio,
+		100*o.DeflatedSharpeRatio, pbo)
+}
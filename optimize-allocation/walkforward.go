@@ -0,0 +1,210 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/octo/portfolio-mcmc/output"
+	"github.com/octo/portfolio-mcmc/timeseries"
+)
+
+// WalkForwardResult is the output of a walk-forward analysis: the optimizer
+// runs on each training window and its best portfolio is evaluated on the
+// following, unseen months.
+type WalkForwardResult struct {
+	Parameters Parameters `json:"parameters"`
+	Series     []string   `json:"series"`
+	Folds      []Fold     `json:"folds"`
+	// InSampleSharpeRatio is the mean Sharpe ratio of the chosen portfolios
+	// on their training windows.
+	InSampleSharpeRatio output.Number `json:"in_sample_sharpe_ratio"`
+	// OutOfSample holds the metrics of the test windows combined into one
+	// series.
+	OutOfSample output.Metrics `json:"out_of_sample"`
+	// Degradation is the reduction of the Sharpe ratio out of sample, in
+	// percent of the in-sample Sharpe ratio. It is nil unless the in-sample
+	// Sharpe ratio is positive.
+	Degradation *float64 `json:"degradation,omitempty"`
+}
+
+// Fold is the portfolio chosen on one training window and its historic
+// performance in and out of sample. Dates are months in the form "2006-01".
+type Fold struct {
+	TrainStart  string           `json:"train_start"`
+	TrainEnd    string           `json:"train_end"`
+	TestStart   string           `json:"test_start"`
+	TestEnd     string           `json:"test_end"`
+	Portfolio   output.Portfolio `json:"portfolio"`
+	InSample    output.Metrics   `json:"in_sample"`
+	OutOfSample output.Metrics   `json:"out_of_sample"`
+
+	description string
+}
+
+// walkForward optimizes on each training window of hist and evaluates the
+// best portfolio on the following months. It calls report after each fold.
+func walkForward(hist map[string]timeseries.Data, report func(Fold) error) (WalkForwardResult, error) {
+	names := seriesNames(hist)
+	ret := WalkForwardResult{
+		Parameters: newParameters(),
+		Series:     names,
+	}
+
+	months := hist[names[0]].Data
+	folds := timeseries.WalkForward(len(months), *trainMonths, *testMonths, *rollingWindow)
+	if len(folds) == 0 {
+		return ret, fmt.Errorf("the history has %d months, want at least %d training and %d test months", len(months), *trainMonths, *testMonths)
+	}
+
+	month := func(i int) string {
+		return months[i].Date.Format("2006-01")
+	}
+
+	var inSample float64
+	outOfSample := timeseries.Data{Name: "Out of Sample"}
+	for _, f := range folds {
+		train := timeseries.Slice(hist, f.TrainStart, f.TrainEnd)
+		pop, err := evolve(train, func(int, *Population) error { return nil })
+		if err != nil {
+			return ret, err
+		}
+		best := pop.Individuals[len(pop.Individuals)-1].Portfolio
+
+		is, err := best.Eval(&timeseries.Backtest{Data: train})
+		if err != nil {
+			return ret, fmt.Errorf("Portfolio.Eval: %w", err)
+		}
+		oos, err := best.Eval(&timeseries.Backtest{
+			Data:   hist,
+			Start:  f.TrainEnd,
+			Months: f.TestEnd - f.TrainEnd,
+		})
+		if err != nil {
+			return ret, fmt.Errorf("Portfolio.Eval: %w", err)
+		}
+
+		fold := Fold{
+			TrainStart:  month(f.TrainStart),
+			TrainEnd:    month(f.TrainEnd - 1),
+			TestStart:   month(f.TrainEnd),
+			TestEnd:     month(f.TestEnd - 1),
+			Portfolio:   output.NewPortfolio(best),
+			InSample:    output.NewMetrics(is),
+			OutOfSample: output.NewMetrics(oos),
+			description: best.String(),
+		}
+		ret.Folds = append(ret.Folds, fold)
+		inSample += float64(fold.InSample.SharpeRatio)
+		outOfSample.Data = append(outOfSample.Data, oos.Data...)
+
+		if err := report(fold); err != nil {
+			return ret, err
+		}
+	}
+
+	ret.InSampleSharpeRatio = output.Number(inSample / float64(len(folds)))
+	ret.OutOfSample = output.NewMetrics(outOfSample)
+	if ret.InSampleSharpeRatio > 0 {
+		d := 100 * (1 - float64(ret.OutOfSample.SharpeRatio/ret.InSampleSharpeRatio))
+		ret.Degradation = &d
+	}
+	return ret, nil
+}
+
+// degradation formats the degradation for text output.
+func (r WalkForwardResult) degradation() string {
+	if r.Degradation == nil {
+		return "n/a (in-sample sharpe ratio not positive)"
+	}
+	return fmt.Sprintf("%.0f%%", *r.Degradation)
+}
+
+// writeWalkForward runs the walk-forward analysis and writes its results in
+// format. The text, CSV and NDJSON formats write each fold as it completes.
+func writeWalkForward(format string, w io.Writer, hist map[string]timeseries.Data) error {
+	names := seriesNames(hist)
+	weights := func(p output.Portfolio) []string {
+		var ret []string
+		for _, name := range names {
+			var weight float64
+			for _, pos := range p.Positions {
+				if pos.Name == name {
+					weight = pos.Weight
+				}
+			}
+			ret = append(ret, output.Float(weight))
+		}
+		return ret
+	}
+
+	var (
+		csv    *output.CSVWriter
+		ndjson *output.NDJSONWriter
+	)
+	switch format {
+	case output.Text:
+		fmt.Fprintln(w, "=== Walk-Forward ===")
+		fmt.Fprintf(w, "%-17s %-17s %9s %13s  %s\n", "training", "test", "in-sample", "out-of-sample", "portfolio")
+	case output.CSV:
+		csv = output.NewCSVWriter(w)
+		writeParameters(csv)
+		csv.Table(append([]string{"train_start", "train_end", "test_start", "test_end", "in_sample_sharpe_ratio",
+			"returns", "volatility", "sharpe_ratio", "max_drawdown", "growth"}, names...)...)
+	case output.NDJSON:
+		ndjson = output.NewNDJSONWriter(w)
+		if err := ndjson.Write("parameters", newParameters()); err != nil {
+			return err
+		}
+		if err := ndjson.Write("series", struct {
+			Series []string `json:"series"`
+		}{names}); err != nil {
+			return err
+		}
+	}
+
+	res, err := walkForward(hist, func(f Fold) error {
+		switch format {
+		case output.Text:
+			fmt.Fprintf(w, "%-17s %-17s %9.2f %13.2f  %s\n",
+				f.TrainStart+" – "+f.TrainEnd, f.TestStart+" – "+f.TestEnd,
+				f.InSample.SharpeRatio, f.OutOfSample.SharpeRatio, f.description)
+		case output.CSV:
+			record := []string{f.TrainStart, f.TrainEnd, f.TestStart, f.TestEnd, output.Float(float64(f.InSample.SharpeRatio))}
+			record = append(record, f.OutOfSample.Record()...)
+			csv.Write(append(record, weights(f.Portfolio)...)...)
+			return csv.Flush()
+		case output.NDJSON:
+			return ndjson.Write("fold", f)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case output.Text:
+		fmt.Fprintln(w)
+		fmt.Fprintf(w, "in-sample sharpe ratio (mean): %.2f\n", res.InSampleSharpeRatio)
+		fmt.Fprintf(w, "out-of-sample: returns: %.1f%%; volatility: %.1f%%; sharpe ratio: %.2f\n",
+			res.OutOfSample.Returns, res.OutOfSample.Volatility, res.OutOfSample.SharpeRatio)
+		fmt.Fprintf(w, "sharpe ratio degradation: %s\n", res.degradation())
+	case output.JSON:
+		return output.WriteJSON(w, res)
+	case output.CSV:
+		var degradation string
+		if res.Degradation != nil {
+			degradation = output.Float(*res.Degradation)
+		}
+		csv.Table("in_sample_sharpe_ratio", "out_of_sample_sharpe_ratio", "degradation")
+		csv.Write(output.Float(float64(res.InSampleSharpeRatio)), output.Float(float64(res.OutOfSample.SharpeRatio)), degradation)
+		return csv.Flush()
+	case output.NDJSON:
+		return ndjson.Write("summary", struct {
+			InSampleSharpeRatio output.Number  `json:"in_sample_sharpe_ratio"`
+			OutOfSample         output.Metrics `json:"out_of_sample"`
+			Degradation         *float64       `json:"degradation,omitempty"`
+		}{res.InSampleSharpeRatio, res.OutOfSample, res.Degradation})
+	}
+	return nil
+}
@@ -0,0 +1,402 @@
+// Package output implements the machine-readable output formats of the
+// commands. The JSON field names and CSV headers are part of the documented
+// schema, see README.md; add fields rather than renaming them.
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/octo/portfolio-mcmc/portfolio"
+	"github.com/octo/portfolio-mcmc/timeseries"
+)
+
+// Formats accepted by the commands' -format flag.
+const (
+	Text   = "text"
+	JSON   = "json"
+	CSV    = "csv"
+	NDJSON = "ndjson"
+)
+
+// CheckFormat returns an error if format is not one of the supported formats.
+func CheckFormat(format string) error {
+	switch format {
+	case Text, JSON, CSV, NDJSON:
+		return nil
+	}
+	return fmt.Errorf("unknown format %q, want one of %q, %q, %q and %q", format, Text, JSON, CSV, NDJSON)
+}
+
+// Position is a position of a portfolio. Weight is the share of the
+// portfolio's total value in percent; TER and tracking difference are annual
+// costs in percent.
+type Position struct {
+	Name               string  `json:"name"`
+	Value              float64 `json:"value"`
+	Weight             float64 `json:"weight"`
+	TER                float64 `json:"ter,omitempty"`
+	TrackingDifference float64 `json:"tracking_difference,omitempty"`
+	FundType           string  `json:"fund_type,omitempty"`
+}
+
+// Portfolio is the composition of a portfolio.
+type Portfolio struct {
+	Name      string     `json:"name,omitempty"`
+	Owner     string     `json:"owner,omitempty"`
+	Account   string     `json:"account,omitempty"`
+	Notes     string     `json:"notes,omitempty"`
+	Value     float64    `json:"value"`
+	Positions []Position `json:"positions"`
+	// Rebalance is the number of months between rebalancing; zero means
+	// the portfolio is not rebalanced periodically.
+	Rebalance          int                     `json:"rebalance,omitempty"`
+	RebalanceThreshold float64                 `json:"rebalance_threshold,omitempty"`
+	CashFlow           *CashFlow               `json:"cash_flow,omitempty"`
+	TradingCosts       *portfolio.TradingCosts `json:"trading_costs,omitempty"`
+	Tax                *portfolio.Tax          `json:"tax,omitempty"`
+	Interest           *portfolio.Interest     `json:"interest,omitempty"`
+	Borrowing          *portfolio.Borrowing    `json:"borrowing,omitempty"`
+	Strategy           string                  `json:"strategy,omitempty"`
+	GlidePath          *GlidePath              `json:"glide_path,omitempty"`
+}
+
+// GlidePath changes the weights of a portfolio over time. Age is the owner's
+// age at the start.
+type GlidePath struct {
+	Age   float64     `json:"age,omitempty"`
+	Steps []GlideStep `json:"steps"`
+}
+
+// GlideStep holds the weights in percent at an age or in a month, formatted
+// as "2006-01".
+type GlideStep struct {
+	Age     float64            `json:"age,omitempty"`
+	Date    string             `json:"date,omitempty"`
+	Weights map[string]float64 `json:"weights"`
+}
+
+// CashFlow is a regular contribution or, if negative, withdrawal.
+type CashFlow struct {
+	Amount float64 `json:"amount"`
+	Months int     `json:"months"`
+}
+
+// NewPortfolio returns the composition of p.
+func NewPortfolio(p portfolio.Portfolio) Portfolio {
+	ret := Portfolio{
+		Name:               p.Name,
+		Owner:              p.Owner,
+		Account:            p.Account,
+		Notes:              p.Notes,
+		Positions:          []Position{},
+		Rebalance:          p.Rebalance,
+		RebalanceThreshold: p.RebalanceThreshold,
+	}
+	if p.CashFlow.Months != 0 {
+		ret.CashFlow = &CashFlow{
+			Amount: p.CashFlow.Amount,
+			Months: p.CashFlow.Months,
+		}
+	}
+	ret.Tax = p.Tax
+	if c := p.TradingCosts; c != (portfolio.TradingCosts{}) {
+		ret.TradingCosts = &c
+	}
+	if i := p.Interest; i != (portfolio.Interest{}) {
+		ret.Interest = &i
+	}
+	if b := p.Borrowing; b != (portfolio.Borrowing{}) {
+		ret.Borrowing = &b
+	}
+	if p.Strategy != nil {
+		ret.Strategy = p.Strategy.String()
+	}
+	if g, ok := p.Strategy.(portfolio.GlidePath); ok {
+		ret.GlidePath = &GlidePath{Age: g.Age}
+		for _, step := range g.Steps {
+			s := GlideStep{
+				Age:     step.Age,
+				Weights: map[string]float64{},
+			}
+			if !step.Date.IsZero() {
+				s.Date = step.Date.Format("2006-01")
+			}
+			var sum float64
+			for _, w := range step.Weights {
+				sum += w
+			}
+			for name, w := range step.Weights {
+				s.Weights[name] = 100 * w / sum
+			}
+			ret.GlidePath.Steps = append(ret.GlidePath.Steps, s)
+		}
+	}
+	for _, pos := range p.Positions {
+		ret.Value += pos.Value
+	}
+	for _, pos := range p.Positions {
+		ret.Positions = append(ret.Positions, Position{
+			Name:   pos.Name,
+			Value:  pos.Value,
+			Weight: 100 * pos.Value / ret.Value,
+
+			TER:                pos.TER,
+			TrackingDifference: pos.TrackingDifference,
+			FundType:           pos.FundType,
+		})
+	}
+
+	return ret
+}
+
+// Metrics holds the values of timeseries.Metrics for a single result.
+// Returns, volatility and drawdown are annualized and in percent; growth is
+// the terminal value relative to the initial value.
+type Metrics struct {
+	Returns     Number `json:"returns"`
+	Volatility  Number `json:"volatility"`
+	SharpeRatio Number `json:"sharpe_ratio"`
+	MaxDrawdown Number `json:"max_drawdown"`
+	Growth      Number `json:"growth"`
+}
+
+// NewMetrics calculates the metrics of h.
+func NewMetrics(h timeseries.Data) Metrics {
+	return Metrics{
+		Returns:     Number(h.Returns()),
+		Volatility:  Number(h.Volatility()),
+		SharpeRatio: Number(h.SharpeRatio()),
+		MaxDrawdown: Number(h.MaxDrawdown()),
+		Growth:      Number(h.Growth()),
+	}
+}
+
+// MetricsHeader is the CSV header matching Metrics.Record.
+var MetricsHeader = []string{"returns", "volatility", "sharpe_ratio", "max_drawdown", "growth"}
+
+// Record returns the metrics as CSV fields.
+func (m Metrics) Record() []string {
+	return []string{Float(float64(m.Returns)), Float(float64(m.Volatility)), Float(float64(m.SharpeRatio)), Float(float64(m.MaxDrawdown)), Float(float64(m.Growth))}
+}
+
+// CostDrag is the reduction of the annualized returns caused by costs, in
+// percentage points.
+type CostDrag struct {
+	Total   Number `json:"total"`
+	Fund    Number `json:"fund"`
+	Trading Number `json:"trading"`
+}
+
+// NewCostDrag converts d.
+func NewCostDrag(d portfolio.CostDrag) CostDrag {
+	return CostDrag{
+		Total:   Number(d.Total()),
+		Fund:    Number(d.Fund),
+		Trading: Number(d.Trading),
+	}
+}
+
+// Relative holds the statistics of timeseries.Relative.
+type Relative struct {
+	Beta             Number `json:"beta"`
+	Alpha            Number `json:"alpha"`
+	TrackingError    Number `json:"tracking_error"`
+	InformationRatio Number `json:"information_ratio"`
+	UpCapture        Number `json:"up_capture"`
+	DownCapture      Number `json:"down_capture"`
+}
+
+// NewRelative converts r.
+func NewRelative(r timeseries.Relative) Relative {
+	return Relative{
+		Beta:             Number(r.Beta),
+		Alpha:            Number(r.Alpha),
+		TrackingError:    Number(r.TrackingError),
+		InformationRatio: Number(r.InformationRatio),
+		UpCapture:        Number(r.UpCapture),
+		DownCapture:      Number(r.DownCapture),
+	}
+}
+
+// SharpeTest holds the result of a timeseries.SharpeTest. Z is omitted for
+// the bootstrap test.
+type SharpeTest struct {
+	Difference Number `json:"difference"`
+	Z          Number `json:"z,omitempty"`
+	PValue     Number `json:"p_value"`
+	Lower      Number `json:"lower"`
+	Upper      Number `json:"upper"`
+}
+
+// NewSharpeTest converts t.
+func NewSharpeTest(t timeseries.SharpeTest) SharpeTest {
+	return SharpeTest{
+		Difference: Number(t.Difference),
+		Z:          Number(t.Z),
+		PValue:     Number(t.PValue),
+		Lower:      Number(t.Lower),
+		Upper:      Number(t.Upper),
+	}
+}
+
+// Percentile is a percentile of a metric's distribution together with its
+// confidence interval. Percentiles follow the convention of
+// timeseries.Distribution.Percentile, i.e. P95 is exceeded by 95% of results.
+type Percentile struct {
+	Metric     string  `json:"metric"`
+	Percentile float64 `json:"percentile"`
+	Value      Number  `json:"value"`
+	Lower      Number  `json:"lower"`
+	Upper      Number  `json:"upper"`
+}
+
+// Percentiles returns the percentiles pcts of d with confidence intervals at
+// the given level, e.g. 0.95.
+func Percentiles(d timeseries.Distribution, pcts []float64, level float64) []Percentile {
+	var ret []Percentile
+	for _, p := range pcts {
+		lo, hi := d.ConfidenceInterval(p, level)
+		ret = append(ret, Percentile{
+			Metric:     MetricName(d.Metric),
+			Percentile: p,
+			Value:      Number(d.Percentile(p)),
+			Lower:      Number(lo),
+			Upper:      Number(hi),
+		})
+	}
+	return ret
+}
+
+// ParsePercentiles parses a comma separated list of percentiles, e.g.
+// "50,90,95".
+func ParsePercentiles(s string) ([]float64, error) {
+	var ret []float64
+	for _, field := range strings.Split(s, ",") {
+		p, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, err
+		}
+		if p < 0 || p > 100 {
+			return nil, fmt.Errorf("percentile %g out of range [0, 100]", p)
+		}
+		ret = append(ret, p)
+	}
+
+	return ret, nil
+}
+
+// MetricName returns the name of m as used in the schema, e.g. "sharpe_ratio".
+func MetricName(m timeseries.Metric) string {
+	return strings.ReplaceAll(m.Name, " ", "_")
+}
+
+// Number is a float64 that is written to JSON as null if it is NaN or
+// infinite, which encoding/json rejects. It is used for statistics that may be
+// undefined, e.g. the Sharpe ratio of a series without volatility.
+type Number float64
+
+// MarshalJSON implements json.Marshaler.
+func (n Number) MarshalJSON() ([]byte, error) {
+	if f := float64(n); math.IsNaN(f) || math.IsInf(f, 0) {
+		return []byte("null"), nil
+	}
+	return json.Marshal(float64(n))
+}
+
+// WriteJSON writes v as a single, indented JSON document.
+func WriteJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// NDJSONWriter writes newline delimited JSON. Every line is an object with a
+// "type" field followed by the fields of the record.
+type NDJSONWriter struct {
+	w io.Writer
+}
+
+// NewNDJSONWriter returns a new NDJSONWriter writing to w.
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	return &NDJSONWriter{w: w}
+}
+
+// Write writes one line of type typ. v must encode to a JSON object.
+func (w *NDJSONWriter) Write(typ string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if len(data) < 2 || data[0] != '{' {
+		return fmt.Errorf("record of type %q is not an object", typ)
+	}
+
+	line := fmt.Sprintf(`{"type":%q`, typ)
+	if rest := string(data[1:]); rest != "}" {
+		line += "," + rest
+	} else {
+		line += rest
+	}
+
+	_, err = fmt.Fprintln(w.w, line)
+	return err
+}
+
+// CSVWriter writes several CSV tables to the same stream, separated by empty
+// lines. Each table starts with a header.
+type CSVWriter struct {
+	w      io.Writer
+	cw     *csv.Writer
+	tables int
+}
+
+// NewCSVWriter returns a new CSVWriter writing to w.
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{
+		w:  w,
+		cw: csv.NewWriter(w),
+	}
+}
+
+// Table starts a new table.
+func (w *CSVWriter) Table(header ...string) {
+	if w.tables != 0 {
+		w.cw.Flush()
+		fmt.Fprintln(w.w)
+	}
+	w.tables++
+	w.cw.Write(header)
+}
+
+// Write writes a row of the current table.
+func (w *CSVWriter) Write(record ...string) {
+	w.cw.Write(record)
+}
+
+// Flush writes any buffered data and returns the first error encountered.
+func (w *CSVWriter) Flush() error {
+	w.cw.Flush()
+	return w.cw.Error()
+}
+
+// PositionsHeader is the CSV header matching CSVWriter.Positions.
+var PositionsHeader = []string{"series", "position", "value", "weight"}
+
+// Positions writes one row per position of p. series identifies the
+// portfolio, e.g. "portfolio" or "benchmark".
+func (w *CSVWriter) Positions(series string, p Portfolio) {
+	for _, pos := range p.Positions {
+		w.Write(series, pos.Name, Float(pos.Value), Float(pos.Weight))
+	}
+}
+
+// Float formats v without losing precision.
+func Float(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
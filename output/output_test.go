@@ -0,0 +1,106 @@
+package output
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/octo/portfolio-mcmc/portfolio"
+)
+
+func TestNDJSONWriter(t *testing.T) {
+	var b strings.Builder
+	w := NewNDJSONWriter(&b)
+
+	p := NewPortfolio(portfolio.Portfolio{
+		Positions: []portfolio.Position{
+			{Name: "a", Value: 75},
+			{Name: "b", Value: 25},
+		},
+	})
+	if err := w.Write("portfolio", p); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write("empty", struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write("number", 42); err == nil {
+		t.Error(`Write("number", 42) succeeded, want error`)
+	}
+
+	want := `{"type":"portfolio","value":100,"positions":[{"name":"a","value":75,"weight":75},{"name":"b","value":25,"weight":25}]}
+{"type":"empty"}
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("NDJSONWriter output differs (-want/+got):\n%s", diff)
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	var b strings.Builder
+	w := NewCSVWriter(&b)
+
+	w.Table("parameter", "value")
+	w.Write("iterations", "100")
+	w.Table(PositionsHeader...)
+	w.Positions("portfolio", Portfolio{
+		Positions: []Position{{Name: "a, b", Value: 1, Weight: 100}},
+	})
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `parameter,value
+iterations,100
+
+series,position,value,weight
+portfolio,"a, b",1,100
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("CSVWriter output differs (-want/+got):\n%s", diff)
+	}
+}
+
+func TestParsePercentiles(t *testing.T) {
+	got, err := ParsePercentiles("50, 90,99.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]float64{50, 90, 99.5}, got); diff != "" {
+		t.Errorf("ParsePercentiles() differs (-want/+got):\n%s", diff)
+	}
+
+	for _, input := range []string{"", "50,x", "101", "-1"} {
+		if _, err := ParsePercentiles(input); err == nil {
+			t.Errorf("ParsePercentiles(%q) = nil, want error", input)
+		}
+	}
+}
+
+func TestNumber(t *testing.T) {
+	type record struct {
+		Value   Number            `json:"value"`
+		Omitted Number            `json:"omitted,omitempty"`
+		Values  []Number          `json:"values"`
+		Map     map[string]Number `json:"map"`
+		Metrics Metrics           `json:"metrics"`
+	}
+
+	r := record{
+		Value:   Number(math.NaN()),
+		Values:  []Number{1.5, Number(math.Inf(-1))},
+		Map:     map[string]Number{"b": 2, "a": Number(math.Inf(1))},
+		Metrics: Metrics{Returns: 5, SharpeRatio: Number(math.NaN())},
+	}
+
+	var b strings.Builder
+	if err := NewNDJSONWriter(&b).Write("record", r); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"record","value":null,"values":[1.5,null],"map":{"a":null,"b":2},"metrics":{"returns":5,"volatility":0,"sharpe_ratio":null,"max_drawdown":0,"growth":0}}
+`
+	if diff := cmp.Diff(want, b.String()); diff != "" {
+		t.Errorf("NDJSONWriter output differs (-want/+got):\n%s", diff)
+	}
+}
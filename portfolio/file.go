@@ -0,0 +1,547 @@
+package portfolio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/octo/portfolio-mcmc/timeseries"
+)
+
+// File is the format of portfolio definition files. Example:
+//
+//	{
+//	  "portfolios": [
+//	    {
+//	      "name": "retirement",
+//	      "owner": "alice",
+//	      "value": 250000,
+//	      "positions": [
+//	        {"name": "WORLD", "percent": 70, "ter": 0.2},
+//	        {"name": "BONDS", "percent": 30, "ter": 0.1}
+//	      ],
+//	      "rebalance": "annually",
+//	      "cash_flow": {"amount": 500, "frequency": "monthly"},
+//	      "trading_costs": {"fee": 1.5, "spread": 0.1},
+//	      "tax": {"allowance": 2000}
+//	    },
+//	    {
+//	      "name": "leveraged",
+//	      "positions": [
+//	        {"name": "WORLD", "percent": 150},
+//	        {"name": "CASH", "percent": -50}
+//	      ],
+//	      "rebalance": "monthly",
+//	      "interest": {"rate": 3},
+//	      "borrowing": {"spread": 1.5, "maintenance": 25}
+//	    },
+//	    {
+//	      "name": "target date",
+//	      "positions": [
+//	        {"name": "WORLD", "percent": 50},
+//	        {"name": "BONDS", "percent": 50}
+//	      ],
+//	      "glide_path": {
+//	        "age": 40,
+//	        "steps": [
+//	          {"age": 40, "weights": {"WORLD": 90, "BONDS": 10}},
+//	          {"age": 65, "weights": {"WORLD": 40, "BONDS": 60}}
+//	        ]
+//	      }
+//	    },
+//	    {
+//	      "name": "trend",
+//	      "positions": [
+//	        {"name": "WORLD", "percent": 100},
+//	        {"name": "CASH", "percent": 0}
+//	      ],
+//	      "strategy": "trend(10, CASH)"
+//	    }
+//	  ]
+//	}
+type File struct {
+	Portfolios []FilePortfolio `json:"portfolios"`
+}
+
+// FilePortfolio is a single portfolio in a portfolio definition file.
+type FilePortfolio struct {
+	Name    string `json:"name"`
+	Owner   string `json:"owner,omitempty"`
+	Account string `json:"account,omitempty"`
+	Notes   string `json:"notes,omitempty"`
+	// Value is the total value of a portfolio whose positions are given as
+	// percentages. It defaults to 100.
+	Value     float64        `json:"value,omitempty"`
+	Positions []FilePosition `json:"positions"`
+	// Rebalance is one of "never", "monthly", "quarterly" and "annually".
+	Rebalance          string        `json:"rebalance,omitempty"`
+	RebalanceThreshold float64       `json:"rebalance_threshold,omitempty"`
+	CashFlow           *FileCashFlow `json:"cash_flow,omitempty"`
+	TradingCosts       *TradingCosts `json:"trading_costs,omitempty"`
+	// Tax enables the tax model. Missing fields default to DefaultTax.
+	Tax *Tax `json:"tax,omitempty"`
+	// Interest and Borrowing apply to the position named "CASH", which may
+	// be negative to borrow money.
+	Interest  *Interest  `json:"interest,omitempty"`
+	Borrowing *Borrowing `json:"borrowing,omitempty"`
+	// GlidePath and Strategy override the weights of the positions over
+	// time. Strategy is parsed by ParseStrategy.
+	GlidePath *FileGlidePath `json:"glide_path,omitempty"`
+	Strategy  string         `json:"strategy,omitempty"`
+}
+
+// FilePosition is a position given either as an amount or as a percentage.
+type FilePosition struct {
+	Name    string   `json:"name"`
+	Amount  *float64 `json:"amount,omitempty"`
+	Percent *float64 `json:"percent,omitempty"`
+	// TER and TrackingDifference are annual costs in percent.
+	TER                float64 `json:"ter,omitempty"`
+	TrackingDifference float64 `json:"tracking_difference,omitempty"`
+	FundType           string  `json:"fund_type,omitempty"`
+}
+
+// FileCashFlow is a regular contribution or, if Amount is negative, a
+// regular withdrawal.
+type FileCashFlow struct {
+	Amount float64 `json:"amount"`
+	// Frequency is one of "monthly", "quarterly" and "annually".
+	Frequency string `json:"frequency"`
+}
+
+// FileGlidePath is a glide path. Age is the owner's age at the start.
+type FileGlidePath struct {
+	Age   float64         `json:"age,omitempty"`
+	Steps []FileGlideStep `json:"steps"`
+}
+
+// FileGlideStep holds the weights of the positions at an age or in a month,
+// given as "2006-01".
+type FileGlideStep struct {
+	Age     *float64           `json:"age,omitempty"`
+	Date    string             `json:"date,omitempty"`
+	Weights map[string]float64 `json:"weights"`
+}
+
+func (fg FileGlidePath) glidePath() (GlidePath, error) {
+	g := GlidePath{
+		Age: fg.Age,
+	}
+	if len(fg.Steps) == 0 {
+		return GlidePath{}, errors.New("no steps")
+	}
+	for i, fs := range fg.Steps {
+		var step GlideStep
+		switch {
+		case fs.Age != nil && fs.Date != "":
+			return GlidePath{}, fmt.Errorf("step #%d: specify either age or date", i+1)
+		case fs.Age != nil:
+			step.Age = *fs.Age
+		case fs.Date != "":
+			t, err := time.Parse("2006-01", fs.Date)
+			if err != nil {
+				return GlidePath{}, fmt.Errorf("step #%d: %w", i+1, err)
+			}
+			step.Date = t
+		default:
+			return GlidePath{}, fmt.Errorf("step #%d: age or date missing", i+1)
+		}
+		step.Weights = fs.Weights
+		g.Steps = append(g.Steps, step)
+	}
+	g.sort()
+
+	return g, g.check()
+}
+
+var frequencies = map[string]int{
+	"":          0,
+	"never":     0,
+	"monthly":   1,
+	"quarterly": 3,
+	"annually":  12,
+}
+
+// ReadFile parses a portfolio definition file.
+func ReadFile(r io.Reader) ([]Portfolio, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	var f File
+	if err := dec.Decode(&f); err != nil {
+		return nil, err
+	}
+	if len(f.Portfolios) == 0 {
+		return nil, errors.New("no portfolios defined")
+	}
+
+	var ret []Portfolio
+	seen := map[string]bool{}
+	for i, fp := range f.Portfolios {
+		p, err := fp.portfolio()
+		if err != nil {
+			if fp.Name == "" {
+				return nil, fmt.Errorf("portfolio #%d: %w", i+1, err)
+			}
+			return nil, fmt.Errorf("portfolio %q: %w", fp.Name, err)
+		}
+		if p.Name != "" && seen[p.Name] {
+			return nil, fmt.Errorf("portfolio %q defined more than once", p.Name)
+		}
+		seen[p.Name] = true
+
+		ret = append(ret, p)
+	}
+
+	return ret, nil
+}
+
+func (fp FilePortfolio) portfolio() (Portfolio, error) {
+	p := Portfolio{
+		Name:               fp.Name,
+		Owner:              fp.Owner,
+		Account:            fp.Account,
+		Notes:              fp.Notes,
+		RebalanceThreshold: fp.RebalanceThreshold,
+	}
+
+	if len(fp.Positions) == 0 {
+		return Portfolio{}, errors.New("no positions")
+	}
+
+	var amounts, percents int
+	var sum float64
+	seen := make(map[string]bool)
+	for _, pos := range fp.Positions {
+		if pos.Name == "" {
+			return Portfolio{}, errors.New("position without name")
+		}
+		if seen[pos.Name] {
+			return Portfolio{}, fmt.Errorf("position %q listed more than once", pos.Name)
+		}
+		seen[pos.Name] = true
+
+		var v float64
+		switch {
+		case pos.Amount != nil && pos.Percent != nil:
+			return Portfolio{}, fmt.Errorf("position %q: specify either amount or percent", pos.Name)
+		case pos.Amount != nil:
+			amounts++
+			v = *pos.Amount
+		case pos.Percent != nil:
+			percents++
+			v = *pos.Percent
+		default:
+			return Portfolio{}, fmt.Errorf("position %q: amount or percent missing", pos.Name)
+		}
+		if v <= 0 && pos.Name != CashPosition {
+			return Portfolio{}, fmt.Errorf("position %q: got %g, want a positive weight", pos.Name, v)
+		}
+
+		if pos.TER < 0 || pos.TER+pos.TrackingDifference >= 100 {
+			return Portfolio{}, fmt.Errorf("position %q: invalid ter or tracking_difference", pos.Name)
+		}
+		if err := CheckFundType(pos.FundType); err != nil {
+			return Portfolio{}, fmt.Errorf("position %q: %w", pos.Name, err)
+		}
+
+		sum += v
+		p.Positions = append(p.Positions, Position{
+			Name:               pos.Name,
+			Value:              v,
+			TER:                pos.TER,
+			TrackingDifference: pos.TrackingDifference,
+			FundType:           pos.FundType,
+		})
+	}
+
+	switch {
+	case amounts != 0 && percents != 0:
+		return Portfolio{}, errors.New("positions mix amounts and percentages")
+	case amounts != 0 && fp.Value != 0:
+		return Portfolio{}, errors.New("value must not be set when positions are given as amounts")
+	case percents != 0:
+		if math.Abs(sum-100) > 0.01 {
+			return Portfolio{}, fmt.Errorf("percentages sum to %g, want 100", sum)
+		}
+		value := fp.Value
+		if value == 0 {
+			value = 100
+		}
+		for i := range p.Positions {
+			p.Positions[i].Value *= value / 100
+		}
+	}
+
+	months, ok := frequencies[fp.Rebalance]
+	if !ok {
+		return Portfolio{}, fmt.Errorf("rebalance: unknown frequency %q", fp.Rebalance)
+	}
+	p.Rebalance = months
+	if fp.RebalanceThreshold < 0 || fp.RebalanceThreshold >= 100 {
+		return Portfolio{}, fmt.Errorf("rebalance_threshold: got %g, want a value in [0, 100)", fp.RebalanceThreshold)
+	}
+
+	if cf := fp.CashFlow; cf != nil {
+		months, ok := frequencies[cf.Frequency]
+		if !ok || months == 0 {
+			return Portfolio{}, fmt.Errorf("cash_flow: got frequency %q, want monthly, quarterly or annually", cf.Frequency)
+		}
+		p.CashFlow = CashFlow{
+			Amount: cf.Amount,
+			Months: months,
+		}
+	}
+
+	if c := fp.TradingCosts; c != nil {
+		if c.Fee < 0 || c.Spread < 0 || c.Spread >= 100 {
+			return Portfolio{}, fmt.Errorf("trading_costs: got fee %g and spread %g, want non-negative values and a spread below 100", c.Fee, c.Spread)
+		}
+		p.TradingCosts = *c
+	}
+
+	if t := fp.Tax; t != nil {
+		if t.Rate < 0 || t.Rate >= 100 || t.Soli < 0 || t.Allowance < 0 {
+			return Portfolio{}, fmt.Errorf("tax: invalid parameters %+v", *t)
+		}
+		p.Tax = t
+	}
+
+	if i := fp.Interest; i != nil {
+		p.Interest = *i
+	}
+	if b := fp.Borrowing; b != nil {
+		if b.Spread < 0 || b.Maintenance < 0 || b.Maintenance >= 100 {
+			return Portfolio{}, fmt.Errorf("borrowing: got spread %g and maintenance %g, want a non-negative spread and a maintenance in [0, 100)", b.Spread, b.Maintenance)
+		}
+		p.Borrowing = *b
+	}
+	if sum <= 0 {
+		return Portfolio{}, fmt.Errorf("positions sum to %g, want a positive value", sum)
+	}
+	if err := p.checkLeverage(); err != nil {
+		return Portfolio{}, err
+	}
+
+	switch {
+	case fp.GlidePath != nil && fp.Strategy != "":
+		return Portfolio{}, errors.New("specify either glide_path or strategy")
+	case fp.GlidePath != nil:
+		g, err := fp.GlidePath.glidePath()
+		if err != nil {
+			return Portfolio{}, fmt.Errorf("glide_path: %w", err)
+		}
+		if err := g.Check(p.Positions); err != nil {
+			return Portfolio{}, fmt.Errorf("glide_path: %w", err)
+		}
+		p.Strategy = g
+	case fp.Strategy != "":
+		s, err := ParseStrategy(fp.Strategy)
+		if err != nil {
+			return Portfolio{}, fmt.Errorf("strategy: %w", err)
+		}
+		if err := s.Check(p.Positions); err != nil {
+			return Portfolio{}, fmt.Errorf("strategy: %w", err)
+		}
+		p.Strategy = s
+	}
+
+	return p, nil
+}
+
+// LoadFile loads portfolios from a definition file. spec has the form
+// "<path>[:<name>]"; if a name is given, only that portfolio is returned.
+func LoadFile(spec string) ([]Portfolio, error) {
+	path, name := spec, ""
+	if _, err := os.Stat(spec); err != nil {
+		if i := strings.LastIndex(spec, ":"); i != -1 {
+			path, name = spec[:i], spec[i+1:]
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ps, err := ReadFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if name == "" {
+		return ps, nil
+	}
+
+	var names []string
+	for _, p := range ps {
+		if p.Name == name {
+			return []Portfolio{p}, nil
+		}
+		names = append(names, p.Name)
+	}
+
+	return nil, fmt.Errorf("%s: no portfolio named %q%s", path, name, suggest(name, names))
+}
+
+// FileFlagFunc returns a function that can be passed to flag.Func() to load p
+// from a portfolio definition file. The file must either define exactly one
+// portfolio or the name of the portfolio must be specified.
+func (p *Portfolio) FileFlagFunc() func(string) error {
+	return func(flagValue string) error {
+		if len(p.Positions) != 0 {
+			return errors.New("portfolio already specified")
+		}
+
+		ps, err := LoadFile(flagValue)
+		if err != nil {
+			return err
+		}
+		if len(ps) != 1 {
+			return fmt.Errorf("%s defines %d portfolios, select one with %q", flagValue, len(ps), flagValue+":<name>")
+		}
+
+		*p = ps[0]
+		return nil
+	}
+}
+
+// Validate checks that data for all positions is available in hist, that
+// only the cash position has a negative weight and that the strategy can be
+// applied to the positions. Unknown names are reported
+// with the most similar available names.
+func (p Portfolio) Validate(hist map[string]timeseries.Data) error {
+	available := seriesNames(hist)
+
+	var errs []string
+	for _, pos := range p.Positions {
+		if pos.Value < 0 && pos.Name != CashPosition {
+			errs = append(errs, fmt.Sprintf("position %q: got %g, want a positive weight; only %q may be negative", pos.Name, pos.Value, CashPosition))
+		}
+		if _, ok := hist[pos.Name]; ok || pos.Name == CashPosition {
+			continue
+		}
+		errs = append(errs, fmt.Sprintf("no data for %q%s", pos.Name, suggest(pos.Name, available)))
+	}
+	for _, series := range interestSeries([]Portfolio{p}) {
+		if _, ok := hist[series]; !ok {
+			errs = append(errs, fmt.Sprintf("no data for interest series %q%s", series, suggest(series, available)))
+		}
+	}
+	if p.Strategy != nil {
+		if err := p.Strategy.Check(p.Positions); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", p.Strategy, err))
+		}
+	}
+	if len(p.Positions) != 0 && sum(p.Positions) <= 0 {
+		errs = append(errs, "the positions must add up to a positive value")
+	}
+	if err := p.checkLeverage(); err != nil {
+		errs = append(errs, err.Error())
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+
+	if p.Name != "" {
+		return fmt.Errorf("portfolio %q: %s", p.Name, strings.Join(errs, "; "))
+	}
+	return errors.New(strings.Join(errs, "; "))
+}
+
+// CheckNames checks that hist has a series for each of names. Unknown names
+// are reported with the most similar available names.
+func CheckNames(hist map[string]timeseries.Data, names ...string) error {
+	available := seriesNames(hist)
+
+	var errs []string
+	for _, name := range names {
+		if _, ok := hist[name]; !ok {
+			errs = append(errs, fmt.Sprintf("no data for %q%s", name, suggest(name, available)))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.New(strings.Join(errs, "; "))
+}
+
+// seriesNames returns the sorted names of the series in hist.
+func seriesNames(hist map[string]timeseries.Data) []string {
+	var ret []string
+	for name := range hist {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// suggest returns a ", did you mean …?" hint listing the candidates closest to
+// name, or the empty string if none is similar.
+func suggest(name string, candidates []string) string {
+	type match struct {
+		name string
+		dist int
+	}
+
+	var matches []match
+	for _, c := range candidates {
+		d := levenshtein(strings.ToLower(name), strings.ToLower(c))
+		if d <= (len(name)+2)/3 {
+			matches = append(matches, match{c, d})
+		}
+	}
+	if len(matches) == 0 {
+		return ""
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].dist < matches[j].dist
+	})
+
+	var names []string
+	for i, m := range matches {
+		if i == 3 {
+			break
+		}
+		names = append(names, fmt.Sprintf("%q", m.name))
+	}
+	return fmt.Sprintf(", did you mean %s?", strings.Join(names, " or "))
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if v := prev[j] + 1; v < cur[j] {
+				cur[j] = v
+			}
+			if v := cur[j-1] + 1; v < cur[j] {
+				cur[j] = v
+			}
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(rb)]
+}
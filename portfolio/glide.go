@@ -0,0 +1,198 @@
+package portfolio
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// GlidePath is a Strategy that changes the target weights of a portfolio over
+// time, e.g. from equities to bonds as the owner approaches retirement. The
+// positions of the portfolio determine its value, costs and fund types; the
+// glide path determines their weights, starting with the initial allocation.
+type GlidePath struct {
+	// Age is the owner's age at the start of the simulation. It is used
+	// with steps given by age.
+	Age   float64
+	Steps []GlideStep
+}
+
+// GlideStep holds the target weights at a given age or, if Date is set, in
+// the month of Date. Between steps, the weights are interpolated linearly;
+// before the first and after the last step, they are constant. Positions
+// without weight are not held.
+type GlideStep struct {
+	Age     float64
+	Date    time.Time
+	Weights map[string]float64
+}
+
+// byDate returns true if the steps are given by date rather than age.
+func (g GlidePath) byDate() bool {
+	return len(g.Steps) != 0 && !g.Steps[0].Date.IsZero()
+}
+
+// key returns the position of step in time, in months.
+func (step GlideStep) key() float64 {
+	if !step.Date.IsZero() {
+		return float64(12*step.Date.Year() + int(step.Date.Month()) - 1)
+	}
+	return 12 * step.Age
+}
+
+func (g *GlidePath) sort() {
+	sort.SliceStable(g.Steps, func(i, j int) bool {
+		return g.Steps[i].key() < g.Steps[j].key()
+	})
+}
+
+// check returns an error if the steps mix ages and dates, occur more than
+// once or have no positive weight.
+func (g GlidePath) check() error {
+	for i, step := range g.Steps {
+		if step.Date.IsZero() == g.byDate() {
+			return errors.New("steps mix ages and dates")
+		}
+		if i > 0 && step.key() == g.Steps[i-1].key() {
+			return fmt.Errorf("step %s defined more than once", step)
+		}
+
+		var sum float64
+		for name, w := range step.Weights {
+			if w < 0 {
+				return fmt.Errorf("step %s: got weight %g for %q, want a non-negative weight", step, w, name)
+			}
+			sum += w
+		}
+		if sum <= 0 {
+			return fmt.Errorf("step %s: weights must add up to a positive value", step)
+		}
+	}
+	return nil
+}
+
+func (step GlideStep) String() string {
+	if !step.Date.IsZero() {
+		return step.Date.Format("2006-01")
+	}
+	return strconv.FormatFloat(step.Age, 'g', -1, 64)
+}
+
+// weights returns the target weights of positions at time key, in months. The
+// weights add up to one; weights of other names are ignored.
+func (g GlidePath) weights(positions []Position, key float64) []float64 {
+	i := sort.Search(len(g.Steps), func(i int) bool {
+		return g.Steps[i].key() > key
+	})
+
+	ret := make([]float64, len(positions))
+	add := func(step GlideStep, f float64) {
+		var sum float64
+		for _, pos := range positions {
+			sum += step.Weights[pos.Name]
+		}
+		if sum == 0 {
+			return
+		}
+		for j, pos := range positions {
+			ret[j] += f * step.Weights[pos.Name] / sum
+		}
+	}
+
+	switch {
+	case i == 0:
+		add(g.Steps[0], 1)
+	case i == len(g.Steps):
+		add(g.Steps[i-1], 1)
+	default:
+		prev, next := g.Steps[i-1], g.Steps[i]
+		f := (key - prev.key()) / (next.key() - prev.key())
+		add(prev, 1-f)
+		add(next, f)
+	}
+	return ret
+}
+
+// Weights implements Strategy.
+func (g GlidePath) Weights(positions []Position, h History) []float64 {
+	key := 12*g.Age + float64(h.Months)
+	if g.byDate() {
+		key = GlideStep{Date: h.Start}.key() - 1 + float64(h.Months)
+	}
+	return g.weights(positions, key)
+}
+
+// Check implements Strategy. It returns an error if the steps mix ages and
+// dates, occur more than once, have no positive weight or use names that are
+// not positions.
+func (g GlidePath) Check(positions []Position) error {
+	if len(g.Steps) == 0 {
+		return errors.New("glide path without steps")
+	}
+	if err := g.check(); err != nil {
+		return err
+	}
+	for _, step := range g.Steps {
+		var names []string
+		for name := range step.Weights {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		if err := checkPositions(positions, names...); err != nil {
+			return fmt.Errorf("step %s: %w", step, err)
+		}
+	}
+	return nil
+}
+
+func (g GlidePath) String() string {
+	return fmt.Sprintf("glide path with %d steps", len(g.Steps))
+}
+
+// FlagFunc returns a function that can be passed to flag.Func() to add a step
+// in the form "<age>=<position>:<weight>[,<position>:<weight>]..." or
+// "<YYYY-MM>=<position>:<weight>[,...]".
+func (g *GlidePath) FlagFunc() func(string) error {
+	return func(flagValue string) error {
+		p, err := Parse(flagValue)
+		if err != nil {
+			return err
+		}
+		if p.Name == "" {
+			return fmt.Errorf(`got %q, want "<age or YYYY-MM>=<position>:<weight>[,...]"`, flagValue)
+		}
+
+		step, err := newGlideStep(p.Name, p.Positions)
+		if err != nil {
+			return err
+		}
+		g.Steps = append(g.Steps, step)
+		g.sort()
+		if err := g.check(); err != nil {
+			return fmt.Errorf("glide path: %w", err)
+		}
+		return nil
+	}
+}
+
+// newGlideStep returns a step at when, which is either an age or a month in
+// the form "2006-01".
+func newGlideStep(when string, positions []Position) (GlideStep, error) {
+	var step GlideStep
+	if t, err := time.Parse("2006-01", when); err == nil {
+		step.Date = t
+	} else if step.Age, err = strconv.ParseFloat(when, 64); err != nil {
+		return GlideStep{}, fmt.Errorf("glide path: got %q, want an age or a month in the form YYYY-MM", when)
+	}
+
+	step.Weights = make(map[string]float64)
+	for _, pos := range positions {
+		if _, ok := step.Weights[pos.Name]; ok {
+			return GlideStep{}, fmt.Errorf("glide path: step %s: position %q listed more than once", step, pos.Name)
+		}
+		step.Weights[pos.Name] = pos.Value
+	}
+	return step, nil
+}
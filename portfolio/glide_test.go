@@ -0,0 +1,166 @@
+package portfolio
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/octo/portfolio-mcmc/timeseries"
+)
+
+func TestGlidePathWeights(t *testing.T) {
+	var g GlidePath
+	for _, s := range []string{"50=A:1,B:1", "40=A:100"} {
+		if err := g.FlagFunc()(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	positions := []Position{{Name: "A"}, {Name: "B"}}
+
+	cases := []struct {
+		age  float64
+		want []float64
+	}{
+		{30, []float64{1, 0}},
+		{40, []float64{1, 0}},
+		{45, []float64{.75, .25}},
+		{50, []float64{.5, .5}},
+		{70, []float64{.5, .5}},
+	}
+	for _, tc := range cases {
+		got := g.weights(positions, 12*tc.age)
+		if diff := cmp.Diff(tc.want, got, cmpopts.EquateApprox(0, 1e-12)); diff != "" {
+			t.Errorf("weights(%g) differs (-want/+got):\n%s", tc.age, diff)
+		}
+	}
+
+	for _, s := range []string{"2030-01=A:1", "40=A:0", "40", "old=A:1"} {
+		if err := g.FlagFunc()(s); err == nil {
+			t.Errorf("FlagFunc()(%q) = nil, want error", s)
+		}
+	}
+}
+
+func TestEvalGlidePath(t *testing.T) {
+	returns := map[string][]float64{"A": {}, "B": {}}
+	for i := 0; i < 24; i++ {
+		returns["A"] = append(returns["A"], .1)
+		returns["B"] = append(returns["B"], 0)
+	}
+	hist := newHistory(returns)
+
+	// The share of A falls from 100% to 0% during the first year.
+	cases := []struct {
+		name  string
+		steps []string
+		age   float64
+	}{
+		{"age", []string{"40=A:1", "41=B:1"}, 40},
+		{"date", []string{"1999-12=A:1", "2000-12=B:1"}, 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			g := GlidePath{Age: tc.age}
+			for _, s := range tc.steps {
+				if err := g.FlagFunc()(s); err != nil {
+					t.Fatal(err)
+				}
+			}
+			p := Portfolio{
+				Positions: []Position{{Name: "A", Value: 50}, {Name: "B", Value: 50}},
+				Rebalance: 1,
+				Strategy:  g,
+			}
+
+			values, err := p.EvalValues(&timeseries.Backtest{Data: hist})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := 100.0
+			for m := 0; m < 12; m++ {
+				want *= 1 + .1*(1-float64(m)/12)
+			}
+			if got := values.Data[23].Value; math.Abs(got-want) > 1e-9 {
+				t.Errorf("EvalValues() = %g, want %g", got, want)
+			}
+		})
+	}
+}
+
+func TestEvalGlidePathAnnual(t *testing.T) {
+	returns := map[string][]float64{"A": {}, "B": {}}
+	for i := 0; i < 24; i++ {
+		returns["A"] = append(returns["A"], .1)
+		returns["B"] = append(returns["B"], 0)
+	}
+	hist := newHistory(returns)
+
+	g := GlidePath{Age: 40}
+	for _, s := range []string{"40=A:1", "41=B:1"} {
+		if err := g.FlagFunc()(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p := Portfolio{
+		Positions: []Position{{Name: "A", Value: 50}, {Name: "B", Value: 50}},
+		Strategy:  g,
+	}
+
+	// Without rebalancing settings, the portfolio holds A for the whole
+	// first year and only moves to B at its end.
+	values, err := p.EvalValues(&timeseries.Backtest{Data: hist})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := values.Data[23].Value, 100*math.Pow(1.1, 12); math.Abs(got-want) > 1e-9 {
+		t.Errorf("EvalValues() = %g, want %g", got, want)
+	}
+}
+
+func TestReadFileGlidePath(t *testing.T) {
+	input := `{"portfolios": [{
+		"positions": [{"name": "A", "percent": 50}, {"name": "B", "percent": 50}],
+		"glide_path": {"age": 40, "steps": [
+			{"age": 65, "weights": {"A": 40, "B": 60}},
+			{"age": 40, "weights": {"A": 90, "B": 10}}
+		]}
+	}]}`
+
+	ps, err := ReadFile(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := GlidePath{
+		Age: 40,
+		Steps: []GlideStep{
+			{Age: 40, Weights: map[string]float64{"A": 90, "B": 10}},
+			{Age: 65, Weights: map[string]float64{"A": 40, "B": 60}},
+		},
+	}
+	if diff := cmp.Diff(want, ps[0].Strategy); diff != "" {
+		t.Errorf("ReadFile() glide path differs (-want/+got):\n%s", diff)
+	}
+
+	for _, input := range []string{
+		`{"portfolios": [{"positions": [{"name": "A", "percent": 100}], "glide_path": {"steps": [{"age": 40, "weights": {"B": 1}}]}}]}`,
+		`{"portfolios": [{"positions": [{"name": "A", "percent": 100}], "glide_path": {"steps": [{"age": 40, "date": "2030-01", "weights": {"A": 1}}]}}]}`,
+		`{"portfolios": [{"positions": [{"name": "A", "percent": 100}], "glide_path": {"steps": [{"weights": {"A": 1}}]}}]}`,
+	} {
+		if _, err := ReadFile(strings.NewReader(input)); err == nil {
+			t.Errorf("ReadFile(%s) = nil, want error", input)
+		}
+	}
+
+	var g GlidePath
+	if err := g.FlagFunc()("40=B:1"); err != nil {
+		t.Fatal(err)
+	}
+	p := Portfolio{Positions: []Position{{Name: "A", Value: 1}}, Strategy: g}
+	if err := p.Validate(newHistory(map[string][]float64{"A": {0}, "B": {0}})); err == nil {
+		t.Error("Validate() = nil, want error for a glide path position not in the portfolio")
+	}
+}
@@ -0,0 +1,251 @@
+package portfolio
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/octo/portfolio-mcmc/timeseries"
+)
+
+// ErrUnreachable is returned by the Goal methods if no value of the searched
+// parameter reaches the target.
+var ErrUnreachable = errors.New("target is unreachable")
+
+// TerminalWealth is the value of a portfolio at the end of the horizon.
+var TerminalWealth = timeseries.Metric{Name: "terminal wealth"}
+
+// Goal is a target wealth that is to be reached with a given confidence.
+// The search methods evaluate all candidates on the same Scenarios, i.e. they
+// use common random numbers, so the result does not jump around with the
+// sampling noise of the individual evaluations.
+type Goal struct {
+	// Target is the wealth to reach at the end of the horizon.
+	Target float64
+	// Confidence is the share of scenarios, in percent, that have to reach
+	// Target.
+	Confidence float64
+	// Months is the horizon.
+	Months int
+	// Scenarios holds the simulated histories, e.g. generated by
+	// timeseries.Generate. They must cover at least Months months.
+	Scenarios []map[string]timeseries.Data
+}
+
+// Distribution returns the distribution of p's terminal wealth across all
+// scenarios.
+func (g Goal) Distribution(p Portfolio) (timeseries.Distribution, error) {
+	d := timeseries.Distribution{Metric: TerminalWealth}
+	for _, sc := range g.Scenarios {
+		values, err := p.EvalValues(&timeseries.Backtest{
+			Data:   sc,
+			Months: g.Months,
+		})
+		if err != nil {
+			return timeseries.Distribution{}, err
+		}
+		if len(values.Data) != g.Months {
+			return timeseries.Distribution{}, fmt.Errorf("scenario has %d months, want %d", len(values.Data), g.Months)
+		}
+		d.Values = append(d.Values, values.Data[len(values.Data)-1].Value)
+	}
+	sort.Float64s(d.Values)
+
+	return d, nil
+}
+
+// TerminalWealthDistribution returns the distribution of the value at the end
+// of each evaluation. Unlike the growth of the returns, it includes cash
+// flows.
+func TerminalWealthDistribution(evaluations []Evaluation) timeseries.Distribution {
+	d := timeseries.Distribution{Metric: TerminalWealth}
+	for _, ev := range evaluations {
+		if n := len(ev.Values.Data); n > 0 {
+			d.Values = append(d.Values, ev.Values.Data[n-1].Value)
+		}
+	}
+	sort.Float64s(d.Values)
+	return d
+}
+
+// Wealth returns the terminal wealth of p that is reached in Confidence
+// percent of the scenarios.
+func (g Goal) Wealth(p Portfolio) (float64, error) {
+	d, err := g.Distribution(p)
+	if err != nil {
+		return 0, err
+	}
+	return d.Percentile(g.Confidence), nil
+}
+
+// Contribution returns the monthly contribution required to reach the goal.
+// It replaces p's cash flow.
+func (g Goal) Contribution(p Portfolio) (float64, error) {
+	return g.search(func(amount float64) Portfolio {
+		p.CashFlow = CashFlow{Amount: amount, Months: 1}
+		return p
+	}, g.Target/float64(g.Months))
+}
+
+// InitialValue returns the initial value of p required to reach the goal.
+// The weights of the positions are kept.
+func (g Goal) InitialValue(p Portfolio) (float64, error) {
+	return g.search(p.WithValue, g.Target)
+}
+
+// search returns the smallest x for which newPortfolio(x) reaches the goal,
+// with a precision of 0.01. Wealth must increase with x. The search starts
+// with the upper bound hi, which is doubled as necessary.
+func (g Goal) search(newPortfolio func(x float64) Portfolio, hi float64) (float64, error) {
+	reached := func(x float64) (bool, error) {
+		w, err := g.Wealth(newPortfolio(x))
+		return w >= g.Target, err
+	}
+
+	if ok, err := reached(0); err != nil || ok {
+		return 0, err
+	}
+
+	hi = math.Max(hi, 1)
+	for i := 0; ; i++ {
+		ok, err := reached(hi)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			break
+		}
+		if i == 30 {
+			return 0, ErrUnreachable
+		}
+		hi *= 2
+	}
+
+	lo := 0.0
+	for hi-lo > 0.01 {
+		mid := (lo + hi) / 2
+		ok, err := reached(mid)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+
+	return hi, nil
+}
+
+// Horizon returns the first month at the end of which the goal is reached.
+// Months is ignored; all months of the scenarios are searched.
+func (g Goal) Horizon(p Portfolio) (int, error) {
+	var paths []timeseries.Data
+	for _, sc := range g.Scenarios {
+		values, err := p.EvalValues(&timeseries.Backtest{Data: sc})
+		if err != nil {
+			return 0, err
+		}
+		paths = append(paths, values)
+	}
+	if len(paths) == 0 {
+		return 0, ErrUnreachable
+	}
+
+	for m := range paths[0].Data {
+		d := timeseries.Distribution{Metric: TerminalWealth}
+		for _, path := range paths {
+			if m < len(path.Data) {
+				d.Values = append(d.Values, path.Data[m].Value)
+			}
+		}
+		sort.Float64s(d.Values)
+
+		if d.Percentile(g.Confidence) >= g.Target {
+			return m + 1, nil
+		}
+	}
+
+	return 0, ErrUnreachable
+}
+
+// EquityShare returns the lowest share of the positions named equity, in
+// percent of the portfolio's value, that reaches the goal. The share is
+// searched in steps of one percentage point. The weights within the equity
+// and the remaining positions are kept.
+func (g Goal) EquityShare(p Portfolio, equity []string) (float64, error) {
+	for share := 0.0; share <= 100; share++ {
+		q, err := p.WithShare(equity, share)
+		if err != nil {
+			return 0, err
+		}
+		w, err := g.Wealth(q)
+		if err != nil {
+			return 0, err
+		}
+		if w >= g.Target {
+			return share, nil
+		}
+	}
+
+	return 0, ErrUnreachable
+}
+
+// WithValue returns a copy of p with the positions scaled to a total value of
+// v. As the weights are derived from the values, the positions keep a
+// negligible value if v is zero.
+func (p Portfolio) WithValue(v float64) Portfolio {
+	total := sum(p.Positions)
+	if v <= 0 {
+		v = 1e-9
+	}
+
+	positions := make([]Position, len(p.Positions))
+	for i, pos := range p.Positions {
+		pos.Value *= v / total
+		positions[i] = pos
+	}
+	p.Positions = positions
+	return p
+}
+
+// WithShare returns a copy of p in which the positions named in group make
+// up share percent of the total value. The weights within the group and
+// within the remaining positions are kept.
+func (p Portfolio) WithShare(group []string, share float64) (Portfolio, error) {
+	in := map[string]bool{}
+	for _, name := range group {
+		if p.position(name).Name == "" {
+			return Portfolio{}, fmt.Errorf("no position %q", name)
+		}
+		in[name] = true
+	}
+
+	var inSum, outSum float64
+	for _, pos := range p.Positions {
+		if in[pos.Name] {
+			inSum += pos.Value
+		} else {
+			outSum += pos.Value
+		}
+	}
+	if inSum <= 0 || outSum <= 0 {
+		return Portfolio{}, fmt.Errorf("positions %s and the remaining positions must both have a value", strings.Join(group, ", "))
+	}
+
+	total := inSum + outSum
+	positions := make([]Position, len(p.Positions))
+	for i, pos := range p.Positions {
+		if in[pos.Name] {
+			pos.Value *= total * share / 100 / inSum
+		} else {
+			pos.Value *= total * (100 - share) / 100 / outSum
+		}
+		positions[i] = pos
+	}
+	p.Positions = positions
+	return p, nil
+}
@@ -0,0 +1,117 @@
+package portfolio
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/octo/portfolio-mcmc/timeseries"
+)
+
+func TestGoal(t *testing.T) {
+	flat := newHistory(map[string][]float64{"A": repeat(0, 12)})
+	p := Portfolio{
+		Positions: []Position{{Name: "A", Value: 1}},
+	}
+
+	g := Goal{
+		Target:     1200,
+		Confidence: 90,
+		Months:     12,
+		Scenarios:  []map[string]timeseries.Data{flat},
+	}
+
+	got, err := g.Contribution(p.WithValue(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(got-100) > 0.01 {
+		t.Errorf("Contribution() = %g, want 100", got)
+	}
+
+	got, err = g.InitialValue(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(got-1200) > 0.01 {
+		t.Errorf("InitialValue() = %g, want 1200", got)
+	}
+
+	g.Target = 600
+	q := p.WithValue(0)
+	q.CashFlow = CashFlow{Amount: 100, Months: 1}
+	months, err := g.Horizon(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if months != 6 {
+		t.Errorf("Horizon() = %d, want 6", months)
+	}
+
+	g.Target = 2000
+	if _, err := g.Horizon(q); !errors.Is(err, ErrUnreachable) {
+		t.Errorf("Horizon() = %v, want ErrUnreachable", err)
+	}
+}
+
+func TestGoalConfidence(t *testing.T) {
+	p := Portfolio{
+		Positions: []Position{{Name: "A", Value: 100}},
+	}
+	g := Goal{
+		Months: 12,
+		Scenarios: []map[string]timeseries.Data{
+			newHistory(map[string][]float64{"A": repeat(.01, 12)}),
+			newHistory(map[string][]float64{"A": repeat(0, 12)}),
+		},
+	}
+
+	for _, tc := range []struct {
+		confidence float64
+		want       float64
+	}{
+		{50, 100 * math.Pow(1.01, 12)},
+		{90, 100},
+	} {
+		g.Confidence = tc.confidence
+		got, err := g.Wealth(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("Wealth() at %g%% confidence = %g, want %g", tc.confidence, got, tc.want)
+		}
+	}
+}
+
+func TestGoalEquityShare(t *testing.T) {
+	p := Portfolio{
+		Positions: []Position{
+			{Name: "A", Value: 50},
+			{Name: "B", Value: 50},
+		},
+	}
+	g := Goal{
+		Target:     110,
+		Confidence: 90,
+		Months:     12,
+		Scenarios: []map[string]timeseries.Data{newHistory(map[string][]float64{
+			"A": {.01, .01, .01, .01, .01, .01, .01, .01, .01, .01, .01, .01},
+			"B": make([]float64, 12),
+		})},
+	}
+
+	// 100 * (s * 1.01^12 + 1 - s) >= 110 requires s >= 78.85%.
+	got, err := g.EquityShare(p, []string{"A"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 79 {
+		t.Errorf("EquityShare() = %g, want 79", got)
+	}
+
+	g.Target = 120
+	if _, err := g.EquityShare(p, []string{"A"}); !errors.Is(err, ErrUnreachable) {
+		t.Errorf("EquityShare() = %v, want ErrUnreachable", err)
+	}
+}
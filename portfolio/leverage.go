@@ -0,0 +1,205 @@
+package portfolio
+
+import (
+	"fmt"
+	"math"
+)
+
+// CashPosition is the name of the cash position. It is not read from the
+// historic data but earns the portfolio's Interest. A negative cash position
+// is a loan, i.e. the remaining positions add up to more than 100% of the
+// portfolio's value.
+const CashPosition = "CASH"
+
+// Interest is the rate earned by the cash position.
+type Interest struct {
+	// Rate is the annual interest rate in percent. It is used if Series is
+	// empty.
+	Rate float64 `json:"rate,omitempty"`
+	// Series is the name of a series of monthly returns, e.g. of a money
+	// market fund, that is used as the interest rate.
+	Series string `json:"series,omitempty"`
+}
+
+// Borrowing configures loans, i.e. negative cash positions.
+type Borrowing struct {
+	// Spread is the annual interest paid on top of the Interest, in percent.
+	Spread float64 `json:"spread,omitempty"`
+	// Maintenance is the lowest ratio of the portfolio's value to its
+	// assets, in percent. If the ratio falls below, assets are sold to repay
+	// the loan until the ratio is restored (margin call). Zero disables
+	// margin calls.
+	Maintenance float64 `json:"maintenance,omitempty"`
+}
+
+// Leverage returns the value of all positions except a loan relative to the
+// value of the portfolio, e.g. 1.5 for a portfolio with a loan of half its
+// value.
+func (p Portfolio) Leverage() float64 {
+	var assets, value float64
+	for _, pos := range p.Positions {
+		if pos.Value > 0 {
+			assets += pos.Value
+		}
+		value += pos.Value
+	}
+	return assets / value
+}
+
+// maxLeverage returns the highest leverage that meets the maintenance margin,
+// or +Inf if margin calls are disabled.
+func (b Borrowing) maxLeverage() float64 {
+	if b.Maintenance <= 0 {
+		return math.Inf(1)
+	}
+	return 100 / b.Maintenance
+}
+
+// checkLeverage returns an error if the portfolio's leverage is above the
+// maximum allowed by its maintenance margin, i.e. if it would face a margin
+// call right away.
+func (p Portfolio) checkLeverage() error {
+	if sum(p.Positions) <= 0 {
+		return nil
+	}
+	if l, max := p.Leverage(), p.Borrowing.maxLeverage(); l > max*(1+1e-9) {
+		return fmt.Errorf("leverage %.2f exceeds %.2f, the maximum for a maintenance of %g%%", l, max, p.Borrowing.Maintenance)
+	}
+	return nil
+}
+
+// WithoutCash returns a copy of p without the cash position, interest and
+// borrowing.
+func (p Portfolio) WithoutCash() Portfolio {
+	var positions []Position
+	for _, pos := range p.Positions {
+		if pos.Name != CashPosition {
+			positions = append(positions, pos)
+		}
+	}
+	p.Positions = positions
+	p.Interest, p.Borrowing = Interest{}, Borrowing{}
+	return p
+}
+
+// isCash returns true if position i is the cash position.
+func (s *simulation) isCash(i int) bool {
+	return s.positions[i].Name == CashPosition
+}
+
+// interest returns the monthly factor by which the cash position grows. Loans
+// pay the borrowing spread in addition.
+func (s *simulation) interest(qp QuoteProvider, loan bool) (float64, error) {
+	var r float64
+	if series := s.p.Interest.Series; series != "" {
+		rv, err := qp.RelativeValue(series)
+		if err != nil {
+			return 0, err
+		}
+		r = rv - 1
+	} else {
+		r = monthly(s.p.Interest.Rate)
+	}
+
+	if loan {
+		r += monthly(s.p.Borrowing.Spread)
+	}
+	return 1 + r, nil
+}
+
+// monthly converts an annual rate in percent to a monthly rate.
+func monthly(annual float64) float64 {
+	return math.Pow(1+annual/100, 1.0/12) - 1
+}
+
+// marginCall sells assets to repay the loan if the portfolio's value fell
+// below the maintenance margin. It returns true if assets were sold.
+func (s *simulation) marginCall() bool {
+	m := s.p.Borrowing.Maintenance / 100
+	if m <= 0 {
+		return false
+	}
+
+	var assets, loan float64
+	cash := -1
+	for i, pos := range s.positions {
+		switch {
+		case s.isCash(i) && pos.Value < 0:
+			loan, cash = -pos.Value, i
+		case pos.Value > 0:
+			assets += pos.Value
+		}
+	}
+	if loan == 0 {
+		return false
+	}
+
+	value := assets - loan
+	if value >= m*assets {
+		return false
+	}
+
+	// Sell enough assets to restore the maintenance margin; if the loan
+	// exceeds the assets, everything is sold and the portfolio is wiped out.
+	sale := assets
+	if value > 0 {
+		sale = assets - value/m
+	}
+
+	var proceeds float64
+	for i, pos := range s.positions {
+		if i != cash && pos.Value > 0 {
+			proceeds += s.sell(i, sale*pos.Value/assets)
+		}
+	}
+	s.positions[cash].Value = math.Min(0, proceeds-loan)
+	if s.value() <= 0 {
+		for i := range s.positions {
+			s.positions[i].Value = 0
+		}
+	}
+
+	return true
+}
+
+// capped returns the targets with the leverage limited to the maintenance
+// margin: the assets are scaled down and the loan is reduced accordingly, so
+// that rebalancing never borrows past the limit.
+func (s *simulation) capped() []float64 {
+	max := s.p.Borrowing.maxLeverage()
+
+	var assets float64
+	cash := -1
+	for i, w := range s.targets {
+		switch {
+		case s.isCash(i):
+			cash = i
+		case w > 0:
+			assets += w
+		}
+	}
+	if cash < 0 || assets <= max {
+		return s.targets
+	}
+
+	ret := make([]float64, len(s.targets))
+	for i, w := range s.targets {
+		if i != cash {
+			ret[i] = w * max / assets
+		}
+	}
+	ret[cash] = 1 - max
+	return ret
+}
+
+// interestSeries returns the names of the series required for the interest of
+// the portfolios' cash positions.
+func interestSeries(portfolios []Portfolio) []string {
+	var ret []string
+	for _, p := range portfolios {
+		if p.Interest.Series != "" && p.position(CashPosition).Name != "" {
+			ret = append(ret, p.Interest.Series)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,225 @@
+package portfolio
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/octo/portfolio-mcmc/timeseries"
+)
+
+func TestCash(t *testing.T) {
+	hist := newHistory(map[string][]float64{
+		"A":  {0, 0, 0},
+		"MM": {.01, .01, .01},
+	})
+
+	cases := []struct {
+		name     string
+		interest Interest
+		want     float64
+	}{
+		{"rate", Interest{Rate: 12}, math.Pow(1.12, 3.0/12)},
+		{"series", Interest{Series: "MM"}, math.Pow(1.01, 3)},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := Portfolio{
+				Positions: []Position{{Name: CashPosition, Value: 100}},
+				Interest:  tc.interest,
+			}
+			res, err := p.Eval(&timeseries.Backtest{Data: hist})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := res.Growth(); math.Abs(got-tc.want) > 1e-9 {
+				t.Errorf("Growth() = %g, want %g", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLeverage(t *testing.T) {
+	p := Portfolio{
+		Positions: []Position{
+			{Name: "A", Value: 200},
+			{Name: CashPosition, Value: -100},
+		},
+		Borrowing: Borrowing{Spread: 12, Maintenance: 25},
+	}
+	if got, want := p.Leverage(), 2.0; got != want {
+		t.Errorf("Leverage() = %g, want %g", got, want)
+	}
+
+	cases := []struct {
+		name            string
+		returns         float64
+		wantValue       float64
+		wantMarginCalls int
+	}{
+		{
+			name:      "gain",
+			returns:   .1,
+			wantValue: 220 - 100*(1+monthly(12)),
+		},
+		{
+			// The value of about 19 is 15.9% of the assets of 120, so
+			// assets are sold to restore 25%.
+			name:            "margin call",
+			returns:         -.4,
+			wantValue:       120 - 100*(1+monthly(12)),
+			wantMarginCalls: 1,
+		},
+		{
+			name:            "wiped out",
+			returns:         -.6,
+			wantValue:       0,
+			wantMarginCalls: 1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ev, err := p.Evaluate(&timeseries.Backtest{
+				Data: newHistory(map[string][]float64{"A": {tc.returns}}),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ev.Values.Data[0].Value; math.Abs(got-tc.wantValue) > 1e-9 {
+				t.Errorf("value = %g, want %g", got, tc.wantValue)
+			}
+			if ev.MarginCalls != tc.wantMarginCalls {
+				t.Errorf("MarginCalls = %d, want %d", ev.MarginCalls, tc.wantMarginCalls)
+			}
+		})
+	}
+}
+
+func TestMarginCallRestoresMaintenance(t *testing.T) {
+	p := Portfolio{
+		Positions: []Position{
+			{Name: "A", Value: 200},
+			{Name: CashPosition, Value: -100},
+		},
+		Borrowing: Borrowing{Maintenance: 25},
+	}
+
+	s := newSimulation(p)
+	s.grow(0, .6)
+	if !s.marginCall() {
+		t.Fatal("marginCall() = false, want true")
+	}
+
+	want := []float64{80, -60}
+	var got []float64
+	for _, pos := range s.positions {
+		got = append(got, pos.Value)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("positions differ (-want/+got):\n%s", diff)
+	}
+}
+
+func TestMarginCallLowersLeverage(t *testing.T) {
+	p := Portfolio{
+		Positions: []Position{
+			{Name: "A", Value: 200},
+			{Name: CashPosition, Value: -100},
+		},
+		Rebalance: 1,
+		Borrowing: Borrowing{Maintenance: 40},
+	}
+
+	// The margin call in the first month sells A down to 50 and repays the
+	// loan down to 30, a leverage of 2.5. Rebalancing in the same month would
+	// borrow again and restore the leverage of 2, i.e. 40 in A and a loan of
+	// 20, and the second month would end at 40 instead of 45.
+	ev, err := p.Evaluate(&timeseries.Backtest{
+		Data: newHistory(map[string][]float64{"A": {-.4, .5}}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []float64
+	for _, d := range ev.Values.Data {
+		got = append(got, d.Value)
+	}
+	if diff := cmp.Diff([]float64{20, 45}, got, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("EvalValues() differs (-want/+got):\n%s", diff)
+	}
+	if ev.MarginCalls != 1 {
+		t.Errorf("MarginCalls = %d, want 1", ev.MarginCalls)
+	}
+}
+
+func TestRebalanceCapsLeverage(t *testing.T) {
+	p := Portfolio{
+		Positions: []Position{
+			{Name: "A", Value: 60},
+			{Name: "B", Value: 40},
+			{Name: CashPosition, Value: 0},
+		},
+		Borrowing: Borrowing{Maintenance: 40},
+	}
+
+	// A strategy asking for a leverage of 3 only gets 2.5.
+	s := newSimulation(p)
+	s.targets = []float64{1.8, 1.2, -2}
+	s.rebalance()
+
+	want := []float64{150, 100, -150}
+	var got []float64
+	for _, pos := range s.positions {
+		got = append(got, pos.Value)
+	}
+	if diff := cmp.Diff(want, got, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("positions differ (-want/+got):\n%s", diff)
+	}
+}
+
+func TestCheckLeverage(t *testing.T) {
+	p := Portfolio{
+		Positions: []Position{
+			{Name: "A", Value: 300},
+			{Name: CashPosition, Value: -200},
+		},
+		Borrowing: Borrowing{Maintenance: 40},
+	}
+	hist := newHistory(map[string][]float64{"A": {0}})
+	if err := p.Validate(hist); err == nil {
+		t.Error("Validate() = nil, want error for leverage above 2.5")
+	}
+
+	p.Borrowing.Maintenance = 30
+	if err := p.Validate(hist); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+func TestNamesCash(t *testing.T) {
+	p := Portfolio{
+		Positions: []Position{
+			{Name: "A", Value: 150},
+			{Name: CashPosition, Value: -50},
+		},
+		Interest: Interest{Series: "MM"},
+	}
+
+	if diff := cmp.Diff([]string{"A", "MM"}, Names(p)); diff != "" {
+		t.Errorf("Names() differs (-want/+got):\n%s", diff)
+	}
+
+	hist := newHistory(map[string][]float64{"A": {0}, "MM": {0}})
+	if err := p.Validate(hist); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+
+	p.Positions[0].Value = -50
+	if err := p.Validate(hist); err == nil {
+		t.Error("Validate() = nil, want error for negative position")
+	}
+}
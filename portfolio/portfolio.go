@@ -3,6 +3,7 @@ package portfolio
 
 import (
 	"fmt"
+	"math"
 	"math/rand"
 	"sort"
 	"strconv"
@@ -13,12 +14,78 @@ import (
 )
 
 type Portfolio struct {
+	Name      string
 	Positions []Position
+
+	// Owner, Account and Notes are informational only.
+	Owner, Account, Notes string
+
+	// Rebalance is the number of months between resetting the positions to
+	// their initial weights. Zero disables periodic rebalancing.
+	Rebalance int
+	// RebalanceThreshold rebalances the portfolio whenever the weight of a
+	// position deviates from its initial weight by more than this many
+	// percentage points. Zero disables threshold rebalancing.
+	RebalanceThreshold float64
+	// Strategy changes the target weights over time. It is nil if the
+	// weights are constant. If neither Rebalance nor RebalanceThreshold is
+	// set, a GlidePath is rebalanced annually and other strategies whenever a
+	// target weight moved by more than five percentage points since the last
+	// rebalancing.
+	Strategy Strategy
+
+	CashFlow     CashFlow
+	TradingCosts TradingCosts
+	// Interest and Borrowing apply to the cash position, see CashPosition.
+	Interest  Interest
+	Borrowing Borrowing
+	// Tax is nil if taxes are not considered.
+	Tax *Tax
+}
+
+// CashFlow is a regular contribution to or withdrawal from a portfolio.
+// Contributions are split according to the initial weights, withdrawals
+// according to the current weights of the positions.
+type CashFlow struct {
+	// Amount is added every Months months. Negative amounts are withdrawn.
+	Amount float64
+	Months int
+}
+
+// TradingCosts are charged whenever contributions, withdrawals or rebalancing
+// buy or sell a position.
+type TradingCosts struct {
+	// Fee is the fixed fee per trade.
+	Fee float64 `json:"fee"`
+	// Spread is the cost of a trade in percent of the traded amount.
+	Spread float64 `json:"spread"`
+}
+
+func (c TradingCosts) cost(amount float64) float64 {
+	if amount == 0 {
+		return 0
+	}
+	return c.Fee + c.Spread/100*math.Abs(amount)
 }
 
 type Position struct {
 	Name  string
 	Value float64
+
+	// TER is the annual total expense ratio in percent.
+	TER float64
+	// TrackingDifference is the annual underperformance relative to the
+	// index beyond the TER, in percent. Negative values mean the fund
+	// outperforms its index.
+	TrackingDifference float64
+	// FundType determines the partial tax exemption, see Tax.
+	FundType string
+}
+
+// costFactor returns the monthly factor by which the running costs of the
+// position reduce its value.
+func (pos Position) costFactor() float64 {
+	return math.Pow(1-(pos.TER+pos.TrackingDifference)/100, 1.0/12)
 }
 
 type QuoteProvider interface {
@@ -39,6 +106,9 @@ func (p Portfolio) String() string {
 		}
 		fmt.Fprintf(&b, "%2.0f%% %s", 100*pos.Value/sum, pos.Name)
 	}
+	if p.Strategy != nil {
+		fmt.Fprintf(&b, " (weights follow %s)", p.Strategy)
+	}
 
 	return b.String()
 }
@@ -52,6 +122,40 @@ func (p Portfolio) Position(name string) float64 {
 	return 0
 }
 
+func (p Portfolio) position(name string) Position {
+	for _, pos := range p.Positions {
+		if pos.Name == name {
+			return pos
+		}
+	}
+	return Position{}
+}
+
+// Names returns the sorted names of the series required to evaluate all given
+// portfolios, i.e. the names of their positions except the cash position and
+// the series of their interest.
+func Names(portfolios ...Portfolio) []string {
+	seen := map[string]bool{CashPosition: true}
+	var ret []string
+	for _, p := range portfolios {
+		for _, pos := range p.Positions {
+			if !seen[pos.Name] {
+				seen[pos.Name] = true
+				ret = append(ret, pos.Name)
+			}
+		}
+	}
+	for _, name := range interestSeries(portfolios) {
+		if !seen[name] {
+			seen[name] = true
+			ret = append(ret, name)
+		}
+	}
+	sort.Strings(ret)
+
+	return ret
+}
+
 func (p Portfolio) CSV() string {
 	var fields []string
 
@@ -66,65 +170,323 @@ func (p Portfolio) CSV() string {
 	return strings.Join(fields, ",")
 }
 
+// Eval simulates the portfolio using the quotes provided by qp and returns
+// its monthly returns. Cash flows do not affect the returns, i.e. they are
+// time-weighted returns.
 func (p Portfolio) Eval(qp QuoteProvider) (timeseries.Data, error) {
-	positions := make([]Position, len(p.Positions))
-	copy(positions, p.Positions)
+	ev, err := p.Evaluate(qp)
+	return ev.Returns, err
+}
 
-	ret := timeseries.Data{
-		Name: "Simulated Portfolio",
+// EvalValues simulates the portfolio like Eval but returns the value of the
+// portfolio at the end of each month, after cash flows.
+func (p Portfolio) EvalValues(qp QuoteProvider) (timeseries.Data, error) {
+	ev, err := p.Evaluate(qp)
+	return ev.Values, err
+}
+
+// Evaluation is the detailed result of simulating a portfolio.
+type Evaluation struct {
+	// Returns holds the time-weighted monthly returns, net of costs and
+	// taxes paid.
+	Returns timeseries.Data
+	// Values holds the value at the end of each month, after cash flows.
+	Values timeseries.Data
+	// TaxPaid is the sum of all taxes paid during the simulation.
+	TaxPaid float64
+	// LiquidationTax is the tax that would be due if the portfolio was
+	// sold at the end of the simulation.
+	LiquidationTax float64
+	// MarginCalls is the number of months in which assets were sold to
+	// meet the maintenance margin of a loan.
+	MarginCalls int
+}
+
+// AfterTaxGrowth returns the growth of the portfolio assuming it is sold at
+// the end of the simulation and the LiquidationTax is paid.
+func (ev Evaluation) AfterTaxGrowth() float64 {
+	g := ev.Returns.Growth()
+	if n := len(ev.Values.Data); n > 0 && ev.Values.Data[n-1].Value > 0 {
+		g *= 1 - ev.LiquidationTax/ev.Values.Data[n-1].Value
 	}
+	return g
+}
+
+// AfterTaxReturns returns the annualized returns, in percent, corresponding
+// to AfterTaxGrowth.
+func (ev Evaluation) AfterTaxReturns() float64 {
+	years := float64(len(ev.Returns.Data)) / 12
+	return 100 * (math.Pow(ev.AfterTaxGrowth(), 1/years) - 1)
+}
+
+// Evaluate simulates the portfolio using the quotes provided by qp.
+func (p Portfolio) Evaluate(qp QuoteProvider) (Evaluation, error) {
+	s := newSimulation(p)
 
-	var prevValue float64
-	for _, p := range positions {
-		prevValue += p.Value
+	ev := Evaluation{
+		Returns: timeseries.Data{
+			Name: "Simulated Portfolio",
+		},
+		Values: timeseries.Data{
+			Name: "Simulated Portfolio",
+		},
 	}
 
-	for {
+	prevValue := s.value()
+	for month := 1; ; month++ {
 		date, ok := qp.Next()
 		if !ok {
 			break
 		}
 
-		var nextValue float64
-		for i := 0; i < len(positions); i++ {
-			rv, err := qp.RelativeValue(positions[i].Name)
+		if month == 1 && p.Strategy != nil {
+			s.history.Start = date
+			s.follow()
+		}
+
+		// Taxes for the previous year are due at the start of the year.
+		if s.tax != nil && month > 1 && date.Year() != s.tax.year {
+			s.payTax()
+		}
+		if s.tax != nil {
+			s.tax.startMonth(date, s.positions)
+		}
+
+		for i := range s.positions {
+			var (
+				rv  float64
+				err error
+			)
+			if s.isCash(i) {
+				rv, err = s.interest(qp, s.positions[i].Value < 0)
+			} else {
+				rv, err = qp.RelativeValue(s.positions[i].Name)
+			}
 			if err != nil {
-				return timeseries.Data{}, err
+				return Evaluation{}, err
 			}
 
-			positions[i].Value *= rv
-			nextValue += positions[i].Value
+			s.grow(i, rv*s.factors[i])
+			s.returns[i] = rv - 1
+		}
+		// Rebalancing right after a margin call would borrow again what
+		// was just repaid; it waits for the next month instead.
+		marginCall := s.marginCall()
+		if marginCall {
+			ev.MarginCalls++
+		}
+		if p.Strategy != nil {
+			s.observe(date, s.returns)
+			s.follow()
 		}
 
-		ret.Data = append(ret.Data, timeseries.Datum{
+		var flow float64
+		if cf := p.CashFlow; cf.Months > 0 && month%cf.Months == 0 {
+			flow = s.applyCashFlow(cf.Amount)
+		}
+		if !marginCall && (p.rebalanceDue(month, s.positions, s.targets) || s.shifted()) {
+			s.rebalance()
+		}
+
+		// Costs and taxes reduce the returns, the cash flow itself does not.
+		nextValue := s.value()
+		var r float64
+		if prevValue > 0 {
+			r = (nextValue-flow)/prevValue - 1
+		}
+		ev.Returns.Data = append(ev.Returns.Data, timeseries.Datum{
 			Date:  date,
-			Value: nextValue/prevValue - 1,
+			Value: r,
+		})
+		ev.Values.Data = append(ev.Values.Data, timeseries.Datum{
+			Date:  date,
+			Value: nextValue,
 		})
 		prevValue = nextValue
 	}
 
+	if s.tax != nil {
+		ev.TaxPaid = s.tax.paid
+		ev.LiquidationTax = s.tax.liquidation()
+	}
+
+	return ev, nil
+}
+
+func (p Portfolio) rebalanceDue(month int, positions []Position, targets []float64) bool {
+	value := sum(positions)
+	if value <= 0 {
+		return false
+	}
+	if p.Rebalance > 0 && month%p.Rebalance == 0 {
+		return true
+	}
+	// A glide path is followed annually unless specified otherwise.
+	if _, ok := p.Strategy.(GlidePath); ok && p.Rebalance == 0 && p.RebalanceThreshold == 0 && month%12 == 0 {
+		return true
+	}
+	if p.RebalanceThreshold > 0 {
+		for i, pos := range positions {
+			if 100*math.Abs(pos.Value/value-targets[i]) > p.RebalanceThreshold {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasCosts returns true if any running or trading costs are set.
+func (p Portfolio) HasCosts() bool {
+	if p.TradingCosts != (TradingCosts{}) {
+		return true
+	}
+	for _, pos := range p.Positions {
+		if pos.TER != 0 || pos.TrackingDifference != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// WithoutFundCosts returns a copy of p without TER and tracking difference.
+func (p Portfolio) WithoutFundCosts() Portfolio {
+	positions := make([]Position, len(p.Positions))
+	for i, pos := range p.Positions {
+		pos.TER, pos.TrackingDifference = 0, 0
+		positions[i] = pos
+	}
+	p.Positions = positions
+	return p
+}
+
+// WithoutCosts returns a copy of p without any costs.
+func (p Portfolio) WithoutCosts() Portfolio {
+	p = p.WithoutFundCosts()
+	p.TradingCosts = TradingCosts{}
+	return p
+}
+
+// CostDrag is the reduction of the annualized returns caused by costs, in
+// percentage points.
+type CostDrag struct {
+	// Fund is the drag caused by TER and tracking difference.
+	Fund float64
+	// Trading is the drag caused by trading costs.
+	Trading float64
+}
+
+// Total returns the combined cost drag.
+func (d CostDrag) Total() float64 {
+	return d.Fund + d.Trading
+}
+
+// CostDrag evaluates p with and without costs and returns the difference in
+// annualized returns. newQP is called for every evaluation and must return
+// quote providers yielding the same quotes, e.g. a new Backtest.
+func (p Portfolio) CostDrag(newQP func() QuoteProvider) (CostDrag, error) {
+	noTrading := p
+	noTrading.TradingCosts = TradingCosts{}
+
+	var returns []float64
+	for _, q := range []Portfolio{p.WithoutCosts(), noTrading, p} {
+		res, err := q.Eval(newQP())
+		if err != nil {
+			return CostDrag{}, err
+		}
+		returns = append(returns, res.Returns())
+	}
+
+	return CostDrag{
+		Fund:    returns[0] - returns[1],
+		Trading: returns[1] - returns[2],
+	}, nil
+}
+
+// HoldingPeriods evaluates the portfolio for every period of the given number
+// of consecutive months in hist, i.e. it starts the portfolio at every possible
+// month and holds it for months months. Periods that would extend past the end
+// of hist are omitted.
+func (p Portfolio) HoldingPeriods(hist map[string]timeseries.Data, months int) ([]timeseries.Data, error) {
+	size := -1
+	for _, h := range hist {
+		if size == -1 || size > len(h.Data) {
+			size = len(h.Data)
+		}
+	}
+
+	var ret []timeseries.Data
+	for start := 0; start+months <= size; start++ {
+		res, err := p.Eval(&timeseries.Backtest{
+			Data:   hist,
+			Start:  start,
+			Months: months,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, res)
+	}
+
 	return ret, nil
 }
 
 // FlagFunc returns a function that can be passed to flag.Func() for flag parsing.
 func (p *Portfolio) FlagFunc() func(string) error {
 	return func(flagValue string) error {
-		fields := strings.Split(flagValue, ":")
-		if len(fields) != 2 {
-			return fmt.Errorf(`got %q, want "<name>:<weight>"`, flagValue)
+		pos, err := parsePosition(flagValue)
+		if err != nil {
+			return err
 		}
 
-		weight, err := strconv.ParseFloat(fields[1], 64)
+		p.Positions = append(p.Positions, pos)
+		return nil
+	}
+}
+
+// Parse parses a portfolio in the form
+// "[<name>=]<position>:<weight>[,<position>:<weight>]...".
+func Parse(s string) (Portfolio, error) {
+	var p Portfolio
+	if i := strings.Index(s, "="); i != -1 {
+		p.Name = s[:i]
+		s = s[i+1:]
+	}
+
+	for _, field := range strings.Split(s, ",") {
+		pos, err := parsePosition(field)
 		if err != nil {
-			return fmt.Errorf("ParseFloat(%q): %w", fields[1], err)
+			return Portfolio{}, err
 		}
+		p.Positions = append(p.Positions, pos)
+	}
 
-		p.Positions = append(p.Positions, Position{
-			Name:  fields[0],
-			Value: weight,
-		})
-		return nil
+	return p, nil
+}
+
+// parsePosition parses a position in the form "<name>:<weight>[:<ter>]".
+func parsePosition(s string) (Position, error) {
+	fields := strings.Split(s, ":")
+	if len(fields) != 2 && len(fields) != 3 {
+		return Position{}, fmt.Errorf(`got %q, want "<name>:<weight>[:<ter>]"`, s)
+	}
+
+	weight, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return Position{}, fmt.Errorf("ParseFloat(%q): %w", fields[1], err)
 	}
+
+	pos := Position{
+		Name:  fields[0],
+		Value: weight,
+	}
+	if len(fields) == 3 {
+		if pos.TER, err = strconv.ParseFloat(fields[2], 64); err != nil {
+			return Position{}, fmt.Errorf("ParseFloat(%q): %w", fields[2], err)
+		}
+	}
+
+	return pos, nil
 }
 
 // Recombine combines two portfolios, p0 and p1, to create a "child" portfolio.
@@ -163,12 +525,17 @@ func Recombine(p0, p1 Portfolio) Portfolio {
 		sum += positions[name]
 	}
 
-	var ret Portfolio
+	ret := Portfolio{
+		TradingCosts: p0.TradingCosts,
+	}
 	for _, name := range names {
-		ret.Positions = append(ret.Positions, Position{
-			Name:  name,
-			Value: 100000 * positions[name] / sum,
-		})
+		pos := p0.position(name)
+		if pos.Name == "" {
+			pos = p1.position(name)
+		}
+		pos.Name = name
+		pos.Value = 100000 * positions[name] / sum
+		ret.Positions = append(ret.Positions, pos)
 	}
 	return ret
 }
@@ -0,0 +1,288 @@
+package portfolio
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/octo/portfolio-mcmc/timeseries"
+)
+
+// newHistory returns one series per name with the given monthly returns.
+func newHistory(returns map[string][]float64) map[string]timeseries.Data {
+	ret := map[string]timeseries.Data{}
+	for name, values := range returns {
+		h := timeseries.Data{
+			Name: name,
+		}
+		for i, v := range values {
+			h.Data = append(h.Data, timeseries.Datum{
+				Date:  time.Date(2000, time.January+time.Month(i), 1, 0, 0, 0, 0, time.UTC),
+				Value: v,
+			})
+		}
+		ret[name] = h
+	}
+	return ret
+}
+
+// repeat returns n copies of v.
+func repeat(v float64, n int) []float64 {
+	ret := make([]float64, n)
+	for i := range ret {
+		ret[i] = v
+	}
+	return ret
+}
+
+func TestReadFile(t *testing.T) {
+	input := `{
+  "portfolios": [
+    {
+      "name": "retirement",
+      "owner": "alice",
+      "value": 1000,
+      "positions": [
+        {"name": "STOCKS", "percent": 60},
+        {"name": "BONDS", "percent": 40}
+      ],
+      "rebalance": "quarterly",
+      "cash_flow": {"amount": -10, "frequency": "annually"}
+    },
+    {
+      "name": "play money",
+      "positions": [{"name": "STOCKS", "amount": 500}]
+    }
+  ]
+}`
+
+	want := []Portfolio{
+		{
+			Name:  "retirement",
+			Owner: "alice",
+			Positions: []Position{
+				{Name: "STOCKS", Value: 600},
+				{Name: "BONDS", Value: 400},
+			},
+			Rebalance: 3,
+			CashFlow:  CashFlow{Amount: -10, Months: 12},
+		},
+		{
+			Name:      "play money",
+			Positions: []Position{{Name: "STOCKS", Value: 500}},
+		},
+	}
+
+	got, err := ReadFile(strings.NewReader(input))
+	if err != nil {
+		t.Fatal("ReadFile(): ", err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("ReadFile() differs (-want/+got):\n%s", diff)
+	}
+}
+
+func TestReadFileErrors(t *testing.T) {
+	cases := map[string]string{
+		"mixed":     `{"portfolios": [{"positions": [{"name": "A", "amount": 1}, {"name": "B", "percent": 50}]}]}`,
+		"sum":       `{"portfolios": [{"positions": [{"name": "A", "percent": 60}, {"name": "B", "percent": 50}]}]}`,
+		"duplicate": `{"portfolios": [{"positions": [{"name": "A", "amount": 1}, {"name": "A", "amount": 2}]}]}`,
+		"zero":      `{"portfolios": [{"positions": [{"name": "A", "percent": 100}, {"name": "CASH", "percent": 0}, {"name": "CASH", "percent": 0}]}]}`,
+		"frequency": `{"portfolios": [{"positions": [{"name": "A", "amount": 1}], "rebalance": "weekly"}]}`,
+		"leverage":  `{"portfolios": [{"positions": [{"name": "A", "percent": 300}, {"name": "CASH", "percent": -200}], "borrowing": {"maintenance": 40}}]}`,
+		"unknown":   `{"portfolios": [{"positions": [{"name": "A", "amount": 1}], "colour": "blue"}]}`,
+		"empty":     `{"portfolios": []}`,
+	}
+
+	for name, input := range cases {
+		if _, err := ReadFile(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: ReadFile() = nil, want error", name)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	hist := newHistory(map[string][]float64{
+		"WORLD":            {0},
+		"WORLD VALUE":      {0},
+		"EMERGING MARKETS": {0},
+	})
+
+	p := Portfolio{
+		Positions: []Position{{Name: "WORLD", Value: 1}, {Name: "world valeu", Value: 1}},
+	}
+	err := p.Validate(hist)
+	if err == nil {
+		t.Fatal("Validate() = nil, want error")
+	}
+	if want := `did you mean "WORLD VALUE"?`; !strings.Contains(err.Error(), want) {
+		t.Errorf("Validate() = %q, want it to contain %q", err, want)
+	}
+
+	p.Positions[1].Name = "WORLD VALUE"
+	if err := p.Validate(hist); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+func TestCheckNames(t *testing.T) {
+	hist := newHistory(map[string][]float64{
+		"WORLD":       {0},
+		"WORLD VALUE": {0},
+	})
+
+	if err := CheckNames(hist, "WORLD", "WORLD VALUE"); err != nil {
+		t.Errorf("CheckNames() = %v", err)
+	}
+
+	err := CheckNames(hist, "WORLD", "WROLD")
+	if err == nil {
+		t.Fatal("CheckNames() = nil, want error")
+	}
+	if want := `did you mean "WORLD"?`; !strings.Contains(err.Error(), want) {
+		t.Errorf("CheckNames() = %q, want it to contain %q", err, want)
+	}
+}
+
+func TestEval(t *testing.T) {
+	hist := newHistory(map[string][]float64{
+		"A": {1, 0, 0},
+		"B": {0, 0, 0},
+	})
+
+	cases := []struct {
+		name        string
+		p           Portfolio
+		wantReturns []float64
+		wantValues  []float64
+	}{
+		{
+			name:        "buy and hold",
+			p:           Portfolio{Positions: []Position{{Name: "A", Value: 50}, {Name: "B", Value: 50}}},
+			wantReturns: []float64{.5, 0, 0},
+			wantValues:  []float64{150, 150, 150},
+		},
+		{
+			name: "contribution",
+			p: Portfolio{
+				Positions: []Position{{Name: "A", Value: 50}, {Name: "B", Value: 50}},
+				CashFlow:  CashFlow{Amount: 10, Months: 2},
+			},
+			wantReturns: []float64{.5, 0, 0},
+			wantValues:  []float64{150, 160, 160},
+		},
+		{
+			name: "withdrawal",
+			p: Portfolio{
+				Positions: []Position{{Name: "A", Value: 50}, {Name: "B", Value: 50}},
+				CashFlow:  CashFlow{Amount: -100, Months: 1},
+			},
+			wantReturns: []float64{.5, 0, 0},
+			wantValues:  []float64{50, 0, 0},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			returns, err := tc.p.Eval(&timeseries.Backtest{Data: hist})
+			if err != nil {
+				t.Fatal(err)
+			}
+			values, err := tc.p.EvalValues(&timeseries.Backtest{Data: hist})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var gotReturns, gotValues []float64
+			for i := range returns.Data {
+				gotReturns = append(gotReturns, returns.Data[i].Value)
+				gotValues = append(gotValues, values.Data[i].Value)
+			}
+			if diff := cmp.Diff(tc.wantReturns, gotReturns, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+				t.Errorf("Eval() differs (-want/+got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tc.wantValues, gotValues, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+				t.Errorf("EvalValues() differs (-want/+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestEvalRebalance(t *testing.T) {
+	hist := newHistory(map[string][]float64{
+		"A": {1, -.5},
+		"B": {0, 0},
+	})
+
+	// Without rebalancing, A doubles and halves again: 100 → 150 → 100.
+	// With monthly rebalancing, A is reset to 75 after the first month:
+	// 150 → 75*0.5 + 75 = 112.5.
+	p := Portfolio{
+		Positions: []Position{{Name: "A", Value: 50}, {Name: "B", Value: 50}},
+		Rebalance: 1,
+	}
+	values, err := p.EvalValues(&timeseries.Backtest{Data: hist})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := values.Data[1].Value, 112.5; got != want {
+		t.Errorf("EvalValues() = %g, want %g", got, want)
+	}
+
+	p.Rebalance = 0
+	p.RebalanceThreshold = 10
+	values, err = p.EvalValues(&timeseries.Backtest{Data: hist})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := values.Data[1].Value, 112.5; got != want {
+		t.Errorf("EvalValues() with threshold = %g, want %g", got, want)
+	}
+}
+
+func TestEvalCosts(t *testing.T) {
+	hist := newHistory(map[string][]float64{
+		"A": {1, -.5},
+		"B": {0, 0},
+	})
+
+	// A 12% TER reduces the value by 1% per month.
+	p := Portfolio{
+		Positions: []Position{{Name: "A", Value: 100, TER: 100 * (1 - math.Pow(.99, 12))}},
+	}
+	values, err := p.EvalValues(&timeseries.Backtest{Data: hist})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := values.Data[1].Value, 100*2*.99*.5*.99; math.Abs(got-want) > 1e-9 {
+		t.Errorf("EvalValues() = %g, want %g", got, want)
+	}
+
+	// Rebalancing from 100/50 to 75/75 trades 25 in each position, costing
+	// 1 + 2% * 25 each.
+	p = Portfolio{
+		Positions:    []Position{{Name: "A", Value: 50}, {Name: "B", Value: 50}},
+		Rebalance:    1,
+		TradingCosts: TradingCosts{Fee: 1, Spread: 2},
+	}
+	returns, err := p.Eval(&timeseries.Backtest{Data: hist})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := returns.Data[0].Value, (150-2*(1+.5))/100.0-1; math.Abs(got-want) > 1e-9 {
+		t.Errorf("Eval() = %g, want %g", got, want)
+	}
+
+	drag, err := p.CostDrag(func() QuoteProvider {
+		return &timeseries.Backtest{Data: hist}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if drag.Fund != 0 || drag.Trading <= 0 {
+		t.Errorf("CostDrag() = %+v, want only trading costs", drag)
+	}
+}
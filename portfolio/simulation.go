@@ -0,0 +1,185 @@
+package portfolio
+
+import "math"
+
+// simulation holds the state of a portfolio while it is being evaluated.
+type simulation struct {
+	p         Portfolio
+	positions []Position
+	// targets holds the initial weights of the positions.
+	targets []float64
+	// factors holds the monthly factors of the positions' running costs.
+	factors []float64
+	// tax is nil if p has no tax model.
+	tax *taxState
+
+	// returns holds the returns of the positions in the current month.
+	returns []float64
+	// history and rebalanced are only used with a strategy. rebalanced
+	// holds the targets at the last rebalancing.
+	history    History
+	rebalanced []float64
+}
+
+func newSimulation(p Portfolio) *simulation {
+	s := &simulation{
+		p:         p,
+		positions: make([]Position, len(p.Positions)),
+		targets:   make([]float64, len(p.Positions)),
+		factors:   make([]float64, len(p.Positions)),
+		returns:   make([]float64, len(p.Positions)),
+	}
+	copy(s.positions, p.Positions)
+
+	value := s.value()
+	for i, pos := range s.positions {
+		s.targets[i] = pos.Value / value
+		s.factors[i] = pos.costFactor()
+	}
+	s.rebalanced = append([]float64(nil), s.targets...)
+
+	if p.Tax != nil {
+		s.tax = newTaxState(*p.Tax, s.positions)
+	}
+
+	return s
+}
+
+func (s *simulation) value() float64 {
+	return sum(s.positions)
+}
+
+func sum(positions []Position) float64 {
+	var ret float64
+	for _, pos := range positions {
+		ret += pos.Value
+	}
+	return ret
+}
+
+// grow multiplies the value of position i with f. Interest on cash is
+// taxable income of the current year.
+func (s *simulation) grow(i int, f float64) {
+	switch {
+	case s.tax == nil:
+	case s.isCash(i):
+		s.tax.income += math.Max(0, s.positions[i].Value*(f-1))
+	default:
+		s.tax.holdings[i].price *= f
+	}
+	s.positions[i].Value *= f
+}
+
+// cost returns the trading costs of buying or selling amount of position i.
+// Cash is not traded.
+func (s *simulation) cost(i int, amount float64) float64 {
+	if s.isCash(i) {
+		return 0
+	}
+	return s.p.TradingCosts.cost(amount)
+}
+
+// buy invests amount in position i. The trading costs are paid from amount.
+func (s *simulation) buy(i int, amount float64) {
+	net := math.Max(0, amount-s.cost(i, amount))
+	s.positions[i].Value += net
+	if s.tax != nil && !s.isCash(i) {
+		s.tax.buy(i, net, amount)
+	}
+}
+
+// sell sells amount of position i and returns the proceeds after trading
+// costs.
+func (s *simulation) sell(i int, amount float64) float64 {
+	amount = math.Min(amount, s.positions[i].Value)
+	if amount <= 0 {
+		return 0
+	}
+
+	proceeds := math.Max(0, amount-s.cost(i, amount))
+	s.positions[i].Value -= amount
+	if s.tax != nil && !s.isCash(i) {
+		s.tax.sell(i, amount, proceeds)
+	}
+	return proceeds
+}
+
+// applyCashFlow adds amount to the positions and returns the amount that was
+// actually added or, if negative, paid out. Contributions are split by the
+// target weights, i.e. a negative cash position borrows its share.
+// Withdrawals are taken from all positions except a loan proportionally and
+// are limited to the value of the portfolio.
+func (s *simulation) applyCashFlow(amount float64) float64 {
+	if amount >= 0 {
+		for i := range s.positions {
+			s.buy(i, s.targets[i]*amount)
+		}
+		return amount
+	}
+
+	var assets float64
+	for _, pos := range s.positions {
+		assets += math.Max(0, pos.Value)
+	}
+	if s.value() <= 0 || assets <= 0 {
+		return 0
+	}
+	amount = math.Max(amount, -s.value())
+
+	c := s.p.TradingCosts
+	var paid float64
+	for i, pos := range s.positions {
+		need := -amount * pos.Value / assets
+		if need <= 0 {
+			continue
+		}
+		if s.isCash(i) {
+			paid += s.sell(i, need)
+			continue
+		}
+		// Sell enough to cover the trading costs, too.
+		paid += s.sell(i, (need+c.Fee)/(1-c.Spread/100))
+	}
+
+	return -paid
+}
+
+// rebalance resets the positions to their target weights, paying the trading
+// costs from the portfolio. The leverage is capped at the maintenance margin.
+func (s *simulation) rebalance() {
+	value := s.value()
+	targets := s.capped()
+
+	var costs float64
+	for i, pos := range s.positions {
+		if trade := targets[i]*value - pos.Value; math.Abs(trade) > 1e-9*value {
+			costs += s.cost(i, trade)
+		}
+	}
+	value = math.Max(0, value-costs)
+	copy(s.rebalanced, s.targets)
+
+	for i := range s.positions {
+		prev := s.positions[i].Value
+		s.positions[i].Value = targets[i] * value
+
+		if s.tax == nil || s.isCash(i) {
+			continue
+		}
+		if trade := s.positions[i].Value - prev; trade > 0 {
+			s.tax.buy(i, trade, trade)
+		} else if trade < 0 {
+			s.tax.sell(i, -trade, -trade)
+		}
+	}
+}
+
+// payTax settles the taxes of the past year by selling positions
+// proportionally.
+func (s *simulation) payTax() {
+	due := s.tax.settle()
+	if due <= 0 {
+		return
+	}
+	s.tax.paid += -s.applyCashFlow(-due)
+}
@@ -0,0 +1,429 @@
+package portfolio
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/octo/portfolio-mcmc/timeseries"
+)
+
+// Strategy determines the target weights of a portfolio's positions while the
+// portfolio is evaluated, e.g. to follow a trend or a glide path.
+type Strategy interface {
+	// Weights returns the target weights of positions after the months
+	// observed in h. positions are the portfolio's positions with their
+	// initial values. The weights add up to one; only the cash position
+	// may have a negative weight. Weights is also called before the first
+	// month, with an empty history, to determine the initial allocation. A
+	// nil result keeps the current targets.
+	Weights(positions []Position, h History) []float64
+	// Check returns an error if the strategy cannot be applied to a
+	// portfolio with positions.
+	Check(positions []Position) error
+	String() string
+}
+
+// History is the data observed by a strategy.
+type History struct {
+	// Start is the date of the first month of the evaluation.
+	Start time.Time
+	// Months is the number of months evaluated so far.
+	Months int
+	// Returns holds the monthly returns of every position, before costs.
+	// The returns of the cash position are its interest.
+	Returns map[string]timeseries.Data
+}
+
+// trailing returns the last n returns of name, or nil if fewer are available.
+func (h History) trailing(name string, n int) []timeseries.Datum {
+	data := h.Returns[name].Data
+	if n <= 0 || len(data) < n {
+		return nil
+	}
+	return data[len(data)-n:]
+}
+
+// strategyThreshold is the number of percentage points by which a target
+// weight has to move before a portfolio with a strategy other than a
+// GlidePath but without rebalancing settings is rebalanced.
+const strategyThreshold = 5
+
+// Names of the strategies accepted by ParseStrategy.
+const (
+	TrendStrategy      = "trend"
+	MomentumStrategy   = "momentum"
+	VolatilityStrategy = "voltarget"
+)
+
+// ParseStrategy parses a strategy in the form "<func>(<args>)". The following
+// strategies are supported:
+//
+//	trend(<months>, <safe>)
+//	momentum(<months>, <safe>, <asset>, <asset>[, ...])
+//	voltarget(<volatility>, <months>, <safe>[, <max>])
+//
+// <safe> is the position that is held instead of risky ones, often "CASH".
+// See Trend, DualMomentum and VolatilityTarget for details.
+func ParseStrategy(s string) (Strategy, error) {
+	s = strings.TrimSpace(s)
+	open := strings.Index(s, "(")
+	if open == -1 || !strings.HasSuffix(s, ")") {
+		return nil, fmt.Errorf(`got %q, want "<func>(<args>)"`, s)
+	}
+	name := strings.TrimSpace(s[:open])
+
+	var args []string
+	for _, arg := range strings.Split(s[open+1:len(s)-1], ",") {
+		args = append(args, strings.TrimSpace(arg))
+	}
+	for _, arg := range args {
+		if arg == "" {
+			return nil, fmt.Errorf("%s: empty argument", name)
+		}
+	}
+
+	switch name {
+	case TrendStrategy:
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%s: got %d arguments, want 2", name, len(args))
+		}
+		months, err := parseMonths(name, args[0])
+		if err != nil {
+			return nil, err
+		}
+		return Trend{Months: months, Safe: args[1]}, nil
+	case MomentumStrategy:
+		if len(args) < 4 {
+			return nil, fmt.Errorf("%s: got %d arguments, want at least 4", name, len(args))
+		}
+		months, err := parseMonths(name, args[0])
+		if err != nil {
+			return nil, err
+		}
+		return DualMomentum{Months: months, Safe: args[1], Assets: args[2:]}, nil
+	case VolatilityStrategy:
+		if len(args) != 3 && len(args) != 4 {
+			return nil, fmt.Errorf("%s: got %d arguments, want 3 or 4", name, len(args))
+		}
+		target, err := strconv.ParseFloat(args[0], 64)
+		if err != nil || target <= 0 {
+			return nil, fmt.Errorf("%s: got volatility %q, want a positive number", name, args[0])
+		}
+		months, err := parseMonths(name, args[1])
+		if err != nil {
+			return nil, err
+		}
+		vt := VolatilityTarget{Target: target, Months: months, Safe: args[2], Max: 1}
+		if len(args) == 4 {
+			if vt.Max, err = strconv.ParseFloat(args[3], 64); err != nil || vt.Max <= 0 {
+				return nil, fmt.Errorf("%s: got maximum %q, want a positive number", name, args[3])
+			}
+		}
+		return vt, nil
+	}
+
+	return nil, fmt.Errorf("unknown strategy %q, want one of %q, %q and %q", name, TrendStrategy, MomentumStrategy, VolatilityStrategy)
+}
+
+func parseMonths(name, s string) (int, error) {
+	months, err := strconv.Atoi(s)
+	if err != nil || months < 2 {
+		return 0, fmt.Errorf("%s: got %q months, want an integer of at least 2", name, s)
+	}
+	return months, nil
+}
+
+// WithoutStrategy returns a copy of p with constant weights.
+func (p Portfolio) WithoutStrategy() Portfolio {
+	p.Strategy = nil
+	return p
+}
+
+// baseWeights returns the weights of positions relative to their total value.
+func baseWeights(positions []Position) []float64 {
+	value := sum(positions)
+	ret := make([]float64, len(positions))
+	for i, pos := range positions {
+		ret[i] = pos.Value / value
+	}
+	return ret
+}
+
+// checkPositions returns an error if one of names is not a position.
+func checkPositions(positions []Position, names ...string) error {
+	for _, name := range names {
+		var found bool
+		for _, pos := range positions {
+			found = found || pos.Name == name
+		}
+		if !found {
+			return fmt.Errorf("%q is not a position of the portfolio", name)
+		}
+	}
+	return nil
+}
+
+// index returns the index of the position name, or -1.
+func index(positions []Position, name string) int {
+	for i, pos := range positions {
+		if pos.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Trend holds a position only while its price is at or above its moving
+// average over Months months, e.g. the ten-month moving average. Otherwise,
+// its weight is held in Safe. Positions are held until Months months have
+// been observed.
+type Trend struct {
+	Months int
+	Safe   string
+}
+
+// Weights implements Strategy.
+func (t Trend) Weights(positions []Position, h History) []float64 {
+	w := baseWeights(positions)
+	safe := index(positions, t.Safe)
+	if safe == -1 {
+		return nil
+	}
+
+	for i, pos := range positions {
+		if i == safe {
+			continue
+		}
+		data := h.trailing(pos.Name, t.Months)
+		if data == nil {
+			continue
+		}
+
+		// The prices relative to the price before the window.
+		var price, avg float64 = 1, 0
+		for _, d := range data {
+			price *= 1 + d.Value
+			avg += price / float64(len(data))
+		}
+		if price < avg {
+			w[safe] += w[i]
+			w[i] = 0
+		}
+	}
+	return w
+}
+
+// Check implements Strategy.
+func (t Trend) Check(positions []Position) error {
+	return checkPositions(positions, t.Safe)
+}
+
+func (t Trend) String() string {
+	return fmt.Sprintf("%s(%d, %s)", TrendStrategy, t.Months, t.Safe)
+}
+
+// DualMomentum holds the combined weight of Assets in the one with the
+// highest returns over the last Months months (relative momentum), but only
+// if these returns exceed those of Safe (absolute momentum). Otherwise, the
+// weight is held in Safe. Until Months months have been observed, the
+// initial weights are kept.
+type DualMomentum struct {
+	Months int
+	Safe   string
+	Assets []string
+}
+
+// Weights implements Strategy.
+func (m DualMomentum) Weights(positions []Position, h History) []float64 {
+	w := baseWeights(positions)
+
+	growth := func(name string) (float64, bool) {
+		data := h.trailing(name, m.Months)
+		if data == nil {
+			return 0, false
+		}
+		g := 1.0
+		for _, d := range data {
+			g *= 1 + d.Value
+		}
+		return g, true
+	}
+
+	safe := index(positions, m.Safe)
+	if safe == -1 {
+		return nil
+	}
+
+	best, bestGrowth := -1, 0.0
+	var total float64
+	for _, name := range m.Assets {
+		i := index(positions, name)
+		if i == -1 {
+			return nil
+		}
+		g, ok := growth(name)
+		if !ok {
+			return w
+		}
+		if best == -1 || g > bestGrowth {
+			best, bestGrowth = i, g
+		}
+		total += w[i]
+		w[i] = 0
+	}
+
+	if g, ok := growth(m.Safe); ok && g >= bestGrowth {
+		best = safe
+	}
+	w[best] += total
+	return w
+}
+
+// Check implements Strategy.
+func (m DualMomentum) Check(positions []Position) error {
+	for _, name := range m.Assets {
+		if name == m.Safe {
+			return fmt.Errorf("%q is both an asset and the safe position", name)
+		}
+	}
+	return checkPositions(positions, append([]string{m.Safe}, m.Assets...)...)
+}
+
+func (m DualMomentum) String() string {
+	return fmt.Sprintf("%s(%d, %s, %s)", MomentumStrategy, m.Months, m.Safe, strings.Join(m.Assets, ", "))
+}
+
+// VolatilityTarget scales the positions other than Safe and cash so that their
+// volatility over the last Months months, at the initial weights, matches
+// Target, an annual volatility in percent. The remainder is held in Safe.
+// The exposure is limited to Max times the initial weights; values above one
+// borrow money and require the cash position as Safe.
+type VolatilityTarget struct {
+	Target float64
+	Months int
+	Safe   string
+	Max    float64
+}
+
+// Weights implements Strategy.
+func (v VolatilityTarget) Weights(positions []Position, h History) []float64 {
+	w := baseWeights(positions)
+	safe := index(positions, v.Safe)
+	if safe == -1 {
+		return nil
+	}
+
+	// Cash keeps its weight; the other positions are risky.
+	isRisky := func(i int) bool {
+		return i != safe && positions[i].Name != CashPosition
+	}
+	var risky float64
+	for i := range positions {
+		if isRisky(i) {
+			risky += w[i]
+		}
+	}
+	if risky <= 0 {
+		return w
+	}
+
+	// The returns of the risky positions at their initial weights.
+	returns := make([]float64, v.Months)
+	for i, pos := range positions {
+		if !isRisky(i) {
+			continue
+		}
+		data := h.trailing(pos.Name, v.Months)
+		if data == nil {
+			return w
+		}
+		for j, d := range data {
+			returns[j] += w[i] / risky * d.Value
+		}
+	}
+
+	vol := 100 * math.Sqrt(12*timeseries.Variance(returns))
+	scale := v.Max
+	if vol > 0 {
+		scale = math.Min(v.Max, v.Target/vol)
+	}
+
+	w[safe] = 1
+	for i := range positions {
+		if isRisky(i) {
+			w[i] *= scale
+		}
+		if i != safe {
+			w[safe] -= w[i]
+		}
+	}
+	return w
+}
+
+// Check implements Strategy.
+func (v VolatilityTarget) Check(positions []Position) error {
+	if v.Max > 1 && v.Safe != CashPosition {
+		return fmt.Errorf("a maximum exposure of %g requires %q as the safe position", v.Max, CashPosition)
+	}
+	return checkPositions(positions, v.Safe)
+}
+
+func (v VolatilityTarget) String() string {
+	return fmt.Sprintf("%s(%g, %d, %s, %g)", VolatilityStrategy, v.Target, v.Months, v.Safe, v.Max)
+}
+
+// observe appends the returns of the month on date to the history.
+func (s *simulation) observe(date time.Time, returns []float64) {
+	if s.history.Returns == nil {
+		s.history.Returns = make(map[string]timeseries.Data)
+	}
+	for i, pos := range s.positions {
+		h := s.history.Returns[pos.Name]
+		h.Data = append(h.Data, timeseries.Datum{
+			Date:  date,
+			Value: returns[i],
+		})
+		s.history.Returns[pos.Name] = h
+	}
+	s.history.Months++
+}
+
+// follow sets the targets to the strategy's weights. Before the first month,
+// the positions are allocated accordingly, without trading costs.
+func (s *simulation) follow() {
+	w := s.p.Strategy.Weights(s.p.Positions, s.history)
+	if w == nil {
+		return
+	}
+	s.targets = w
+
+	if s.history.Months == 0 {
+		value := s.value()
+		for i := range s.positions {
+			s.positions[i].Value = s.targets[i] * value
+		}
+		s.rebalanced = append([]float64(nil), s.targets...)
+	}
+}
+
+// shifted returns true if the portfolio follows a strategy without rebalancing
+// settings and a target weight moved by more than strategyThreshold
+// percentage points since the last rebalancing. Glide paths are rebalanced
+// annually instead, see rebalanceDue.
+func (s *simulation) shifted() bool {
+	if s.p.Strategy == nil || s.p.Rebalance != 0 || s.p.RebalanceThreshold != 0 {
+		return false
+	}
+	if _, ok := s.p.Strategy.(GlidePath); ok {
+		return false
+	}
+	for i, w := range s.targets {
+		if 100*math.Abs(w-s.rebalanced[i]) > strategyThreshold {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,227 @@
+package portfolio
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/octo/portfolio-mcmc/timeseries"
+)
+
+func TestParseStrategy(t *testing.T) {
+	cases := []struct {
+		input   string
+		want    Strategy
+		wantErr bool
+	}{
+		{input: "trend(10, CASH)", want: Trend{Months: 10, Safe: "CASH"}},
+		{
+			input: "momentum(12, CASH, WORLD, EMERGING MARKETS)",
+			want:  DualMomentum{Months: 12, Safe: "CASH", Assets: []string{"WORLD", "EMERGING MARKETS"}},
+		},
+		{input: "voltarget(10, 6, CASH)", want: VolatilityTarget{Target: 10, Months: 6, Safe: "CASH", Max: 1}},
+		{input: "voltarget(10, 6, CASH, 1.5)", want: VolatilityTarget{Target: 10, Months: 6, Safe: "CASH", Max: 1.5}},
+		{input: "trend(1, CASH)", wantErr: true},
+		{input: "trend(10)", wantErr: true},
+		{input: "momentum(12, CASH, WORLD)", wantErr: true},
+		{input: "voltarget(-1, 6, CASH)", wantErr: true},
+		{input: "buy-the-dip(CASH)", wantErr: true},
+		{input: "trend", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := ParseStrategy(tc.input)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ParseStrategy() = %v, want error %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("ParseStrategy() differs (-want/+got):\n%s", diff)
+			}
+			if err == nil && got.String() != tc.input && tc.input != "voltarget(10, 6, CASH)" {
+				t.Errorf("String() = %q, want %q", got.String(), tc.input)
+			}
+		})
+	}
+}
+
+func TestStrategyWeights(t *testing.T) {
+	volScale := 10 / (100 * .15)
+	positions := []Position{{Name: "A", Value: 60}, {Name: "B", Value: 40}, {Name: CashPosition}}
+
+	cases := []struct {
+		name     string
+		strategy Strategy
+		returns  map[string][]float64
+		want     []float64
+	}{
+		{
+			name:     "trend without history",
+			strategy: Trend{Months: 3, Safe: CashPosition},
+			returns:  map[string][]float64{"A": {-.1}, "B": {-.1}, CashPosition: {0}},
+			want:     []float64{.6, .4, 0},
+		},
+		{
+			name:     "trend",
+			strategy: Trend{Months: 3, Safe: CashPosition},
+			returns:  map[string][]float64{"A": {.1, .1, .1}, "B": {0, 0, -.1}, CashPosition: {0, 0, 0}},
+			want:     []float64{.6, 0, .4},
+		},
+		{
+			name:     "relative momentum",
+			strategy: DualMomentum{Months: 2, Safe: CashPosition, Assets: []string{"A", "B"}},
+			returns:  map[string][]float64{"A": {0, .01}, "B": {.01, .01}, CashPosition: {0, 0}},
+			want:     []float64{0, 1, 0},
+		},
+		{
+			name:     "absolute momentum",
+			strategy: DualMomentum{Months: 2, Safe: CashPosition, Assets: []string{"A", "B"}},
+			returns:  map[string][]float64{"A": {0, -.01}, "B": {-.01, .005}, CashPosition: {.001, .001}},
+			want:     []float64{0, 0, 1},
+		},
+		{
+			// The returns alternate by ±4.33%, a volatility of 15% p.a.
+			name:     "volatility target",
+			strategy: VolatilityTarget{Target: 10, Months: 4, Safe: CashPosition, Max: 1},
+			returns: map[string][]float64{
+				"A":          {.15 / math.Sqrt(12), -.15 / math.Sqrt(12), .15 / math.Sqrt(12), -.15 / math.Sqrt(12)},
+				"B":          {.15 / math.Sqrt(12), -.15 / math.Sqrt(12), .15 / math.Sqrt(12), -.15 / math.Sqrt(12)},
+				CashPosition: {0, 0, 0, 0},
+			},
+			want: []float64{.6 * volScale, .4 * volScale, 1 - volScale},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			h := History{Returns: newHistory(tc.returns), Months: len(tc.returns["A"])}
+			got := tc.strategy.Weights(positions, h)
+			if diff := cmp.Diff(tc.want, got, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+				t.Errorf("Weights() differs (-want/+got):\n%s", diff)
+			}
+			if err := tc.strategy.Check(positions); err != nil {
+				t.Errorf("Check() = %v", err)
+			}
+		})
+	}
+
+	if err := (Trend{Months: 10, Safe: "BONDS"}).Check(positions); err == nil {
+		t.Error("Check() = nil, want error for a missing safe position")
+	}
+
+	// Without the safe position, the current targets are kept.
+	h := History{Returns: newHistory(map[string][]float64{"A": {-.1}, "B": {-.1}, CashPosition: {0}}), Months: 1}
+	for _, s := range []Strategy{
+		Trend{Months: 1, Safe: "BONDS"},
+		DualMomentum{Months: 1, Safe: "BONDS", Assets: []string{"A", "B"}},
+		VolatilityTarget{Target: 10, Months: 1, Safe: "BONDS", Max: 1},
+	} {
+		if got := s.Weights(positions, h); got != nil {
+			t.Errorf("%v.Weights() = %v, want nil for a missing safe position", s, got)
+		}
+	}
+	if err := (VolatilityTarget{Target: 10, Months: 6, Safe: "B", Max: 2}).Check(positions); err == nil {
+		t.Error("Check() = nil, want error for leverage without cash")
+	}
+}
+
+func TestEvalStrategy(t *testing.T) {
+	// A rises for three months and falls afterwards. With a three-month
+	// trend, the portfolio moves into cash at the end of the fourth month.
+	hist := newHistory(map[string][]float64{
+		"A": {.1, .1, .1, -.1, -.1, -.1, -.1},
+	})
+	p := Portfolio{
+		Positions: []Position{{Name: "A", Value: 100}, {Name: CashPosition}},
+		Strategy:  Trend{Months: 3, Safe: CashPosition},
+	}
+
+	values, err := p.EvalValues(&timeseries.Backtest{Data: hist})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := 100 * 1.1 * 1.1 * 1.1 * .9
+	var got []float64
+	for _, d := range values.Data {
+		got = append(got, d.Value)
+	}
+	if diff := cmp.Diff([]float64{want, want, want, want}, got[3:], cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("EvalValues() differs (-want/+got):\n%s", diff)
+	}
+	if got, want := p.WithoutStrategy().Strategy, Strategy(nil); got != want {
+		t.Errorf("WithoutStrategy().Strategy = %v, want nil", got)
+	}
+}
+
+func TestReadFileStrategy(t *testing.T) {
+	input := `{"portfolios": [{
+		"positions": [{"name": "A", "percent": 100}, {"name": "CASH", "percent": 0}],
+		"strategy": "trend(10, CASH)"
+	}]}`
+
+	ps, err := ReadFile(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(Strategy(Trend{Months: 10, Safe: CashPosition}), ps[0].Strategy); diff != "" {
+		t.Errorf("ReadFile() strategy differs (-want/+got):\n%s", diff)
+	}
+
+	for _, input := range []string{
+		`{"portfolios": [{"positions": [{"name": "A", "percent": 100}], "strategy": "trend(10, CASH)"}]}`,
+		`{"portfolios": [{"positions": [{"name": "A", "percent": 100}], "strategy": "trend(10)"}]}`,
+		`{"portfolios": [{"positions": [{"name": "A", "percent": 100}], "strategy": "trend(10, A)", "glide_path": {"steps": [{"age": 40, "weights": {"A": 1}}]}}]}`,
+	} {
+		if _, err := ReadFile(strings.NewReader(input)); err == nil {
+			t.Errorf("ReadFile(%s) = nil, want error", input)
+		}
+	}
+}
+
+func TestVolatilityTargetCash(t *testing.T) {
+	positions := []Position{{Name: "A", Value: 60}, {Name: CashPosition, Value: 20}, {Name: "B", Value: 20}}
+	h := History{
+		Returns: newHistory(map[string][]float64{
+			"A":          {.15 / math.Sqrt(12), -.15 / math.Sqrt(12), .15 / math.Sqrt(12), -.15 / math.Sqrt(12)},
+			CashPosition: {0, 0, 0, 0},
+			"B":          {0, 0, 0, 0},
+		}),
+		Months: 4,
+	}
+
+	// Only A is risky: it is scaled from 15% to 10% volatility, cash keeps
+	// its weight and the remainder goes to B.
+	got := VolatilityTarget{Target: 10, Months: 4, Safe: "B", Max: 1}.Weights(positions, h)
+	if diff := cmp.Diff([]float64{.4, .2, .4}, got, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("Weights() differs (-want/+got):\n%s", diff)
+	}
+}
+
+func TestShifted(t *testing.T) {
+	p := Portfolio{
+		Positions: []Position{{Name: "A", Value: 50}, {Name: CashPosition, Value: 50}},
+		Strategy:  Trend{Months: 10, Safe: CashPosition},
+	}
+
+	cases := []struct {
+		name    string
+		p       func(Portfolio) Portfolio
+		targets []float64
+		want    bool
+	}{
+		{"small move", func(p Portfolio) Portfolio { return p }, []float64{.54, .46}, false},
+		{"large move", func(p Portfolio) Portfolio { return p }, []float64{.56, .44}, true},
+		{"rebalancing settings", func(p Portfolio) Portfolio { p.Rebalance = 12; return p }, []float64{.56, .44}, false},
+		{"glide path", func(p Portfolio) Portfolio { p.Strategy = GlidePath{}; return p }, []float64{.56, .44}, false},
+	}
+	for _, tc := range cases {
+		s := newSimulation(tc.p(p))
+		s.targets = tc.targets
+		if got := s.shifted(); got != tc.want {
+			t.Errorf("%s: shifted() = %t, want %t", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package portfolio
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Tax is a model of the German taxation of investment funds held by a private
+// investor: the flat tax on capital income (Abgeltungsteuer) plus the
+// solidarity surcharge, the annual allowance (Sparerpauschbetrag), the partial
+// exemption by fund type (Teilfreistellung) and the advance lump sum
+// (Vorabpauschale) on accumulating funds.
+//
+// Gains are realized whenever positions are sold, e.g. when rebalancing or
+// withdrawing, using the first-in-first-out method. Taxes are settled once
+// per year, at the start of the following year, by selling positions
+// proportionally. Realized losses are carried forward.
+type Tax struct {
+	// Rate is the tax rate on capital income in percent.
+	Rate float64 `json:"rate"`
+	// Soli is the solidarity surcharge in percent of the tax.
+	Soli float64 `json:"soli"`
+	// Allowance is the annual tax-free capital income.
+	Allowance float64 `json:"allowance"`
+	// BaseRate is the base interest rate (Basiszins) for calculating the
+	// Vorabpauschale, in percent.
+	BaseRate float64 `json:"base_rate"`
+}
+
+// DefaultTax holds the parameters for a single taxpayer in 2024.
+var DefaultTax = Tax{
+	Rate:      25,
+	Soli:      5.5,
+	Allowance: 1000,
+	BaseRate:  2.29,
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Missing fields default to the
+// values of DefaultTax.
+func (t *Tax) UnmarshalJSON(b []byte) error {
+	type plain Tax
+	v := plain(DefaultTax)
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	*t = Tax(v)
+	return nil
+}
+
+// rate returns the effective tax rate as a fraction.
+func (t Tax) rate() float64 {
+	return t.Rate / 100 * (1 + t.Soli/100)
+}
+
+// Fund types determine the share of income that is tax exempt.
+const (
+	Equity            = "equity"
+	Mixed             = "mixed"
+	RealEstate        = "real-estate"
+	ForeignRealEstate = "foreign-real-estate"
+	Other             = "other"
+)
+
+// partialExemptions maps fund types to the tax exempt share of income, in
+// percent.
+var partialExemptions = map[string]float64{
+	"":                0,
+	Other:             0,
+	Equity:            30,
+	Mixed:             15,
+	RealEstate:        60,
+	ForeignRealEstate: 80,
+}
+
+// CheckFundType returns an error if t is not a known fund type.
+func CheckFundType(t string) error {
+	if _, ok := partialExemptions[t]; ok {
+		return nil
+	}
+
+	var types []string
+	for k := range partialExemptions {
+		if k != "" {
+			types = append(types, fmt.Sprintf("%q", k))
+		}
+	}
+	sort.Strings(types)
+	return fmt.Errorf("unknown fund type %q, want one of %s", t, strings.Join(types, ", "))
+}
+
+// WithoutTax returns a copy of p without tax model.
+func (p Portfolio) WithoutTax() Portfolio {
+	p.Tax = nil
+	return p
+}
+
+// lot is a purchase of a position.
+type lot struct {
+	units float64
+	// cost is the acquisition cost of the remaining units.
+	cost float64
+	// vorab is the sum of the Vorabpauschalen per unit taxed so far.
+	vorab float64
+	year  int
+	month time.Month
+}
+
+// holding tracks the tax lots of a position. Units are priced at 1 when the
+// simulation starts.
+type holding struct {
+	// exemption is the tax exempt share of income as a fraction.
+	exemption float64
+	price     float64
+	// yearStart is the price at the start of the current year.
+	yearStart float64
+	lots      []lot
+}
+
+// taxState holds the tax relevant state of a simulation.
+type taxState struct {
+	Tax
+	holdings []holding
+	year     int
+	month    time.Month
+	// income is the taxable income of the current year after partial
+	// exemption.
+	income float64
+	// losses is the loss carried forward from previous years.
+	losses float64
+	paid   float64
+}
+
+func newTaxState(t Tax, positions []Position) *taxState {
+	ts := &taxState{
+		Tax: t,
+	}
+	for _, pos := range positions {
+		ts.holdings = append(ts.holdings, holding{
+			exemption: partialExemptions[pos.FundType] / 100,
+			price:     1,
+			yearStart: 1,
+		})
+	}
+	return ts
+}
+
+// startMonth advances the state to date. The initial positions are bought in
+// the first month.
+func (ts *taxState) startMonth(date time.Time, positions []Position) {
+	first := ts.year == 0
+	if date.Year() != ts.year {
+		for i := range ts.holdings {
+			ts.holdings[i].yearStart = ts.holdings[i].price
+		}
+	}
+	ts.year, ts.month = date.Year(), date.Month()
+
+	if first {
+		for i, pos := range positions {
+			if pos.Name != CashPosition {
+				ts.buy(i, pos.Value, pos.Value)
+			}
+		}
+	}
+}
+
+// buy records the purchase of value of position i at the cost of cost.
+func (ts *taxState) buy(i int, value, cost float64) {
+	h := &ts.holdings[i]
+	if value <= 0 || h.price <= 0 {
+		return
+	}
+	h.lots = append(h.lots, lot{
+		units: value / h.price,
+		cost:  cost,
+		year:  ts.year,
+		month: ts.month,
+	})
+}
+
+// sell records the sale of value of position i for proceeds and realizes the
+// gains of the sold lots.
+func (ts *taxState) sell(i int, value, proceeds float64) {
+	h := &ts.holdings[i]
+	if value <= 0 || h.price <= 0 {
+		return
+	}
+
+	total := value / h.price
+	units := total
+	for units > 0 && len(h.lots) > 0 {
+		l := &h.lots[0]
+		u := math.Min(units, l.units)
+		f := u / l.units
+
+		gain := proceeds*u/total - f*l.cost - u*l.vorab
+		ts.income += gain * (1 - h.exemption)
+
+		l.cost -= f * l.cost
+		l.units -= u
+		units -= u
+		if l.units <= 1e-9*total {
+			h.lots = h.lots[1:]
+		}
+	}
+}
+
+// vorabpauschale returns the taxable Vorabpauschale of the current year and
+// adds it to the lots. It is 70% of the base rate applied to the value at the
+// start of the year, reduced by one twelfth for every full month before a lot
+// was bought, and limited to the increase in value during the year.
+func (ts *taxState) vorabpauschale() float64 {
+	var ret float64
+	for i := range ts.holdings {
+		h := &ts.holdings[i]
+		for j := range h.lots {
+			l := &h.lots[j]
+
+			ref, months := h.yearStart, 12
+			if l.year == ts.year {
+				ref, months = l.cost/l.units, 13-int(l.month)
+			}
+
+			base := l.units * ref * 0.7 * ts.BaseRate / 100 * float64(months) / 12
+			v := math.Max(0, math.Min(base, l.units*(h.price-ref)))
+			if v == 0 {
+				continue
+			}
+
+			l.vorab += v / l.units
+			ret += v * (1 - h.exemption)
+		}
+	}
+	return ret
+}
+
+// due returns the tax due on income, offsetting losses carried forward and
+// the allowance. It returns the loss carried forward to the next year.
+func (ts *taxState) due(income float64) (tax, losses float64) {
+	total := income - ts.losses
+	if total < 0 {
+		return 0, -total
+	}
+	return math.Max(0, total-ts.Allowance) * ts.rate(), 0
+}
+
+// settle ends the current year and returns the tax due.
+func (ts *taxState) settle() float64 {
+	ts.income += ts.vorabpauschale()
+
+	tax, losses := ts.due(ts.income)
+	ts.income, ts.losses = 0, losses
+
+	return tax
+}
+
+// liquidation returns the tax that would be due if all positions were sold
+// at their current value.
+func (ts *taxState) liquidation() float64 {
+	income := ts.income
+	for _, h := range ts.holdings {
+		for _, l := range h.lots {
+			gain := l.units*h.price - l.cost - l.units*l.vorab
+			income += gain * (1 - h.exemption)
+		}
+	}
+
+	tax, _ := ts.due(income)
+	return tax
+}
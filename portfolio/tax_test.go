@@ -0,0 +1,69 @@
+package portfolio
+
+import (
+	"math"
+	"testing"
+
+	"github.com/octo/portfolio-mcmc/timeseries"
+)
+
+func TestTaxLiquidation(t *testing.T) {
+	tax := DefaultTax
+	tax.BaseRate = 0
+	p := Portfolio{
+		Positions: []Position{{Name: "A", Value: 10000, FundType: Equity}},
+		Tax:       &tax,
+	}
+
+	ev, err := p.Evaluate(&timeseries.Backtest{Data: newHistory(map[string][]float64{"A": repeat(.01, 24)})})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ev.TaxPaid != 0 {
+		t.Errorf("TaxPaid = %g, want 0", ev.TaxPaid)
+	}
+
+	// 30% of the gain is exempt, the allowance of one year applies.
+	gain := 10000 * (math.Pow(1.01, 24) - 1)
+	want := (0.7*gain - 1000) * 0.25 * 1.055
+	if math.Abs(ev.LiquidationTax-want) > 1e-6 {
+		t.Errorf("LiquidationTax = %g, want %g", ev.LiquidationTax, want)
+	}
+
+	wantGrowth := (10000*math.Pow(1.01, 24) - want) / 10000
+	if got := ev.AfterTaxGrowth(); math.Abs(got-wantGrowth) > 1e-9 {
+		t.Errorf("AfterTaxGrowth() = %g, want %g", got, wantGrowth)
+	}
+}
+
+func TestTaxVorabpauschale(t *testing.T) {
+	tax := Tax{
+		Rate:     25,
+		Soli:     5.5,
+		BaseRate: 2,
+	}
+	p := Portfolio{
+		Positions: []Position{{Name: "A", Value: 10000}},
+		Tax:       &tax,
+	}
+
+	// The Vorabpauschale for 2000 is due in January 2001: 70% of the base
+	// rate, as the value increased by more than that.
+	ev, err := p.Evaluate(&timeseries.Backtest{Data: newHistory(map[string][]float64{"A": repeat(.01, 13)})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 10000 * 0.7 * 0.02 * 0.25 * 1.055; math.Abs(ev.TaxPaid-want) > 1e-9 {
+		t.Errorf("TaxPaid = %g, want %g", ev.TaxPaid, want)
+	}
+
+	// In a year with losses, there is no Vorabpauschale.
+	ev, err = p.Evaluate(&timeseries.Backtest{Data: newHistory(map[string][]float64{"A": repeat(-.01, 13)})})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.TaxPaid != 0 || ev.LiquidationTax != 0 {
+		t.Errorf("TaxPaid, LiquidationTax = %g, %g, want 0, 0", ev.TaxPaid, ev.LiquidationTax)
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	_ "embed"
+	"flag"
+	"fmt"
+	"html/template"
+	"log"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/octo/portfolio-mcmc/chart"
+	"github.com/octo/portfolio-mcmc/output"
+	"github.com/octo/portfolio-mcmc/portfolio"
+	"github.com/octo/portfolio-mcmc/timeseries"
+)
+
+var (
+	input       = flag.String("input", "history.csv", "file containing historic returns")
+	validate    = flag.Bool("validate", false, "validate the input file before simulating")
+	outputFile  = flag.String("output", "report.html", "file to write the report to")
+	title       = flag.String("title", "Portfolio Report", "title of the report")
+	iterations  = flag.Int("iterations", 10000, "number of simulated paths")
+	percentiles = flag.String("percentiles", "50,80,90,95,99", "comma separated list of percentiles to report")
+	confidence  = flag.Float64("confidence", 95, "confidence level of the reported intervals [%]")
+	drawdowns   = flag.Int("drawdowns", 5, "number of drawdown periods to list")
+
+	pf      = portfolio.Portfolio{}
+	derived timeseries.Derivations
+
+	//go:embed report.html
+	reportTemplate string
+)
+
+// Report holds the data rendered by the template. Wealth is the value at the
+// end of the backtest, including cash flows.
+type Report struct {
+	Title      string
+	Generated  string
+	Input      string
+	Portfolio  output.Portfolio
+	First      string
+	Last       string
+	Months     int
+	Synthetic  int
+	Metrics    output.Metrics
+	Wealth     float64
+	CostDrag   *output.CostDrag
+	Years      []Year
+	Drawdowns  []Drawdown
+	Iterations int
+	Confidence float64
+	Forecasts  []Forecast
+	Charts     Charts
+}
+
+// Charts holds the inline SVG charts.
+type Charts struct {
+	Equity, Drawdown, Histogram, Fan template.HTML
+}
+
+// Year is a row of the yearly returns table. Returns are in percent.
+type Year struct {
+	Year    int
+	Months  int
+	Returns float64
+}
+
+// Drawdown is a row of the drawdown table. Dates are formatted as "2006-01".
+type Drawdown struct {
+	Peak, Trough, Recovery string
+	Depth                  float64
+	Months                 int
+}
+
+// Forecast holds the percentile tables of one simulation method.
+type Forecast struct {
+	Method  string
+	Metrics []MetricTable
+}
+
+// MetricTable holds the formatted percentiles of one metric.
+type MetricTable struct {
+	Name string
+	Rows []PercentileRow
+}
+
+// PercentileRow is a single formatted percentile and its confidence interval.
+type PercentileRow struct {
+	Percentile          string
+	Value, Lower, Upper string
+}
+
+func main() {
+	flag.Func("pos", `position as "name:weight[:ter]"; ter is the annual expense ratio [%]`, pf.FlagFunc())
+	flag.Func("portfolio", `load the portfolio from a definition file, "file.json[:name]"`, pf.FileFlagFunc())
+	flag.Func("derive", `add a derived series "name=func(args)", e.g. "2X WORLD=leverage(WORLD, 2, 0.6)"`, derived.FlagFunc())
+	flag.Parse()
+	rand.Seed(time.Now().UnixNano())
+
+	if len(pf.Positions) == 0 {
+		log.Fatal("specify -portfolio or one or more -pos arguments")
+	}
+
+	pcts, err := output.ParsePercentiles(*percentiles)
+	if err != nil {
+		log.Fatalf("-percentiles: %v", err)
+	}
+
+	hist, err := timeseries.LoadFile(*input, *validate)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := derived.Apply(hist); err != nil {
+		log.Fatalf("-derive: %v", err)
+	}
+
+	if err := pf.Validate(hist); err != nil {
+		log.Fatal(err)
+	}
+
+	hist, err = timeseries.Align(hist, portfolio.Names(pf)...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	r, err := newReport(hist, pcts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	tmpl, err := template.New("report").Funcs(template.FuncMap{
+		// The values are float64 or output.Number.
+		"percent": func(v interface{}) string { return fmt.Sprintf("%.1f%%", v) },
+		"number":  func(v interface{}) string { return fmt.Sprintf("%.2f", v) },
+		"amount":  func(v interface{}) string { return fmt.Sprintf("%.0f", v) },
+	}).Parse(reportTemplate)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	out, err := os.Create(*outputFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := tmpl.Execute(out, r); err != nil {
+		out.Close()
+		log.Fatal(err)
+	}
+	if err := out.Close(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func newReport(hist map[string]timeseries.Data, pcts []float64) (*Report, error) {
+	ev, err := pf.Evaluate(&timeseries.Backtest{
+		Data: hist,
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := ev.Returns
+	if len(res.Data) == 0 {
+		return nil, fmt.Errorf("no data for the portfolio")
+	}
+
+	r := &Report{
+		Title:      *title,
+		Generated:  time.Now().Format("2006-01-02"),
+		Input:      *input,
+		Portfolio:  output.NewPortfolio(pf),
+		First:      res.Data[0].Date.Format("2006-01"),
+		Last:       res.Data[len(res.Data)-1].Date.Format("2006-01"),
+		Months:     len(res.Data),
+		Synthetic:  timeseries.SyntheticMonths(hist, portfolio.Names(pf)...),
+		Metrics:    output.NewMetrics(res),
+		Wealth:     ev.Values.Data[len(ev.Values.Data)-1].Value,
+		Iterations: *iterations,
+		Confidence: *confidence,
+	}
+
+	if pf.HasCosts() {
+		drag, err := pf.CostDrag(func() portfolio.QuoteProvider {
+			return &timeseries.Backtest{
+				Data: hist,
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		d := output.NewCostDrag(drag)
+		r.CostDrag = &d
+	}
+
+	prev := -1
+	for _, d := range res.Data {
+		if d.Date.Year() != prev {
+			r.Years = append(r.Years, Year{Year: d.Date.Year()})
+			prev = d.Date.Year()
+		}
+		r.Years[len(r.Years)-1].Months++
+	}
+	for i, d := range res.CalendarYears().Data {
+		r.Years[i].Returns = 100 * d.Value
+	}
+
+	for i, dd := range res.Drawdowns() {
+		if i == *drawdowns {
+			break
+		}
+		row := Drawdown{
+			Peak:     dd.Peak.Format("2006-01"),
+			Trough:   dd.Trough.Format("2006-01"),
+			Recovery: "not recovered",
+			Depth:    dd.Depth,
+			Months:   dd.Months,
+		}
+		if dd.Recovered() {
+			row.Recovery = dd.Recovery.Format("2006-01")
+		}
+		r.Drawdowns = append(r.Drawdowns, row)
+	}
+
+	monteCarlo, err := simulate(func() (portfolio.Evaluation, error) {
+		scenario, err := timeseries.Generate(portfolio.Names(pf), &timeseries.MonteCarlo{
+			Data: hist,
+		})
+		if err != nil {
+			return portfolio.Evaluation{}, err
+		}
+		return pf.Evaluate(&timeseries.Backtest{
+			Data: scenario,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	markovChain, err := simulate(func() (portfolio.Evaluation, error) {
+		// res is net of TER, tracking difference, interest on loans and taxes
+		// paid and follows the strategy already.
+		return pf.WithoutFundCosts().WithoutCash().WithoutStrategy().WithoutTax().Evaluate(timeseries.NewMarkovChain(res))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r.Forecasts = []Forecast{
+		newForecast("Monte Carlo", monteCarlo, pcts),
+		newForecast("Markov Chain", markovChain, pcts),
+	}
+
+	var paths []timeseries.Data
+	for _, ev := range monteCarlo {
+		paths = append(paths, ev.Values)
+	}
+
+	var values []float64
+	for _, d := range res.Data {
+		values = append(values, 100*d.Value)
+	}
+	for _, c := range []struct {
+		chart *chart.Chart
+		dst   *template.HTML
+	}{
+		{chart.Equity("Backtest", r.Portfolio.Value, res), &r.Charts.Equity},
+		{chart.Underwater("Drawdown", res), &r.Charts.Drawdown},
+		{chart.Histogram("Monthly Returns", values, 40, chart.Percent), &r.Charts.Histogram},
+		{chart.Fan("Wealth (Monte Carlo)", timeseries.ValueBands(paths, []float64{5, 25, 50, 75, 95})), &r.Charts.Fan},
+	} {
+		var b strings.Builder
+		if err := c.chart.WriteSVG(&b); err != nil {
+			return nil, err
+		}
+		// The SVG is generated by the chart package, which escapes all text.
+		*c.dst = template.HTML(b.String())
+	}
+
+	return r, nil
+}
+
+// simulate calls eval *iterations times and returns the evaluations.
+func simulate(eval func() (portfolio.Evaluation, error)) ([]portfolio.Evaluation, error) {
+	var ret []portfolio.Evaluation
+	for i := 0; i < *iterations; i++ {
+		ev, err := eval()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, ev)
+	}
+	return ret, nil
+}
+
+// newForecast formats the percentiles of every metric. Growth is replaced by
+// the terminal wealth, which includes cash flows.
+func newForecast(method string, evaluations []portfolio.Evaluation, pcts []float64) Forecast {
+	ret := Forecast{
+		Method: method,
+	}
+
+	var results []timeseries.Data
+	for _, ev := range evaluations {
+		results = append(results, ev.Returns)
+	}
+
+	for _, m := range timeseries.Metrics {
+		d, format := timeseries.NewDistribution(m, results), "%.2f"
+		switch {
+		case m.Name == timeseries.Growth.Name:
+			d, format = portfolio.TerminalWealthDistribution(evaluations), "%.0f"
+		case m.Percent:
+			format = "%.1f%%"
+		}
+
+		table := MetricTable{
+			Name: d.Metric.Name,
+		}
+		for _, p := range output.Percentiles(d, pcts, *confidence/100) {
+			table.Rows = append(table.Rows, PercentileRow{
+				Percentile: fmt.Sprintf("P%g", p.Percentile),
+				Value:      fmt.Sprintf(format, p.Value),
+				Lower:      fmt.Sprintf(format, p.Lower),
+				Upper:      fmt.Sprintf(format, p.Upper),
+			})
+		}
+		ret.Metrics = append(ret.Metrics, table)
+	}
+
+	return ret
+}
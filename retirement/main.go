@@ -0,0 +1,299 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/octo/portfolio-mcmc/output"
+	"github.com/octo/portfolio-mcmc/portfolio"
+	"github.com/octo/portfolio-mcmc/timeseries"
+	"github.com/octo/portfolio-mcmc/withdrawal"
+)
+
+var (
+	input       = flag.String("input", "history.csv", "file containing historic returns")
+	validate    = flag.Bool("validate", false, "validate the input file before simulating")
+	iterations  = flag.Int("iterations", 10000, "number of simulated paths")
+	wealth      = flag.Float64("wealth", 0, "initial wealth; defaults to the value of the portfolio")
+	years       = flag.Int("years", 30, "length of the retirement [years]")
+	rule        = flag.String("rule", withdrawal.ConstantReal, `withdrawal rule, one of "constant-real", "constant-percentage" and "guardrails"`)
+	rate        = flag.Float64("rate", 4, "initial annual withdrawal rate [%]")
+	inflation   = flag.Float64("inflation", 2, "annual inflation [%]")
+	floor       = flag.Float64("floor", 0, "lowest acceptable real withdrawal in percent of the initial withdrawal; paths falling below it fail")
+	target      = flag.Float64("target", 95, "target success probability for the safe withdrawal rate [%]")
+	percentiles = flag.String("percentiles", "50,80,90,95,99", "comma separated list of percentiles to report")
+	confidence  = flag.Float64("confidence", 95, "confidence level of the reported intervals [%]")
+	format      = flag.String("format", output.Text, `output format, one of "text" and "json"`)
+
+	pf      = portfolio.Portfolio{}
+	derived timeseries.Derivations
+)
+
+// Result is the output of the retirement command.
+type Result struct {
+	Parameters  Parameters       `json:"parameters"`
+	Portfolio   output.Portfolio `json:"portfolio"`
+	MonteCarlo  Simulation       `json:"monte_carlo"`
+	MarkovChain Simulation       `json:"markov_chain"`
+}
+
+// Parameters holds the command line flags that influence the result.
+type Parameters struct {
+	Input       string    `json:"input"`
+	Iterations  int       `json:"iterations"`
+	Wealth      float64   `json:"wealth"`
+	Years       int       `json:"years"`
+	Rule        string    `json:"rule"`
+	Rate        float64   `json:"rate"`
+	Inflation   float64   `json:"inflation"`
+	Floor       float64   `json:"floor,omitempty"`
+	Target      float64   `json:"target"`
+	Percentiles []float64 `json:"percentiles"`
+	Confidence  float64   `json:"confidence"`
+}
+
+// Simulation holds the results of one simulation method. Probabilities and
+// rates are in percent, amounts in today's money.
+type Simulation struct {
+	Survival    float64             `json:"survival"`
+	Success     float64             `json:"success"`
+	SafeRate    float64             `json:"safe_withdrawal_rate"`
+	Percentiles []output.Percentile `json:"percentiles"`
+}
+
+// Metrics of the withdrawal simulation.
+var (
+	terminalWealth = timeseries.Metric{Name: "terminal real wealth"}
+	minWithdrawal  = timeseries.Metric{Name: "lowest real withdrawal"}
+	totalWithdrawn = timeseries.Metric{Name: "total real withdrawals"}
+)
+
+func main() {
+	flag.Func("pos", `position as "name:weight[:ter]"; ter is the annual expense ratio [%]`, pf.FlagFunc())
+	flag.Func("portfolio", `load the portfolio from a definition file, "file.json[:name]"`, pf.FileFlagFunc())
+	flag.Func("derive", `add a derived series "name=func(args)", e.g. "2X WORLD=leverage(WORLD, 2, 0.6)"`, derived.FlagFunc())
+	flag.Parse()
+	rand.Seed(time.Now().UnixNano())
+
+	if *format != output.Text && *format != output.JSON {
+		log.Fatalf("unknown format %q, want one of %q and %q", *format, output.Text, output.JSON)
+	}
+	if err := withdrawal.CheckRule(*rule); err != nil {
+		log.Fatalf("-rule: %v", err)
+	}
+	// A constant percentage never depletes the portfolio, so without a
+	// floor every rate succeeds and the safe rate is meaningless.
+	if *rule == withdrawal.ConstantPercentage && *floor == 0 {
+		log.Fatalf("-rule %s requires -floor", withdrawal.ConstantPercentage)
+	}
+	if *years <= 0 {
+		log.Fatalf("-years: got %d, want a positive number", *years)
+	}
+
+	pcts, err := output.ParsePercentiles(*percentiles)
+	if err != nil {
+		log.Fatalf("-percentiles: %v", err)
+	}
+
+	hist, err := timeseries.LoadFile(*input, *validate)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := derived.Apply(hist); err != nil {
+		log.Fatalf("-derive: %v", err)
+	}
+
+	if err := pf.Validate(hist); err != nil {
+		log.Fatal(err)
+	}
+
+	if len(pf.Positions) == 0 {
+		var names []string
+		for name := range hist {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Println("ERROR: specify -portfolio or one or more -pos arguments.")
+		fmt.Println()
+		fmt.Println("Available time series:")
+		fmt.Println()
+		for _, name := range names {
+			fmt.Println("  *", name)
+		}
+
+		return
+	}
+
+	hist, err = timeseries.Align(hist, portfolio.Names(pf)...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Withdrawals are simulated by the plan; taxes are not modeled.
+	pf.CashFlow = portfolio.CashFlow{}
+	pf = pf.WithoutTax()
+
+	plan := withdrawal.Plan{
+		Wealth:    *wealth,
+		Rate:      *rate,
+		Rule:      *rule,
+		Inflation: *inflation,
+		Floor:     *floor,
+	}
+	if plan.Wealth == 0 {
+		for _, pos := range pf.Positions {
+			plan.Wealth += pos.Value
+		}
+	}
+
+	result := Result{
+		Parameters: Parameters{
+			Input:       *input,
+			Iterations:  *iterations,
+			Wealth:      plan.Wealth,
+			Years:       *years,
+			Rule:        plan.Rule,
+			Rate:        plan.Rate,
+			Inflation:   plan.Inflation,
+			Floor:       plan.Floor,
+			Target:      *target,
+			Percentiles: pcts,
+			Confidence:  *confidence,
+		},
+		Portfolio: output.NewPortfolio(pf),
+	}
+	months := 12 * *years
+
+	var paths []timeseries.Data
+	names := portfolio.Names(pf)
+	for i := 0; i < *iterations; i++ {
+		scenario, err := timeseries.Generate(names, &timeseries.MonteCarlo{
+			Data:   hist,
+			Months: months,
+		})
+		if err != nil {
+			log.Fatal("Generate: ", err)
+		}
+
+		res, err := pf.Eval(&timeseries.Backtest{
+			Data: scenario,
+		})
+		if err != nil {
+			log.Fatal("Eval: ", err)
+		}
+		paths = append(paths, res)
+	}
+	result.MonteCarlo = simulate(plan, paths, pcts)
+
+	// data is net of TER, tracking difference and interest on loans and
+	// follows the strategy already.
+	data, err := pf.Eval(&timeseries.Backtest{
+		Data: hist,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	paths = nil
+	for i := 0; i < *iterations; i++ {
+		chain := timeseries.NewMarkovChain(data)
+		chain.Months = months
+
+		res, err := pf.WithoutFundCosts().WithoutCash().WithoutStrategy().Eval(chain)
+		if err != nil {
+			log.Fatal("Eval: ", err)
+		}
+		paths = append(paths, res)
+	}
+	result.MarkovChain = simulate(plan, paths, pcts)
+
+	switch *format {
+	case output.Text:
+		printText(result)
+	case output.JSON:
+		err = output.WriteJSON(os.Stdout, result)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// simulate applies plan to all paths and searches the safe withdrawal rate.
+func simulate(plan withdrawal.Plan, paths []timeseries.Data, pcts []float64) Simulation {
+	var (
+		ret       Simulation
+		survived  int
+		succeeded int
+		dists     = []timeseries.Distribution{
+			{Metric: terminalWealth},
+			{Metric: minWithdrawal},
+			{Metric: totalWithdrawn},
+		}
+	)
+	for _, path := range paths {
+		res := plan.Simulate(path)
+		if res.Survived {
+			survived++
+		}
+		if res.Success {
+			succeeded++
+		}
+		dists[0].Values = append(dists[0].Values, res.Terminal)
+		dists[1].Values = append(dists[1].Values, res.MinWithdrawal)
+		dists[2].Values = append(dists[2].Values, res.Withdrawn)
+	}
+
+	ret.Survival = 100 * float64(survived) / float64(len(paths))
+	ret.Success = 100 * float64(succeeded) / float64(len(paths))
+	ret.SafeRate = plan.MaxRate(paths, *target)
+	for _, d := range dists {
+		sort.Float64s(d.Values)
+		ret.Percentiles = append(ret.Percentiles, output.Percentiles(d, pcts, *confidence/100)...)
+	}
+
+	return ret
+}
+
+func printText(r Result) {
+	p := r.Parameters
+	fmt.Println(pf)
+	fmt.Printf("withdrawing %.2f%% of %.0f (%s) for %d years at %.1f%% inflation\n",
+		p.Rate, p.Wealth, p.Rule, p.Years, p.Inflation)
+
+	for _, s := range []struct {
+		title string
+		sim   Simulation
+	}{
+		{"Monte Carlo", r.MonteCarlo},
+		{"Markov Chain", r.MarkovChain},
+	} {
+		fmt.Println()
+		fmt.Printf("=== %s ===\n", s.title)
+		fmt.Printf("survival probability:  %6.2f%%\n", s.sim.Survival)
+		if p.Floor != 0 {
+			fmt.Printf("success probability:   %6.2f%% (withdrawals at least %.0f%% of the initial withdrawal)\n", s.sim.Success, p.Floor)
+		}
+		fmt.Printf("safe withdrawal rate:  %6.2f%% (%.0f%% success probability)\n", s.sim.SafeRate, p.Target)
+		printPercentiles(s.sim.Percentiles)
+	}
+}
+
+func printPercentiles(ps []output.Percentile) {
+	for _, m := range []timeseries.Metric{terminalWealth, minWithdrawal, totalWithdrawn} {
+		fmt.Println()
+		fmt.Println(m.Name)
+		for _, p := range ps {
+			if p.Metric != output.MetricName(m) {
+				continue
+			}
+			fmt.Printf("  %-6s %12.2f  (%g%% CI: %.2f – %.2f)\n",
+				fmt.Sprintf("[P%g]", p.Percentile), p.Value, *confidence, p.Lower, p.Upper)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/octo/portfolio-mcmc/chart"
+	"github.com/octo/portfolio-mcmc/portfolio"
+	"github.com/octo/portfolio-mcmc/timeseries"
+)
+
+var (
+	input     = flag.String("input", "history.csv", "file containing historic returns")
+	validate  = flag.Bool("validate", false, "validate the input file before simulating")
+	window    = flag.Int("window", 36, "window length [months]")
+	benchmark = flag.String("benchmark", "", "series to compute correlation and beta against")
+	chartFile = flag.String("chart", "", "write a chart to this file; the format is determined by the extension (.svg or .png)")
+	chartType = flag.String("chart-type", "returns", `statistic to chart, one of "returns", "volatility", "sharpe", "correlation" and "beta"`)
+	series    = flagStringList("series", "series to compute statistics for; defaults to all series unless -pos is given")
+
+	pf      = portfolio.Portfolio{}
+	derived timeseries.Derivations
+)
+
+func main() {
+	flag.Func("pos", `position as "name:weight"`, pf.FlagFunc())
+	flag.Func("portfolio", `load the portfolio from a definition file, "file.json[:name]"`, pf.FileFlagFunc())
+	flag.Func("derive", `add a derived series "name=func(args)", e.g. "2X WORLD=leverage(WORLD, 2, 0.6)"`, derived.FlagFunc())
+	flag.Parse()
+
+	hist, err := timeseries.LoadFile(*input, *validate)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := derived.Apply(hist); err != nil {
+		log.Fatalf("-derive: %v", err)
+	}
+
+	if err := pf.Validate(hist); err != nil {
+		log.Fatal(err)
+	}
+
+	var bench timeseries.Data
+	if *benchmark != "" {
+		var ok bool
+		if bench, ok = hist[*benchmark]; !ok {
+			log.Fatalf("no such series: %q", *benchmark)
+		}
+	}
+
+	var data []timeseries.Data
+	if len(*series) == 0 && len(pf.Positions) == 0 {
+		for _, h := range hist {
+			data = append(data, h)
+		}
+		sort.Slice(data, func(i, j int) bool {
+			return data[i].Name < data[j].Name
+		})
+	}
+	for _, name := range *series {
+		h, ok := hist[name]
+		if !ok {
+			log.Fatalf("no such series: %q", name)
+		}
+		data = append(data, h)
+	}
+	if len(pf.Positions) != 0 {
+		aligned, err := timeseries.Align(hist, portfolio.Names(pf)...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		res, err := pf.Eval(&timeseries.Backtest{
+			Data: aligned,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		data = append(data, res)
+	}
+
+	if err := writeCSV(os.Stdout, data, bench); err != nil {
+		log.Fatal(err)
+	}
+
+	if *chartFile != "" {
+		if err := writeChart(data, bench); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+func writeChart(data []timeseries.Data, bench timeseries.Data) error {
+	if (*chartType == "correlation" || *chartType == "beta") && bench.Name == "" {
+		return fmt.Errorf("chart type %q requires -benchmark", *chartType)
+	}
+
+	var (
+		title  string
+		format = chart.Percent
+		series []timeseries.Data
+	)
+	for _, h := range data {
+		var r timeseries.Data
+		switch *chartType {
+		case "returns":
+			r, title = h.RollingReturns(*window), "Returns"
+		case "volatility":
+			r, title = h.RollingVolatility(*window), "Volatility"
+		case "sharpe":
+			r, title, format = h.RollingSharpeRatio(*window), "Sharpe Ratio", chart.Number
+		case "correlation":
+			r, title, format = timeseries.RollingCorrelation(h, bench, *window), "Correlation with "+bench.Name, chart.Number
+		case "beta":
+			r, title, format = timeseries.RollingBeta(h, bench, *window), "Beta relative to "+bench.Name, chart.Number
+		default:
+			return fmt.Errorf("unknown chart type %q", *chartType)
+		}
+
+		r.Name = h.Name
+		series = append(series, r)
+	}
+
+	title = fmt.Sprintf("Rolling %s (%d months)", title, *window)
+	return chart.Lines(title, format, series...).WriteFile(*chartFile)
+}
+
+// writeCSV writes one row per series and month. Returns and volatility are
+// annualized and in percent.
+func writeCSV(w io.Writer, data []timeseries.Data, bench timeseries.Data) error {
+	cw := csv.NewWriter(w)
+
+	header := []string{"date", "series", "returns", "volatility", "sharpe_ratio"}
+	if bench.Name != "" {
+		header = append(header, "correlation", "beta")
+	}
+	cw.Write(header)
+
+	for _, h := range data {
+		var (
+			returns    = h.RollingReturns(*window)
+			volatility = h.RollingVolatility(*window)
+			sharpe     = h.RollingSharpeRatio(*window)
+			corr, beta map[string]float64
+		)
+		if bench.Name != "" {
+			corr = byMonth(timeseries.RollingCorrelation(h, bench, *window))
+			beta = byMonth(timeseries.RollingBeta(h, bench, *window))
+		}
+
+		for i, d := range returns.Data {
+			record := []string{
+				d.Date.Format("2006-01-02"),
+				h.Name,
+				formatFloat(d.Value),
+				formatFloat(volatility.Data[i].Value),
+				formatFloat(sharpe.Data[i].Value),
+			}
+			if bench.Name != "" {
+				month := d.Date.Format("2006-01")
+				record = append(record, formatFloat(corr[month]), formatFloat(beta[month]))
+			}
+			cw.Write(record)
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func byMonth(h timeseries.Data) map[string]float64 {
+	ret := make(map[string]float64)
+	for _, d := range h.Data {
+		ret[d.Date.Format("2006-01")] = d.Value
+	}
+	return ret
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', 4, 64)
+}
+
+func flagStringList(name, usage string) *[]string {
+	var ret []string
+
+	flag.Func(name, usage, func(flagVal string) error {
+		ret = append(ret, flagVal)
+		return nil
+	})
+
+	return &ret
+}
@@ -0,0 +1,242 @@
+package timeseries
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// minOverlap is the minimum number of months in which the target and the
+// explanatory series of a regression must all have values.
+const minOverlap = 24
+
+// splice returns the values of proxy before cutover and the values of target
+// from cutover on. If cutover is zero, the first month of target is used.
+// The values taken from proxy are flagged as synthetic.
+func splice(target, proxy Data, cutover time.Time) (Data, error) {
+	if len(target.Data) == 0 {
+		return Data{}, fmt.Errorf("%s: no data", target.Name)
+	}
+	if cutover.IsZero() {
+		cutover = target.Data[0].Date
+	}
+	cut := monthIndex(cutover)
+
+	var ret Data
+	for _, d := range proxy.Data {
+		if monthIndex(d.Date) < cut {
+			d.Synthetic = true
+			ret.Data = append(ret.Data, d)
+		}
+	}
+	for _, d := range target.Data {
+		if monthIndex(d.Date) >= cut {
+			ret.Data = append(ret.Data, d)
+		}
+	}
+
+	return ret, checkContiguous(ret)
+}
+
+// regress estimates the months that are missing in target from a linear
+// regression on xs, fitted over the months in which all series have values.
+// Every estimate gets a residual of the fit drawn from rng, so that the
+// backfilled months are as volatile as the observed ones. Only months for
+// which all of xs have values are returned. The estimated values are flagged
+// as synthetic.
+func regress(target Data, xs []Data, rng *rand.Rand) (Data, error) {
+	values := make([]map[int]float64, len(xs))
+	for i, x := range xs {
+		values[i] = make(map[int]float64)
+		for _, d := range x.Data {
+			values[i][monthIndex(d.Date)] = d.Value
+		}
+	}
+	// row returns the regressors of month m, including the intercept.
+	row := func(m int) ([]float64, bool) {
+		ret := []float64{1}
+		for i := range xs {
+			v, ok := values[i][m]
+			if !ok {
+				return nil, false
+			}
+			ret = append(ret, v)
+		}
+		return ret, true
+	}
+
+	var (
+		observed = make(map[int]Datum)
+		rows     [][]float64
+		ys       []float64
+	)
+	for _, d := range target.Data {
+		observed[monthIndex(d.Date)] = d
+		if r, ok := row(monthIndex(d.Date)); ok {
+			rows, ys = append(rows, r), append(ys, d.Value)
+		}
+	}
+	if len(rows) < minOverlap {
+		return Data{}, fmt.Errorf("got %d overlapping months, want at least %d", len(rows), minOverlap)
+	}
+
+	beta, err := leastSquares(rows, ys)
+	if err != nil {
+		return Data{}, err
+	}
+	residuals := make([]float64, len(rows))
+	for i, r := range rows {
+		residuals[i] = ys[i] - dot(beta, r)
+	}
+
+	var ret Data
+	for _, d := range xs[0].Data {
+		m := monthIndex(d.Date)
+		if o, ok := observed[m]; ok {
+			ret.Data = append(ret.Data, o)
+			continue
+		}
+		r, ok := row(m)
+		if !ok {
+			continue
+		}
+		ret.Data = append(ret.Data, Datum{
+			Date:      d.Date,
+			Value:     math.Max(-1, dot(beta, r)+residuals[rng.Intn(len(residuals))]),
+			Synthetic: true,
+		})
+	}
+	// Months of the target that are not covered by xs[0].
+	for m, d := range observed {
+		if _, ok := values[0][m]; !ok {
+			ret.Data = append(ret.Data, d)
+		}
+	}
+	sort.Slice(ret.Data, func(i, j int) bool {
+		return ret.Data[i].Date.Before(ret.Data[j].Date)
+	})
+
+	return ret, checkContiguous(ret)
+}
+
+// leastSquares returns the coefficients b that minimize the squared error of
+// x·b = y by solving the normal equations.
+func leastSquares(x [][]float64, y []float64) ([]float64, error) {
+	k := len(x[0])
+	// a is the augmented matrix [X'X | X'y].
+	a := make([][]float64, k)
+	for i := range a {
+		a[i] = make([]float64, k+1)
+		for n, r := range x {
+			for j := 0; j < k; j++ {
+				a[i][j] += r[i] * r[j]
+			}
+			a[i][k] += r[i] * y[n]
+		}
+	}
+
+	// Gaussian elimination with partial pivoting.
+	for col := 0; col < k; col++ {
+		pivot := col
+		for i := col + 1; i < k; i++ {
+			if math.Abs(a[i][col]) > math.Abs(a[pivot][col]) {
+				pivot = i
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return nil, errors.New("the explanatory series are linearly dependent")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+
+		for i := col + 1; i < k; i++ {
+			f := a[i][col] / a[col][col]
+			for j := col; j <= k; j++ {
+				a[i][j] -= f * a[col][j]
+			}
+		}
+	}
+
+	b := make([]float64, k)
+	for i := k - 1; i >= 0; i-- {
+		v := a[i][k]
+		for j := i + 1; j < k; j++ {
+			v -= a[i][j] * b[j]
+		}
+		b[i] = v / a[i][i]
+	}
+	return b, nil
+}
+
+func dot(a, b []float64) float64 {
+	var ret float64
+	for i := range a {
+		ret += a[i] * b[i]
+	}
+	return ret
+}
+
+// checkContiguous returns an error if h has a gap between two months.
+func checkContiguous(h Data) error {
+	for i := 1; i < len(h.Data); i++ {
+		prev, cur := h.Data[i-1].Date, h.Data[i].Date
+		if monthIndex(cur)-monthIndex(prev) != 1 {
+			return fmt.Errorf("no data between %s and %s", prev.Format("2006-01"), cur.Format("2006-01"))
+		}
+	}
+	return nil
+}
+
+// Align returns the named series of hist, restricted to the months covered by
+// all of them. Without names, all series are aligned. The series may cover
+// different periods; only months covered by all of them are simulated.
+// Backtest and MonteCarlo expect aligned series, because they select months
+// by index. It is an error if no month is covered by all series.
+func Align(hist map[string]Data, names ...string) (map[string]Data, error) {
+	if len(names) == 0 {
+		for name := range hist {
+			names = append(names, name)
+		}
+	}
+
+	count := make(map[int]int)
+	for _, name := range names {
+		for _, d := range hist[name].Data {
+			count[monthIndex(d.Date)]++
+		}
+	}
+
+	ret := make(map[string]Data, len(names))
+	for _, name := range names {
+		h := Data{Name: hist[name].Name}
+		for _, d := range hist[name].Data {
+			if count[monthIndex(d.Date)] == len(names) {
+				h.Data = append(h.Data, d)
+			}
+		}
+		ret[name] = h
+	}
+
+	if len(names) != 0 && len(ret[names[0]].Data) == 0 {
+		sorted := append([]string(nil), names...)
+		sort.Strings(sorted)
+		return nil, fmt.Errorf("no month is covered by all of %q", sorted)
+	}
+	return ret, nil
+}
+
+// SyntheticMonths returns the number of months in which at least one of the
+// named series of hist has a synthetic value.
+func SyntheticMonths(hist map[string]Data, names ...string) int {
+	months := make(map[int]bool)
+	for _, name := range names {
+		for _, d := range hist[name].Data {
+			if d.Synthetic {
+				months[monthIndex(d.Date)] = true
+			}
+		}
+	}
+	return len(months)
+}
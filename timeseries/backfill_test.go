@@ -0,0 +1,164 @@
+package timeseries
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestSplice(t *testing.T) {
+	jan := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	proxy := newTestData("", jan, []float64{.1, .2, .3, .4})
+	target := newTestData("", jan.AddDate(0, 2, 0), []float64{.03, .04})
+
+	cases := []struct {
+		name    string
+		target  Data
+		cutover time.Time
+		want    []float64
+		wantErr bool
+	}{
+		{
+			name:   "first month",
+			target: target,
+			want:   []float64{.1, .2, .03, .04},
+		},
+		{
+			name:    "cutover",
+			target:  target,
+			cutover: jan.AddDate(0, 3, 0),
+			want:    []float64{.1, .2, .3, .04},
+		},
+		{
+			name:    "gap",
+			target:  newTestData("", jan.AddDate(0, 6, 0), []float64{.03}),
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := splice(tc.target, proxy, tc.cutover)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("splice() = %v, want error %v", err, tc.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			var values []float64
+			for i, d := range got.Data {
+				values = append(values, d.Value)
+				if want := d.Date.Before(jan.AddDate(0, 2, 0)) || (!tc.cutover.IsZero() && d.Date.Before(tc.cutover)); d.Synthetic != want {
+					t.Errorf("Data[%d].Synthetic = %v, want %v", i, d.Synthetic, want)
+				}
+			}
+			if diff := cmp.Diff(tc.want, values); diff != "" {
+				t.Errorf("splice() differs (-want/+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRegress(t *testing.T) {
+	start := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	var xs, ys []float64
+	for i := 0; i < 36; i++ {
+		x := .05 * math.Sin(float64(i))
+		xs = append(xs, x)
+		ys = append(ys, .001+2*x)
+	}
+	x := newTestData("", start, xs)
+	// The target lacks the first six months.
+	target := newTestData("", start.AddDate(0, 6, 0), ys[6:])
+
+	got, err := regress(target, []Data{x}, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var values []float64
+	for _, d := range got.Data {
+		values = append(values, d.Value)
+	}
+	if diff := cmp.Diff(ys, values, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+		t.Errorf("regress() differs (-want/+got):\n%s", diff)
+	}
+	if got := got.Synthetic(); got != 6 {
+		t.Errorf("Synthetic() = %d, want 6", got)
+	}
+
+	short := newTestData("", start.AddDate(0, 30, 0), ys[30:])
+	if _, err := regress(short, []Data{x}, rand.New(rand.NewSource(1))); err == nil {
+		t.Error("regress() = nil, want error for too few overlapping months")
+	}
+	if _, err := regress(target, []Data{x, x}, rand.New(rand.NewSource(1))); err == nil {
+		t.Error("regress() = nil, want error for linearly dependent series")
+	}
+}
+
+func TestAlign(t *testing.T) {
+	jan := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	hist := map[string]Data{
+		"A": newTestData("", jan, []float64{1, 2, 3, 4}),
+		"B": newTestData("", jan.AddDate(0, 1, 0), []float64{5, 6, 7, 8}),
+		"C": newTestData("", jan.AddDate(0, 2, 0), []float64{9}),
+	}
+
+	want := map[string]Data{
+		"A": newTestData("", jan.AddDate(0, 1, 0), []float64{2, 3, 4}),
+		"B": newTestData("", jan.AddDate(0, 1, 0), []float64{5, 6, 7}),
+	}
+	got, err := Align(hist, "A", "B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Align() differs (-want/+got):\n%s", diff)
+	}
+
+	got, err = Align(hist)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got["A"].Data) != 1 || len(got["C"].Data) != 1 {
+		t.Errorf("Align() = %v, want one month per series", got)
+	}
+
+	hist["D"] = newTestData("", jan.AddDate(1, 0, 0), []float64{10})
+	if _, err := Align(hist, "A", "D"); err == nil {
+		t.Error("Align() = nil, want error for series without common months")
+	}
+}
+
+func TestRegressDeterministic(t *testing.T) {
+	start := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	var xs, ys []float64
+	for i := 0; i < 36; i++ {
+		xs = append(xs, .05*math.Sin(float64(i)))
+		ys = append(ys, .05*math.Cos(float64(i)))
+	}
+
+	d := Derivation{Name: "Y", Func: Regress, Series: []string{"Y", "X"}}
+	var runs [2][]float64
+	for i := range runs {
+		hist := map[string]Data{
+			"X": newTestData("", start, xs),
+			"Y": newTestData("", start.AddDate(0, 6, 0), ys[6:]),
+		}
+		if err := d.Apply(hist); err != nil {
+			t.Fatal(err)
+		}
+		for _, d := range hist["Y"].Data {
+			runs[i] = append(runs[i], d.Value)
+		}
+	}
+	if diff := cmp.Diff(runs[0], runs[1]); diff != "" {
+		t.Errorf("Apply() differs between runs (-first/+second):\n%s", diff)
+	}
+}
@@ -10,6 +10,12 @@ import (
 type Backtest struct {
 	Data map[string]Data
 
+	// Start is the index of the first month to iterate over.
+	Start int
+	// Months limits the number of months to iterate over. Zero means no
+	// limit.
+	Months int
+
 	init  bool
 	index int
 }
@@ -25,12 +31,13 @@ func (b *Backtest) Next() (time.Time, bool) {
 
 	if b.init {
 		b.index++
-		if b.index >= len(data) {
-			return time.Time{}, false
-		}
 	} else {
+		b.index = b.Start
 		b.init = true
 	}
+	if b.index >= len(data) || (b.Months > 0 && b.index >= b.Start+b.Months) {
+		return time.Time{}, false
+	}
 
 	return data[b.index].Date, true
 }
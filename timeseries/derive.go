@@ -0,0 +1,301 @@
+package timeseries
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Functions of derived series.
+const (
+	Leverage = "leverage"
+	Mix      = "mix"
+	Inverse  = "inverse"
+	Splice   = "splice"
+	Regress  = "regress"
+)
+
+// Derivation defines a synthetic series that is computed from existing
+// series, e.g. a leveraged version of an index.
+type Derivation struct {
+	Name string
+	// Func is one of Leverage, Mix, Inverse, Splice and Regress.
+	Func   string
+	Series []string
+	// Weights holds the weight of each series for Mix.
+	Weights []float64
+	// Factor is the leverage factor for Leverage.
+	Factor float64
+	// Fee is the annual fee in percent for Leverage and Inverse.
+	Fee float64
+	// Cutover is the first month of the target series used by Splice. Zero
+	// means the target's first month.
+	Cutover time.Time
+}
+
+// ParseDerivation parses a derived series in the form "<name>=<func>(<args>)".
+// The following functions are supported:
+//
+//	leverage(<series>, <factor>[, <fee>])
+//	mix(<series>:<weight>, <series>:<weight>[, ...])
+//	inverse(<series>[, <fee>])
+//	splice(<target>, <proxy>[, <cutover>])
+//	regress(<target>, <series>[, ...])
+//
+// leverage approximates a fund that rebalances its leverage daily. With monthly
+// data, the volatility drag of daily rebalancing is estimated from the variance
+// of the twelve months up to and including the current one. inverse is leverage
+// with a factor of -1. The fee is annual in percent and should include the
+// product's financing costs. mix rebalances to the given weights every month.
+//
+// splice and regress backfill a series with a short history. splice uses the
+// returns of proxy before the cutover month, given as "2006-01", and the
+// returns of target after. regress estimates the missing months of target from
+// a linear regression on the other series plus a randomly drawn residual of the
+// fit; the draws are the same in every run. The backfilled months are flagged
+// as synthetic. Both may replace the target, i.e. the name may be the name of
+// the target.
+func ParseDerivation(s string) (Derivation, error) {
+	i := strings.Index(s, "=")
+	if i == -1 {
+		return Derivation{}, fmt.Errorf(`got %q, want "<name>=<func>(<args>)"`, s)
+	}
+	d := Derivation{
+		Name: strings.TrimSpace(s[:i]),
+	}
+	expr := strings.TrimSpace(s[i+1:])
+
+	open := strings.Index(expr, "(")
+	if d.Name == "" || open == -1 || !strings.HasSuffix(expr, ")") {
+		return Derivation{}, fmt.Errorf(`got %q, want "<name>=<func>(<args>)"`, s)
+	}
+	d.Func = strings.TrimSpace(expr[:open])
+
+	var args []string
+	for _, arg := range strings.Split(expr[open+1:len(expr)-1], ",") {
+		args = append(args, strings.TrimSpace(arg))
+	}
+
+	var err error
+	switch d.Func {
+	case Leverage:
+		if len(args) != 2 && len(args) != 3 {
+			return Derivation{}, fmt.Errorf("%s: got %d arguments, want 2 or 3", d.Func, len(args))
+		}
+		d.Series = args[:1]
+		if d.Factor, err = strconv.ParseFloat(args[1], 64); err != nil {
+			return Derivation{}, fmt.Errorf("%s: factor: %w", d.Func, err)
+		}
+		if len(args) == 3 {
+			if d.Fee, err = strconv.ParseFloat(args[2], 64); err != nil {
+				return Derivation{}, fmt.Errorf("%s: fee: %w", d.Func, err)
+			}
+		}
+	case Inverse:
+		if len(args) != 1 && len(args) != 2 {
+			return Derivation{}, fmt.Errorf("%s: got %d arguments, want 1 or 2", d.Func, len(args))
+		}
+		d.Series = args[:1]
+		d.Factor = -1
+		if len(args) == 2 {
+			if d.Fee, err = strconv.ParseFloat(args[1], 64); err != nil {
+				return Derivation{}, fmt.Errorf("%s: fee: %w", d.Func, err)
+			}
+		}
+	case Mix:
+		if len(args) < 2 {
+			return Derivation{}, fmt.Errorf("%s: got %d arguments, want at least 2", d.Func, len(args))
+		}
+		for _, arg := range args {
+			j := strings.LastIndex(arg, ":")
+			if j == -1 {
+				return Derivation{}, fmt.Errorf(`%s: got %q, want "<series>:<weight>"`, d.Func, arg)
+			}
+			w, err := strconv.ParseFloat(arg[j+1:], 64)
+			if err != nil {
+				return Derivation{}, fmt.Errorf("%s: weight: %w", d.Func, err)
+			}
+			if w <= 0 {
+				return Derivation{}, fmt.Errorf("%s: got weight %g, want a positive weight", d.Func, w)
+			}
+			d.Series = append(d.Series, strings.TrimSpace(arg[:j]))
+			d.Weights = append(d.Weights, w)
+		}
+	case Splice:
+		if len(args) != 2 && len(args) != 3 {
+			return Derivation{}, fmt.Errorf("%s: got %d arguments, want 2 or 3", d.Func, len(args))
+		}
+		d.Series = args[:2]
+		if len(args) == 3 {
+			if d.Cutover, err = time.Parse("2006-01", args[2]); err != nil {
+				return Derivation{}, fmt.Errorf("%s: cutover: %w", d.Func, err)
+			}
+		}
+	case Regress:
+		if len(args) < 2 {
+			return Derivation{}, fmt.Errorf("%s: got %d arguments, want at least 2", d.Func, len(args))
+		}
+		d.Series = args
+	default:
+		return Derivation{}, fmt.Errorf("unknown function %q, want one of %q, %q, %q, %q and %q", d.Func, Leverage, Mix, Inverse, Splice, Regress)
+	}
+
+	for _, name := range d.Series {
+		if name == "" {
+			return Derivation{}, fmt.Errorf("%s: empty series name", d.Func)
+		}
+	}
+	if d.Fee < 0 || d.Fee >= 100 {
+		return Derivation{}, fmt.Errorf("%s: got fee %g, want a value in [0, 100)", d.Func, d.Fee)
+	}
+
+	return d, nil
+}
+
+// Apply computes the derived series and adds it to hist. Splice and Regress
+// replace their target if the name is the target's name.
+func (d Derivation) Apply(hist map[string]Data) error {
+	replace := (d.Func == Splice || d.Func == Regress) && d.Name == d.Series[0]
+	if _, ok := hist[d.Name]; ok && !replace {
+		return fmt.Errorf("%s: series already exists", d.Name)
+	}
+
+	var inputs []Data
+	for _, name := range d.Series {
+		h, ok := hist[name]
+		if !ok {
+			return fmt.Errorf("%s: no data for %q", d.Name, name)
+		}
+		inputs = append(inputs, h)
+	}
+
+	var (
+		ret Data
+		err error
+	)
+	switch d.Func {
+	case Leverage, Inverse:
+		ret = leverage(inputs[0], d.Factor, d.Fee)
+	case Mix:
+		ret = mix(inputs, d.Weights)
+	case Splice:
+		ret, err = splice(inputs[0], inputs[1], d.Cutover)
+	case Regress:
+		// A fixed seed makes the backfilled months the same in every run.
+		ret, err = regress(inputs[0], inputs[1:], rand.New(rand.NewSource(regressSeed)))
+	default:
+		return fmt.Errorf("%s: unknown function %q", d.Name, d.Func)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", d.Name, err)
+	}
+	ret.Name = d.Name
+
+	hist[d.Name] = ret
+	return nil
+}
+
+// Derivations is a list of derived series. Later derivations may use the
+// series of earlier ones.
+type Derivations []Derivation
+
+// FlagFunc returns a function that can be passed to flag.Func() for flag
+// parsing.
+func (ds *Derivations) FlagFunc() func(string) error {
+	return func(flagValue string) error {
+		d, err := ParseDerivation(flagValue)
+		if err != nil {
+			return err
+		}
+		*ds = append(*ds, d)
+		return nil
+	}
+}
+
+// Apply adds all derived series to hist, in order.
+func (ds Derivations) Apply(hist map[string]Data) error {
+	for _, d := range ds {
+		if err := d.Apply(hist); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// regressSeed seeds the residuals drawn by regress.
+const regressSeed = 1
+
+// dragWindow is the number of months used to estimate the volatility drag.
+const dragWindow = 12
+
+// leverage returns the returns of a fund that holds factor times h,
+// rebalanced continuously. For geometric Brownian motion, the log growth of
+// such a fund is factor times the log growth of h, minus
+// (factor²-factor)/2 times the variance of the log returns.
+func leverage(h Data, factor, fee float64) Data {
+	logs := make([]float64, len(h.Data))
+	for i, d := range h.Data {
+		logs[i] = math.Log(1 + d.Value)
+	}
+
+	var (
+		ret       Data
+		drag      = (factor*factor - factor) / 2
+		feeFactor = math.Pow(1-fee/100, 1.0/12)
+	)
+	for i, d := range h.Data {
+		// The drag is realized during the month, so the variance is
+		// estimated from the twelve months up to and including it; the
+		// first year uses all months so far.
+		start := i + 1 - dragWindow
+		if start < 0 {
+			start = 0
+		}
+
+		g := math.Exp(factor*logs[i]-drag*Variance(logs[start:i+1])) * feeFactor
+		ret.Data = append(ret.Data, Datum{
+			Date:      d.Date,
+			Value:     math.Max(-1, g-1),
+			Synthetic: d.Synthetic,
+		})
+	}
+
+	return ret
+}
+
+// mix returns the returns of a portfolio of inputs that is rebalanced to the
+// given weights every month. Only months present in all inputs are included.
+func mix(inputs []Data, weights []float64) Data {
+	var total float64
+	for _, w := range weights {
+		total += w
+	}
+
+	values := make([]map[time.Time]Datum, len(inputs))
+	for i, h := range inputs {
+		values[i] = make(map[time.Time]Datum)
+		for _, d := range h.Data {
+			values[i][d.Date] = d
+		}
+	}
+
+	var ret Data
+outer:
+	for _, d := range inputs[0].Data {
+		m := Datum{Date: d.Date}
+		for i := range inputs {
+			v, ok := values[i][d.Date]
+			if !ok {
+				continue outer
+			}
+			m.Value += weights[i] / total * v.Value
+			m.Synthetic = m.Synthetic || v.Synthetic
+		}
+		ret.Data = append(ret.Data, m)
+	}
+
+	return ret
+}
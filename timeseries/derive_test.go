@@ -0,0 +1,144 @@
+package timeseries
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestParseDerivation(t *testing.T) {
+	cases := []struct {
+		input   string
+		want    Derivation
+		wantErr bool
+	}{
+		{
+			input: "2X WORLD=leverage(WORLD, 2, 0.6)",
+			want:  Derivation{Name: "2X WORLD", Func: Leverage, Series: []string{"WORLD"}, Factor: 2, Fee: 0.6},
+		},
+		{
+			input: "60/40=mix(WORLD:60, BONDS:40)",
+			want:  Derivation{Name: "60/40", Func: Mix, Series: []string{"WORLD", "BONDS"}, Weights: []float64{60, 40}},
+		},
+		{
+			input: "SHORT=inverse(WORLD)",
+			want:  Derivation{Name: "SHORT", Func: Inverse, Series: []string{"WORLD"}, Factor: -1},
+		},
+		{
+			input: "ESG=splice(ESG, WORLD, 2012-06)",
+			want:  Derivation{Name: "ESG", Func: Splice, Series: []string{"ESG", "WORLD"}, Cutover: time.Date(2012, time.June, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		{
+			input: "ESG=regress(ESG, WORLD, BONDS)",
+			want:  Derivation{Name: "ESG", Func: Regress, Series: []string{"ESG", "WORLD", "BONDS"}},
+		},
+		{input: "leverage(WORLD, 2)", wantErr: true},
+		{input: "X=splice(ESG, WORLD, June)", wantErr: true},
+		{input: "X=regress(ESG)", wantErr: true},
+		{input: "X=leverage(WORLD)", wantErr: true},
+		{input: "X=mix(WORLD:60)", wantErr: true},
+		{input: "X=mix(WORLD:60, BONDS)", wantErr: true},
+		{input: "X=square(WORLD)", wantErr: true},
+		{input: "X=inverse(WORLD, 100)", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.input, func(t *testing.T) {
+			got, err := ParseDerivation(tc.input)
+			if gotErr := err != nil; gotErr != tc.wantErr {
+				t.Fatalf("ParseDerivation() = %v, want error %v", err, tc.wantErr)
+			}
+			if diff := cmp.Diff(tc.want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("ParseDerivation() differs (-want/+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDerivationsApply(t *testing.T) {
+	hist := map[string]Data{
+		"A": newTestData("A", testStart, []float64{.01, .01, .01}),
+		"B": newTestData("B", testStart, []float64{.03, -.01, 0}),
+	}
+
+	var ds Derivations
+	for _, s := range []string{
+		"2X A=leverage(A, 2)",
+		"SHORT A=inverse(A, 1.2)",
+		"MIX=mix(A:3, B:1)",
+		"2X MIX=leverage(MIX, 2)",
+	} {
+		if err := ds.FlagFunc()(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ds.Apply(hist); err != nil {
+		t.Fatal(err)
+	}
+
+	// A has no volatility, so there is no volatility drag.
+	fee := math.Pow(.988, 1.0/12)
+	want := map[string][]float64{
+		"2X A":    {1.01*1.01 - 1, 1.01*1.01 - 1, 1.01*1.01 - 1},
+		"SHORT A": {fee/1.01 - 1, fee/1.01 - 1, fee/1.01 - 1},
+		"MIX":     {.015, .005, .0075},
+	}
+	for name, values := range want {
+		h, ok := hist[name]
+		if !ok {
+			t.Fatalf("missing series %q", name)
+		}
+		var got []float64
+		for _, d := range h.Data {
+			got = append(got, d.Value)
+		}
+		if diff := cmp.Diff(values, got, cmpopts.EquateApprox(0, 1e-12)); diff != "" {
+			t.Errorf("%s differs (-want/+got):\n%s", name, diff)
+		}
+	}
+
+	// The volatility of MIX reduces the growth of the leveraged series
+	// below the square of MIX's growth.
+	if got, max := hist["2X MIX"].Growth(), math.Pow(hist["MIX"].Growth(), 2); got >= max {
+		t.Errorf("Growth() = %g, want less than %g", got, max)
+	}
+
+	if err := ds.Apply(hist); err == nil {
+		t.Error("Apply() = nil, want error for existing series")
+	}
+	jan := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	short := map[string]Data{
+		"A": newTestData("", jan.AddDate(0, 1, 0), []float64{.02}),
+		"B": newTestData("", jan, []float64{.01, .01}),
+	}
+	if err := (Derivation{Name: "A", Func: Splice, Series: []string{"A", "B"}}).Apply(short); err != nil {
+		t.Errorf("Apply() = %v, want the target to be replaced", err)
+	}
+	if got := short["A"]; len(got.Data) != 2 || got.Synthetic() != 1 {
+		t.Errorf("Apply() = %v, want two months, one of them synthetic", got.Data)
+	}
+	if err := (Derivation{Name: "X", Func: Inverse, Series: []string{"C"}, Factor: -1}).Apply(hist); err == nil {
+		t.Error("Apply() = nil, want error for missing series")
+	}
+}
+
+func TestLeverageNoLookAhead(t *testing.T) {
+	h := newTestData("A", testStart, []float64{.01, .2, -.2, .2})
+
+	// The drag of each month only depends on the months up to it: the first
+	// month has no drag, and appending months does not change the past.
+	got := leverage(h, 2, 0)
+	if want := 1.01*1.01 - 1; math.Abs(got.Data[0].Value-want) > 1e-12 {
+		t.Errorf("leverage()[0] = %g, want %g", got.Data[0].Value, want)
+	}
+
+	prefix := leverage(Data{Data: h.Data[:2]}, 2, 0)
+	for i, d := range prefix.Data {
+		if got.Data[i].Value != d.Value {
+			t.Errorf("leverage()[%d] = %g, want %g as without later months", i, got.Data[i].Value, d.Value)
+		}
+	}
+}
@@ -15,9 +15,13 @@ import (
 // sequence.
 // Implements the QuoteProvider interface.
 type MarkovChain struct {
+	// Months is the number of months to generate. Zero means 30 years.
+	Months int
+
 	data map[int]edges
 
 	returnsPermille int
+	months          int
 	date            time.Time
 }
 
@@ -93,7 +97,11 @@ func calcReturnsPermille(t0, t1 float64) int {
 // Next advances the time and transitions to the next state.
 func (m *MarkovChain) Next() (time.Time, bool) {
 	m.date = m.date.AddDate(0, 1, 0)
-	if m.date.After(time.Now().AddDate(30, 0, 0)) {
+	m.months++
+	if m.Months > 0 && m.months > m.Months {
+		return time.Time{}, false
+	}
+	if m.Months == 0 && m.date.After(time.Now().AddDate(30, 0, 0)) {
 		return time.Time{}, false
 	}
 
@@ -0,0 +1,102 @@
+package timeseries
+
+import (
+	"math"
+	"sort"
+)
+
+// Metric is a statistic that can be calculated for a series.
+type Metric struct {
+	Name string
+	// Percent is true if Value returns a percentage.
+	Percent bool
+	// LowerIsBetter is true for risk metrics such as volatility.
+	LowerIsBetter bool
+	Value         func(Data) float64
+}
+
+var (
+	Returns     = Metric{Name: "returns", Percent: true, Value: Data.Returns}
+	Volatility  = Metric{Name: "volatility", Percent: true, LowerIsBetter: true, Value: Data.Volatility}
+	SharpeRatio = Metric{Name: "sharpe ratio", Value: Data.SharpeRatio}
+	MaxDrawdown = Metric{Name: "max drawdown", Percent: true, LowerIsBetter: true, Value: Data.MaxDrawdown}
+	// Growth is the terminal wealth relative to the initial wealth.
+	Growth = Metric{Name: "growth", Value: Data.Growth}
+)
+
+// Metrics is the list of metrics reported by the commands.
+var Metrics = []Metric{Returns, Volatility, SharpeRatio, MaxDrawdown, Growth}
+
+// Distribution holds the values of a metric across many results, e.g. the
+// paths of a Monte Carlo simulation.
+type Distribution struct {
+	Metric
+	// Values holds the metric's values in increasing order.
+	Values []float64
+}
+
+// NewDistribution calculates m for all results.
+func NewDistribution(m Metric, results []Data) Distribution {
+	d := Distribution{
+		Metric: m,
+	}
+	for _, res := range results {
+		d.Values = append(d.Values, m.Value(res))
+	}
+	sort.Float64s(d.Values)
+
+	return d
+}
+
+// Percentile returns the value that p percent of the results are at least as
+// good as. For example, Percentile(95) returns a pessimistic estimate that is
+// exceeded by 95% of results. This matches the "P95" notation in the commands'
+// output.
+func (d Distribution) Percentile(p float64) float64 {
+	if len(d.Values) == 0 {
+		return math.NaN()
+	}
+
+	idx := int(float64(len(d.Values)) * (100 - p) / 100)
+	if d.LowerIsBetter {
+		idx = len(d.Values) - 1 - idx
+	}
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(d.Values) {
+		idx = len(d.Values) - 1
+	}
+
+	return d.Values[idx]
+}
+
+// ConfidenceInterval returns the range that contains the true value of
+// Percentile(p) with the given probability, e.g. 0.95. The interval is
+// derived from the binomial distribution of the number of results below the
+// percentile, so it shrinks with the number of results.
+func (d Distribution) ConfidenceInterval(p, level float64) (lo, hi float64) {
+	n := float64(len(d.Values))
+	if n == 0 {
+		return math.NaN(), math.NaN()
+	}
+
+	q := (100 - p) / 100
+	if d.LowerIsBetter {
+		q = p / 100
+	}
+
+	z := math.Sqrt2 * math.Erfinv(level)
+	half := z * math.Sqrt(n*q*(1-q))
+
+	loIdx := int(math.Floor(n*q - half))
+	if loIdx < 0 {
+		loIdx = 0
+	}
+	hiIdx := int(math.Ceil(n*q + half))
+	if hiIdx >= len(d.Values) {
+		hiIdx = len(d.Values) - 1
+	}
+
+	return d.Values[loIdx], d.Values[hiIdx]
+}
@@ -14,9 +14,12 @@ const expectedDuration = 12 // [months]
 // Implements the QuoteProvider interface.
 type MonteCarlo struct {
 	Data map[string]Data
+	// Months is the number of months to generate. Zero means 30 years.
+	Months int
 
-	index int
-	date  time.Time
+	index  int
+	months int
+	date   time.Time
 }
 
 // Next advances the time and chooses the next month to return data from.
@@ -36,8 +39,12 @@ func (m *MonteCarlo) Next() (time.Time, bool) {
 		m.date = time.Date(year, month, 1, 0, 0, 0, 0, time.Local)
 	}
 	m.date = m.date.AddDate(0, 1, 0)
+	m.months++
 
-	if m.date.After(time.Now().AddDate(30, 0, 0)) {
+	if m.Months > 0 && m.months > m.Months {
+		return time.Time{}, false
+	}
+	if m.Months == 0 && m.date.After(time.Now().AddDate(30, 0, 0)) {
 		return time.Time{}, false
 	}
 
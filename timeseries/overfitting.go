@@ -0,0 +1,166 @@
+package timeseries
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// eulerGamma is the Euler–Mascheroni constant.
+const eulerGamma = 0.5772156649015329
+
+// normCDF returns the cumulative distribution function of the standard normal
+// distribution at x.
+func normCDF(x float64) float64 {
+	return 0.5 * math.Erfc(-x/math.Sqrt2)
+}
+
+// normQuantile returns the quantile function of the standard normal
+// distribution at p.
+func normQuantile(p float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*p-1)
+}
+
+// MonthlySharpeRatio returns the average monthly return divided by the
+// standard deviation of the monthly returns. Unlike SharpeRatio, it is
+// neither compounded nor annualized, as required by the statistics below.
+func (h Data) MonthlySharpeRatio() float64 {
+	sd := h.stdDev()
+	if sd == 0 {
+		return 0
+	}
+	return h.average() / sd
+}
+
+// ProbabilisticSharpeRatio returns the probability that the true monthly
+// Sharpe ratio of h exceeds benchmark, taking the length of h and the
+// skewness and kurtosis of its returns into account (Bailey and López de
+// Prado, 2012).
+func (h Data) ProbabilisticSharpeRatio(benchmark float64) float64 {
+	return normCDF((h.MonthlySharpeRatio() - benchmark) / h.sharpeRatioStdErr())
+}
+
+// ExpectedMaxSharpeRatio returns the monthly Sharpe ratio that the best of
+// trials strategies is expected to reach by chance, if all of them have a
+// true Sharpe ratio of zero and their estimated Sharpe ratios have the given
+// variance.
+func ExpectedMaxSharpeRatio(trials int, variance float64) float64 {
+	if trials < 2 {
+		return 0
+	}
+	n := float64(trials)
+	return math.Sqrt(variance) * ((1-eulerGamma)*normQuantile(1-1/n) + eulerGamma*normQuantile(1-1/(n*math.E)))
+}
+
+// DeflatedSharpeRatio returns the probability that the Sharpe ratio of h,
+// the best of trials strategies, is due to skill rather than selection bias
+// (Bailey and López de Prado, 2014). variance is the variance of the trials'
+// monthly Sharpe ratios, see SharpeRatioVariance.
+func (h Data) DeflatedSharpeRatio(trials int, variance float64) float64 {
+	return h.ProbabilisticSharpeRatio(ExpectedMaxSharpeRatio(trials, variance))
+}
+
+// SharpeRatioVariance returns the variance of the monthly Sharpe ratios of
+// trials.
+func SharpeRatioVariance(trials []Data) float64 {
+	var sr Data
+	for _, h := range trials {
+		sr.Data = append(sr.Data, Datum{Value: h.MonthlySharpeRatio()})
+	}
+	return sr.variance()
+}
+
+// CheckBlocks returns an error if ProbabilityOfOverfitting does not accept
+// the number of blocks.
+func CheckBlocks(blocks int) error {
+	if blocks < 2 || blocks > 24 || blocks%2 != 0 {
+		return fmt.Errorf("got %d blocks, want an even number between 2 and 24", blocks)
+	}
+	return nil
+}
+
+// ProbabilityOfOverfitting estimates the probability that the trial with the
+// best Sharpe ratio in sample does worse than the median trial out of sample,
+// using combinatorially symmetric cross-validation (Bailey et al., 2015). The
+// months are split into an even number of blocks of equal size; remaining
+// months at the end are ignored. Every combination of half of the blocks is
+// used in sample once, with the other half out of sample. The trials must be
+// aligned.
+func ProbabilityOfOverfitting(trials []Data, blocks int) (float64, error) {
+	if err := CheckBlocks(blocks); err != nil {
+		return 0, err
+	}
+	if len(trials) < 2 {
+		return 0, errors.New("need at least two trials")
+	}
+	size := len(trials[0].Data) / blocks
+	if size == 0 {
+		return 0, fmt.Errorf("got %d months, want at least %d", len(trials[0].Data), blocks)
+	}
+
+	// The sums of the returns and of their squares of every block.
+	sum := make([][]float64, len(trials))
+	sq := make([][]float64, len(trials))
+	for i, h := range trials {
+		if len(h.Data) != len(trials[0].Data) {
+			return 0, fmt.Errorf("%q has %d months, want %d", h.Name, len(h.Data), len(trials[0].Data))
+		}
+		sum[i] = make([]float64, blocks)
+		sq[i] = make([]float64, blocks)
+		for j, d := range h.Data[:blocks*size] {
+			sum[i][j/size] += d.Value
+			sq[i][j/size] += d.Value * d.Value
+		}
+	}
+
+	sharpe := func(i int, mask uint) float64 {
+		var s, s2 float64
+		for b := 0; b < blocks; b++ {
+			if mask&(1<<uint(b)) != 0 {
+				s += sum[i][b]
+				s2 += sq[i][b]
+			}
+		}
+		n := float64(bits.OnesCount(mask) * size)
+		avg := s / n
+		variance := s2/n - avg*avg
+		if variance <= 0 {
+			return 0
+		}
+		return avg / math.Sqrt(variance)
+	}
+
+	all := uint(1)<<uint(blocks) - 1
+	var combinations, overfit int
+	for mask := uint(0); mask <= all; mask++ {
+		if bits.OnesCount(mask) != blocks/2 {
+			continue
+		}
+
+		best, bestSharpe := 0, math.Inf(-1)
+		for i := range trials {
+			if sr := sharpe(i, mask); sr > bestSharpe {
+				best, bestSharpe = i, sr
+			}
+		}
+
+		oos := sharpe(best, all&^mask)
+		rank := 1
+		for i := range trials {
+			if i != best && sharpe(i, all&^mask) < oos {
+				rank++
+			}
+		}
+
+		// The logit of the relative rank is positive if the best trial in
+		// sample is above the median out of sample.
+		w := float64(rank) / float64(len(trials)+1)
+		if math.Log(w/(1-w)) <= 0 {
+			overfit++
+		}
+		combinations++
+	}
+
+	return float64(overfit) / float64(combinations), nil
+}
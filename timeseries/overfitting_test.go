@@ -0,0 +1,93 @@
+package timeseries
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestProbabilisticSharpeRatio(t *testing.T) {
+	// Symmetric returns with an average of zero.
+	h := newTestData("a", testStart, []float64{.01, -.01, .02, -.02, .01, -.01})
+	if got, want := h.ProbabilisticSharpeRatio(0), .5; !cmp.Equal(got, want, cmpopts.EquateApprox(0, 1e-9)) {
+		t.Errorf("ProbabilisticSharpeRatio(0) = %g, want %g", got, want)
+	}
+
+	h = newTestData("b", testStart, []float64{.03, -.01, .02, 0, .01, .01})
+	if got := h.ProbabilisticSharpeRatio(0); got <= .5 || got >= 1 {
+		t.Errorf("ProbabilisticSharpeRatio(0) = %g, want between 0.5 and 1", got)
+	}
+	if got, want := h.DeflatedSharpeRatio(1, .01), h.ProbabilisticSharpeRatio(0); got != want {
+		t.Errorf("DeflatedSharpeRatio(1 trial) = %g, want %g", got, want)
+	}
+	if few, many := h.DeflatedSharpeRatio(10, .01), h.DeflatedSharpeRatio(1000, .01); many >= few {
+		t.Errorf("DeflatedSharpeRatio(1000 trials) = %g, want less than %g (10 trials)", many, few)
+	}
+}
+
+func TestExpectedMaxSharpeRatio(t *testing.T) {
+	cases := []struct {
+		trials   int
+		variance float64
+		want     float64
+	}{
+		{trials: 1, variance: 1, want: 0},
+		{trials: 100, variance: 1, want: 2.5306},
+		{trials: 100, variance: .25, want: 2.5306 / 2},
+		{trials: 1000, variance: 1, want: 3.2551},
+	}
+
+	for _, tc := range cases {
+		got := ExpectedMaxSharpeRatio(tc.trials, tc.variance)
+		if !cmp.Equal(got, tc.want, cmpopts.EquateApprox(0, 1e-4)) {
+			t.Errorf("ExpectedMaxSharpeRatio(%d, %g) = %.4f, want %.4f", tc.trials, tc.variance, got, tc.want)
+		}
+	}
+}
+
+func TestProbabilityOfOverfitting(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	newTrials := func(n int, drift func(i int) float64) []Data {
+		var ret []Data
+		for i := 0; i < n; i++ {
+			var values []float64
+			for j := 0; j < 240; j++ {
+				values = append(values, drift(i)+.04*rng.NormFloat64())
+			}
+			ret = append(ret, newTestData("trial", testStart, values))
+		}
+		return ret
+	}
+
+	// Without skill, the best trial in sample is a random one out of sample.
+	noise, err := ProbabilityOfOverfitting(newTrials(50, func(int) float64 { return 0 }), 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(noise-.5) > .2 {
+		t.Errorf("ProbabilityOfOverfitting(noise) = %.2f, want about 0.5", noise)
+	}
+
+	// One trial is much better than the others.
+	skill, err := ProbabilityOfOverfitting(newTrials(50, func(i int) float64 {
+		if i == 0 {
+			return .03
+		}
+		return 0
+	}), 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if skill != 0 {
+		t.Errorf("ProbabilityOfOverfitting(skill) = %.2f, want 0", skill)
+	}
+
+	for _, blocks := range []int{0, 3, 26} {
+		if _, err := ProbabilityOfOverfitting(newTrials(2, func(int) float64 { return 0 }), blocks); err == nil {
+			t.Errorf("ProbabilityOfOverfitting(%d blocks) = nil, want error", blocks)
+		}
+	}
+}
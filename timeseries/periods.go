@@ -0,0 +1,146 @@
+package timeseries
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// CalendarYears returns the compounded return of each calendar year as a
+// fraction, dated with the last month of the year present in h. The first
+// and last year may be partial years.
+func (h Data) CalendarYears() Data {
+	ret := Data{
+		Name: h.Name,
+	}
+
+	for _, d := range h.Data {
+		n := len(ret.Data)
+		if n == 0 || ret.Data[n-1].Date.Year() != d.Date.Year() {
+			ret.Data = append(ret.Data, Datum{
+				Date:  d.Date,
+				Value: d.Value,
+			})
+			continue
+		}
+
+		last := &ret.Data[n-1]
+		last.Date = d.Date
+		last.Value = (1+last.Value)*(1+d.Value) - 1
+	}
+
+	return ret
+}
+
+// CalendarYear holds the returns of a calendar year as fractions.
+type CalendarYear struct {
+	Year int
+	// Months holds the return of each month, January first. Months
+	// without data are NaN.
+	Months [12]float64
+	// Returns is the compounded return of the months with data.
+	Returns float64
+	// Partial is true if data is missing for some months.
+	Partial bool
+}
+
+// Calendar returns the monthly and compounded returns of each calendar year
+// present in h. The compounded returns are those of CalendarYears.
+func (h Data) Calendar() []CalendarYear {
+	var ret []CalendarYear
+	for _, y := range h.CalendarYears().Data {
+		cy := CalendarYear{
+			Year:    y.Date.Year(),
+			Returns: y.Value,
+		}
+		for i := range cy.Months {
+			cy.Months[i] = math.NaN()
+		}
+		ret = append(ret, cy)
+	}
+
+	i := 0
+	for _, d := range h.Data {
+		for ret[i].Year != d.Date.Year() {
+			i++
+		}
+		ret[i].Months[d.Date.Month()-1] = d.Value
+	}
+
+	for i := range ret {
+		for _, v := range ret[i].Months {
+			if math.IsNaN(v) {
+				ret[i].Partial = true
+			}
+		}
+	}
+
+	return ret
+}
+
+// Drawdown is a period in which the cumulative value was below its previous
+// peak.
+type Drawdown struct {
+	// Peak is the last month at the previous peak. For a drawdown starting
+	// in the first month, it is the month before the first month.
+	Peak time.Time
+	// Trough is the month with the lowest value.
+	Trough time.Time
+	// Recovery is the first month back at the previous peak. It is zero if
+	// the value has not recovered by the end of the series.
+	Recovery time.Time
+	// Depth is the decline from the peak to the trough in percent.
+	Depth float64
+	// Months is the number of months below the peak.
+	Months int
+}
+
+// Recovered returns true if the value returned to the previous peak.
+func (d Drawdown) Recovered() bool {
+	return !d.Recovery.IsZero()
+}
+
+// Drawdowns returns all drawdowns of h, the deepest first.
+func (h Data) Drawdowns() []Drawdown {
+	var (
+		ret     []Drawdown
+		current *Drawdown
+		uw      = h.Underwater()
+	)
+
+	for i, d := range uw.Data {
+		if d.Value >= 0 {
+			if current != nil {
+				current.Recovery = d.Date
+				ret = append(ret, *current)
+				current = nil
+			}
+			continue
+		}
+
+		if current == nil {
+			peak := d.Date.AddDate(0, 0, -d.Date.Day())
+			if i > 0 {
+				peak = uw.Data[i-1].Date
+			}
+			current = &Drawdown{
+				Peak: peak,
+			}
+		}
+
+		current.Months++
+		if depth := -100 * d.Value; depth > current.Depth {
+			current.Depth = depth
+			current.Trough = d.Date
+		}
+	}
+	if current != nil {
+		ret = append(ret, *current)
+	}
+
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Depth > ret[j].Depth
+	})
+
+	return ret
+}
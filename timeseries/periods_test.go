@@ -0,0 +1,93 @@
+package timeseries
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestCalendarYears(t *testing.T) {
+	// November 1999 to February 2001.
+	h := newTestData("", time.Date(1999, time.November, 1, 0, 0, 0, 0, time.UTC), []float64{
+		.10, -.10,
+		.01, .01, .01, .01, .01, .01, .01, .01, .01, .01, .01, .01,
+		-.5, 1,
+	})
+
+	want := Data{
+		Data: []Datum{
+			{Date: time.Date(1999, time.December, 1, 0, 0, 0, 0, time.UTC), Value: 1.1*0.9 - 1},
+			{Date: time.Date(2000, time.December, 1, 0, 0, 0, 0, time.UTC), Value: 0.12682503},
+			{Date: time.Date(2001, time.February, 1, 0, 0, 0, 0, time.UTC), Value: 0},
+		},
+	}
+	if diff := cmp.Diff(want, h.CalendarYears(), cmpopts.EquateApprox(0, 1e-8)); diff != "" {
+		t.Errorf("CalendarYears() differs (-want/+got):\n%s", diff)
+	}
+}
+
+func TestCalendar(t *testing.T) {
+	h := newTestData("", time.Date(1999, time.November, 1, 0, 0, 0, 0, time.UTC), []float64{
+		.10, -.10,
+		.01, .01, .01, .01, .01, .01, .01, .01, .01, .01, .01, .01,
+	})
+
+	nan := math.NaN()
+	want := []CalendarYear{
+		{
+			Year:    1999,
+			Months:  [12]float64{nan, nan, nan, nan, nan, nan, nan, nan, nan, nan, .10, -.10},
+			Returns: 1.1*0.9 - 1,
+			Partial: true,
+		},
+		{
+			Year:    2000,
+			Months:  [12]float64{.01, .01, .01, .01, .01, .01, .01, .01, .01, .01, .01, .01},
+			Returns: 0.12682503,
+		},
+	}
+	if diff := cmp.Diff(want, h.Calendar(), cmpopts.EquateApprox(0, 1e-8), cmpopts.EquateNaNs()); diff != "" {
+		t.Errorf("Calendar() differs (-want/+got):\n%s", diff)
+	}
+}
+
+func TestDrawdowns(t *testing.T) {
+	month := func(m time.Month) time.Time {
+		return time.Date(2000, m, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	h := newTestData("", month(time.January), []float64{
+		-.10, // Jan: 0.9
+		.25,  // Feb: 1.125, recovered
+		-.20, // Mar: 0.9
+		-.50, // Apr: 0.45
+		.10,  // May: 0.495, not recovered
+	})
+
+	want := []Drawdown{
+		{
+			Peak:   month(time.February),
+			Trough: month(time.April),
+			Depth:  60,
+			Months: 3,
+		},
+		{
+			Peak:     time.Date(1999, time.December, 31, 0, 0, 0, 0, time.UTC),
+			Trough:   month(time.January),
+			Recovery: month(time.February),
+			Depth:    10,
+			Months:   1,
+		},
+	}
+
+	got := h.Drawdowns()
+	if diff := cmp.Diff(want, got, cmpopts.EquateApprox(0, 1e-8)); diff != "" {
+		t.Errorf("Drawdowns() differs (-want/+got):\n%s", diff)
+	}
+	if got[0].Recovered() || !got[1].Recovered() {
+		t.Errorf("Recovered() = %v, %v, want false, true", got[0].Recovered(), got[1].Recovered())
+	}
+}
@@ -0,0 +1,68 @@
+package timeseries
+
+import (
+	"math"
+)
+
+// Relative holds statistics of a series relative to a benchmark. The risk free
+// rate is assumed to be zero, like in SharpeRatio.
+type Relative struct {
+	Beta float64
+	// Alpha is Jensen's alpha, annualized and in percent.
+	Alpha float64
+	// TrackingError is the annualized volatility of the active returns,
+	// i.e. the difference between the series' and the benchmark's returns,
+	// in percent.
+	TrackingError float64
+	// InformationRatio is the annualized active return divided by the
+	// tracking error.
+	InformationRatio float64
+	// UpCapture and DownCapture are the series' annualized returns in months
+	// in which the benchmark rose or fell, respectively, relative to the
+	// benchmark's annualized returns in those months, in percent.
+	UpCapture   float64
+	DownCapture float64
+}
+
+// RelativeTo calculates statistics of h relative to the benchmark. Only
+// months present in both series are considered.
+func (h Data) RelativeTo(benchmark Data) Relative {
+	x, y := align(h, benchmark)
+
+	var ret Relative
+
+	ret.Beta = beta(x, y)
+	ret.Alpha = 100 * 12 * (x.average() - ret.Beta*y.average())
+
+	active := Data{Name: x.Name}
+	for i := range x.Data {
+		active.Data = append(active.Data, Datum{
+			Date:  x.Data[i].Date,
+			Value: x.Data[i].Value - y.Data[i].Value,
+		})
+	}
+	ret.TrackingError = active.Volatility()
+	ret.InformationRatio = 100 * 12 * active.average() / ret.TrackingError
+
+	var upX, upY, downX, downY Data
+	for i := range x.Data {
+		if y.Data[i].Value >= 0 {
+			upX.Data = append(upX.Data, x.Data[i])
+			upY.Data = append(upY.Data, y.Data[i])
+		} else {
+			downX.Data = append(downX.Data, x.Data[i])
+			downY.Data = append(downY.Data, y.Data[i])
+		}
+	}
+	ret.UpCapture = capture(upX, upY)
+	ret.DownCapture = capture(downX, downY)
+
+	return ret
+}
+
+func capture(x, benchmark Data) float64 {
+	if len(benchmark.Data) == 0 {
+		return math.NaN()
+	}
+	return 100 * x.Returns() / benchmark.Returns()
+}
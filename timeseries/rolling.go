@@ -0,0 +1,66 @@
+package timeseries
+
+import "fmt"
+
+// Rolling applies f to every window of the given number of consecutive months
+// and returns the results as a new series. Each value is dated with the last
+// month of its window.
+func (h Data) Rolling(window int, name string, f func(Data) float64) Data {
+	ret := Data{
+		Name: fmt.Sprintf("%s %s (%dm)", h.Name, name, window),
+	}
+
+	for i := window; i <= len(h.Data); i++ {
+		ret.Data = append(ret.Data, Datum{
+			Date:  h.Data[i-1].Date,
+			Value: f(Data{Name: h.Name, Data: h.Data[i-window : i]}),
+		})
+	}
+
+	return ret
+}
+
+// RollingReturns returns the annualized returns of every window.
+func (h Data) RollingReturns(window int) Data {
+	return h.Rolling(window, "returns", Data.Returns)
+}
+
+// RollingVolatility returns the annualized volatility of every window.
+func (h Data) RollingVolatility(window int) Data {
+	return h.Rolling(window, "volatility", Data.Volatility)
+}
+
+// RollingSharpeRatio returns the Sharpe ratio of every window.
+func (h Data) RollingSharpeRatio(window int) Data {
+	return h.Rolling(window, "sharpe ratio", Data.SharpeRatio)
+}
+
+// RollingCorrelation returns the correlation of a and b for every window.
+// Only months present in both series are considered.
+func RollingCorrelation(a, b Data, window int) Data {
+	return rolling2(a, b, window, "correlation", correlation)
+}
+
+// RollingBeta returns the beta of a relative to the benchmark for every
+// window. Only months present in both series are considered.
+func RollingBeta(a, benchmark Data, window int) Data {
+	return rolling2(a, benchmark, window, "beta", beta)
+}
+
+func rolling2(a, b Data, window int, name string, f func(x, y Data) float64) Data {
+	x, y := align(a, b)
+
+	ret := Data{
+		Name: fmt.Sprintf("%s/%s %s (%dm)", a.Name, b.Name, name, window),
+	}
+
+	for i := window; i <= len(x.Data); i++ {
+		ret.Data = append(ret.Data, Datum{
+			Date: x.Data[i-1].Date,
+			Value: f(Data{Name: x.Name, Data: x.Data[i-window : i]},
+				Data{Name: y.Name, Data: y.Data[i-window : i]}),
+		})
+	}
+
+	return ret
+}
@@ -0,0 +1,44 @@
+package timeseries
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestRolling(t *testing.T) {
+	values := []float64{.01, -.02, .03, .01, -.01, .02}
+	var doubled []float64
+	for _, v := range values {
+		doubled = append(doubled, 2*v)
+	}
+
+	a := newTestData("a", testStart, values)
+	b := newTestData("b", testStart, doubled)
+
+	returns := a.RollingReturns(3)
+	if got, want := len(returns.Data), 4; got != want {
+		t.Fatalf("len(RollingReturns(3)) = %d, want %d", got, want)
+	}
+	if got, want := returns.Data[0].Date, a.Data[2].Date; !got.Equal(want) {
+		t.Errorf("RollingReturns(3)[0].Date = %v, want %v", got, want)
+	}
+	for i, d := range returns.Data {
+		want := Data{Data: a.Data[i : i+3]}.Returns()
+		if !cmp.Equal(d.Value, want, cmpopts.EquateApprox(0, 0.00001)) {
+			t.Errorf("RollingReturns(3)[%d] = %.5f, want %.5f", i, d.Value, want)
+		}
+	}
+
+	for i, d := range RollingBeta(b, a, 3).Data {
+		if !cmp.Equal(d.Value, 2.0, cmpopts.EquateApprox(0, 0.00001)) {
+			t.Errorf("RollingBeta(3)[%d] = %.5f, want 2", i, d.Value)
+		}
+	}
+	for i, d := range RollingCorrelation(a, b, 3).Data {
+		if !cmp.Equal(d.Value, 1.0, cmpopts.EquateApprox(0, 0.00001)) {
+			t.Errorf("RollingCorrelation(3)[%d] = %.5f, want 1", i, d.Value)
+		}
+	}
+}
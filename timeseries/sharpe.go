@@ -0,0 +1,112 @@
+package timeseries
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// sharpeRatioStdErr returns the standard error of the MonthlySharpeRatio of h,
+// taking the skewness and kurtosis of its returns into account (Mertens,
+// 2002). It returns NaN if h is too short.
+func (h Data) sharpeRatioStdErr() float64 {
+	n := len(h.Data)
+	sr := h.MonthlySharpeRatio()
+	v := 1 - h.Skewness()*sr + (h.Kurtosis()+2)/4*sr*sr
+	if n < 2 || v <= 0 {
+		return math.NaN()
+	}
+	return math.Sqrt(v / float64(n-1))
+}
+
+// SharpeRatioInterval returns the confidence interval of the
+// MonthlySharpeRatio of h at level, e.g. 0.95.
+func (h Data) SharpeRatioInterval(level float64) (lo, hi float64) {
+	sr, se := h.MonthlySharpeRatio(), h.sharpeRatioStdErr()
+	z := normQuantile(.5 + level/2)
+	return sr - z*se, sr + z*se
+}
+
+// SharpeTest is the result of testing whether two series have the same
+// Sharpe ratio.
+type SharpeTest struct {
+	// Difference is the first series' MonthlySharpeRatio minus the
+	// second's.
+	Difference float64
+	// Z is the test statistic of the Jobson-Korkie test; it is not set by
+	// the bootstrap test.
+	Z float64
+	// PValue is the two-sided probability of a difference at least this
+	// large if the Sharpe ratios are equal.
+	PValue float64
+	// Lower and Upper bound the confidence interval of Difference.
+	Lower float64
+	Upper float64
+}
+
+// JobsonKorkie tests whether a and b have the same Sharpe ratio, using the
+// Jobson-Korkie test with Memmel's correction. It assumes normally
+// distributed returns that are independent over time, but accounts for the
+// correlation of a and b. Only months present in both series are considered.
+func JobsonKorkie(a, b Data, level float64) SharpeTest {
+	x, y := align(a, b)
+	n := float64(len(x.Data))
+	srX, srY := x.MonthlySharpeRatio(), y.MonthlySharpeRatio()
+	rho := correlation(x, y)
+
+	theta := (2 - 2*rho + (srX*srX+srY*srY-2*srX*srY*rho*rho)/2) / n
+	se := math.Sqrt(theta)
+
+	ret := SharpeTest{Difference: srX - srY, PValue: 1}
+	if se > 0 {
+		ret.Z = ret.Difference / se
+		ret.PValue = 2 * (1 - normCDF(math.Abs(ret.Z)))
+	}
+	z := normQuantile(.5 + level/2)
+	ret.Lower, ret.Upper = ret.Difference-z*se, ret.Difference+z*se
+	return ret
+}
+
+// BootstrapSharpeTest tests whether a and b have the same Sharpe ratio by
+// resampling the months of both series together with the MonteCarlo
+// bootstrap, which keeps runs of consecutive months and thus
+// autocorrelation. The p-value is the share of resampled differences that
+// deviate from the observed one by at least its size. Only months present
+// in both series are considered.
+func BootstrapSharpeTest(a, b Data, iterations int, level float64) (SharpeTest, error) {
+	if iterations <= 0 {
+		return SharpeTest{}, errors.New("need at least one iteration")
+	}
+
+	x, y := align(a, b)
+	if len(x.Data) < 3 {
+		return SharpeTest{}, errors.New("need at least three common months")
+	}
+	hist := map[string]Data{"a": x, "b": y}
+
+	ret := SharpeTest{Difference: x.MonthlySharpeRatio() - y.MonthlySharpeRatio()}
+
+	var diffs []float64
+	extreme := 0
+	for i := 0; i < iterations; i++ {
+		sample, err := Generate([]string{"a", "b"}, &MonteCarlo{
+			Data:   hist,
+			Months: len(x.Data),
+		})
+		if err != nil {
+			return SharpeTest{}, err
+		}
+
+		d := sample["a"].MonthlySharpeRatio() - sample["b"].MonthlySharpeRatio()
+		if math.Abs(d-ret.Difference) >= math.Abs(ret.Difference) {
+			extreme++
+		}
+		diffs = append(diffs, d)
+	}
+	sort.Float64s(diffs)
+
+	ret.PValue = float64(extreme+1) / float64(iterations+1)
+	ret.Lower = diffs[int(float64(iterations-1)*(1-level)/2)]
+	ret.Upper = diffs[int(math.Ceil(float64(iterations-1)*(1+level)/2))]
+	return ret, nil
+}
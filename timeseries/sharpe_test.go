@@ -0,0 +1,107 @@
+package timeseries
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// normalReturns returns n normally distributed monthly returns.
+func normalReturns(rng *rand.Rand, n int, mean, sd float64) []float64 {
+	var ret []float64
+	for i := 0; i < n; i++ {
+		ret = append(ret, mean+sd*rng.NormFloat64())
+	}
+	return ret
+}
+
+func TestSharpeRatioInterval(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	short := newTestData("short", testStart, normalReturns(rng, 60, .01, .04))
+	long := newTestData("long", testStart, normalReturns(rng, 600, .01, .04))
+
+	for _, h := range []Data{short, long} {
+		lo, hi := h.SharpeRatioInterval(.95)
+		if sr := h.MonthlySharpeRatio(); lo >= sr || hi <= sr {
+			t.Errorf("%s: SharpeRatioInterval() = [%.3f, %.3f], want an interval around %.3f", h.Name, lo, hi, sr)
+		}
+
+		// Without skewness and kurtosis, the standard error is
+		// sqrt((1 + SR²/2) / (n-1)).
+		sr := h.MonthlySharpeRatio()
+		want := 2 * 1.959964 * math.Sqrt((1+sr*sr/2)/float64(len(h.Data)-1))
+		if got := hi - lo; math.Abs(got-want) > .2*want {
+			t.Errorf("%s: width of SharpeRatioInterval() = %.3f, want about %.3f", h.Name, got, want)
+		}
+	}
+
+	shortLo, shortHi := short.SharpeRatioInterval(.95)
+	longLo, longHi := long.SharpeRatioInterval(.95)
+	if longHi-longLo >= shortHi-shortLo {
+		t.Errorf("SharpeRatioInterval() of 600 months is wider than of 60 months")
+	}
+}
+
+func TestJobsonKorkie(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	a := newTestData("a", testStart, normalReturns(rng, 600, .01, .04))
+
+	same := JobsonKorkie(a, a, .95)
+	if same.Difference != 0 || same.Z != 0 || same.PValue != 1 {
+		t.Errorf("JobsonKorkie(a, a) = %+v, want no difference", same)
+	}
+
+	// b is a with noise and a lower mean, i.e. correlated with a but with
+	// a clearly lower Sharpe ratio.
+	b := Data{Name: "b"}
+	for _, d := range a.Data {
+		b.Data = append(b.Data, Datum{Date: d.Date, Value: d.Value - .01 + .01*rng.NormFloat64()})
+	}
+	got := JobsonKorkie(a, b, .95)
+	if got.Difference <= 0 || got.PValue > .01 || got.Lower <= 0 {
+		t.Errorf("JobsonKorkie(a, b) = %+v, want a significant positive difference", got)
+	}
+	if rev := JobsonKorkie(b, a, .95); rev.Z != -got.Z || rev.PValue != got.PValue {
+		t.Errorf("JobsonKorkie(b, a) = %+v, want the negation of %+v", rev, got)
+	}
+
+	// c is independent of a with the same distribution.
+	c := newTestData("c", testStart, normalReturns(rng, 600, .01, .04))
+	if got := JobsonKorkie(a, c, .95); got.PValue < .05 || got.Lower >= got.Difference || got.Upper <= got.Difference {
+		t.Errorf("JobsonKorkie(a, c) = %+v, want an insignificant difference", got)
+	}
+}
+
+func TestBootstrapSharpeTest(t *testing.T) {
+	rand.Seed(1)
+	rng := rand.New(rand.NewSource(1))
+	a := newTestData("a", testStart, normalReturns(rng, 360, .01, .04))
+	b := Data{Name: "b"}
+	for _, d := range a.Data {
+		b.Data = append(b.Data, Datum{Date: d.Date, Value: d.Value - .01 + .01*rng.NormFloat64()})
+	}
+	c := newTestData("c", testStart, normalReturns(rng, 360, .01, .04))
+
+	got, err := BootstrapSharpeTest(a, b, 500, .95)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := a.MonthlySharpeRatio() - b.MonthlySharpeRatio(); got.Difference != want {
+		t.Errorf("BootstrapSharpeTest(a, b).Difference = %g, want %g", got.Difference, want)
+	}
+	if got.PValue > .01 || got.Lower <= 0 || got.Upper <= got.Lower {
+		t.Errorf("BootstrapSharpeTest(a, b) = %+v, want a significant positive difference", got)
+	}
+
+	got, err = BootstrapSharpeTest(a, c, 500, .95)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.PValue < .05 || got.Lower >= 0 || got.Upper <= 0 {
+		t.Errorf("BootstrapSharpeTest(a, c) = %+v, want an insignificant difference", got)
+	}
+
+	if _, err := BootstrapSharpeTest(a, c, 0, .95); err == nil {
+		t.Error("BootstrapSharpeTest(0 iterations) = nil, want error")
+	}
+}
@@ -0,0 +1,174 @@
+package timeseries
+
+import (
+	"math"
+)
+
+// MaxDrawdown returns the largest peak-to-trough decline of the cumulative
+// value in percent. A series that never declines has a drawdown of zero.
+func (h Data) MaxDrawdown() float64 {
+	var (
+		value, peak float64 = 1, 1
+		ret         float64
+	)
+
+	for _, d := range h.Data {
+		value *= 1 + d.Value
+		if value > peak {
+			peak = value
+		}
+		if dd := 1 - value/peak; dd > ret {
+			ret = dd
+		}
+	}
+
+	return 100 * ret
+}
+
+// Underwater returns the decline of the cumulative value from its previous
+// peak for each month, as a fraction. The values are zero at new peaks and
+// negative otherwise.
+func (h Data) Underwater() Data {
+	ret := Data{
+		Name: h.Name,
+	}
+
+	var value, peak float64 = 1, 1
+	for _, d := range h.Data {
+		value *= 1 + d.Value
+		if value > peak {
+			peak = value
+		}
+		ret.Data = append(ret.Data, Datum{
+			Date:  d.Date,
+			Value: value/peak - 1,
+		})
+	}
+
+	return ret
+}
+
+// Skewness returns the skewness of the monthly returns. Negative values
+// indicate a longer left tail, i.e. large losses are more likely than large
+// gains of the same size.
+func (h Data) Skewness() float64 {
+	avg, sd := h.average(), h.stdDev()
+	if sd == 0 {
+		return 0
+	}
+
+	var ret float64
+	for _, d := range h.Data {
+		z := (d.Value - avg) / sd
+		ret += z * z * z
+	}
+
+	return ret / float64(len(h.Data))
+}
+
+// Kurtosis returns the excess kurtosis of the monthly returns. The normal
+// distribution has an excess kurtosis of zero, positive values indicate "fat
+// tails".
+func (h Data) Kurtosis() float64 {
+	avg, sd := h.average(), h.stdDev()
+	if sd == 0 {
+		return 0
+	}
+
+	var ret float64
+	for _, d := range h.Data {
+		z := (d.Value - avg) / sd
+		ret += z * z * z * z
+	}
+
+	return ret/float64(len(h.Data)) - 3
+}
+
+// Autocorrelation returns the correlation of the monthly returns with the
+// returns lag months earlier.
+func (h Data) Autocorrelation(lag int) float64 {
+	if lag <= 0 || lag >= len(h.Data) {
+		return math.NaN()
+	}
+
+	avg, variance := h.average(), h.variance()
+	if variance == 0 {
+		return 0
+	}
+
+	var ret float64
+	for i := lag; i < len(h.Data); i++ {
+		ret += (h.Data[i].Value - avg) * (h.Data[i-lag].Value - avg)
+	}
+
+	return ret / float64(len(h.Data)) / variance
+}
+
+// Covariance returns the covariance of the monthly returns of a and b. Only
+// months present in both series are considered.
+func Covariance(a, b Data) float64 {
+	return covariance(align(a, b))
+}
+
+// Correlation returns the Pearson correlation coefficient of the monthly
+// returns of a and b. Only months present in both series are considered.
+func Correlation(a, b Data) float64 {
+	return correlation(align(a, b))
+}
+
+// Beta returns the sensitivity of a's monthly returns to the benchmark's
+// monthly returns. Only months present in both series are considered.
+func Beta(a, benchmark Data) float64 {
+	return beta(align(a, benchmark))
+}
+
+// align returns the parts of a and b for months present in both series.
+func align(a, b Data) (Data, Data) {
+	months := make(map[int]Datum, len(b.Data))
+	for _, d := range b.Data {
+		months[monthIndex(d.Date)] = d
+	}
+
+	x, y := Data{Name: a.Name}, Data{Name: b.Name}
+	for _, d := range a.Data {
+		if v, ok := months[monthIndex(d.Date)]; ok {
+			x.Data = append(x.Data, d)
+			y.Data = append(y.Data, v)
+		}
+	}
+
+	return x, y
+}
+
+func covariance(x, y Data) float64 {
+	if len(x.Data) == 0 {
+		return math.NaN()
+	}
+
+	avgX, avgY := x.average(), y.average()
+
+	var ret float64
+	for i := range x.Data {
+		ret += (x.Data[i].Value - avgX) * (y.Data[i].Value - avgY)
+	}
+
+	return ret / float64(len(x.Data))
+}
+
+func correlation(x, y Data) float64 {
+	sdX, sdY := x.stdDev(), y.stdDev()
+	if sdX == 0 || sdY == 0 {
+		return 0
+	}
+
+	return covariance(x, y) / (sdX * sdY)
+}
+
+func beta(x, benchmark Data) float64 {
+	v := benchmark.variance()
+	if v == 0 {
+		return math.NaN()
+	}
+
+	return covariance(x, benchmark) / v
+}
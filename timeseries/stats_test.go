@@ -0,0 +1,141 @@
+package timeseries
+
+import (
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func TestMaxDrawdown(t *testing.T) {
+	cases := []struct {
+		name   string
+		values []float64
+		want   float64
+	}{
+		{
+			name:   "no decline",
+			values: []float64{.01, 0, .02},
+			want:   0,
+		},
+		{
+			name:   "recovered",
+			values: []float64{.1, -.5, 1, .1},
+			want:   50,
+		},
+		{
+			name:   "consecutive",
+			values: []float64{.1, -.5, -.5, .5, -.1},
+			want:   75,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := newTestData(tc.name, testStart, tc.values).MaxDrawdown()
+			if !cmp.Equal(got, tc.want, cmpopts.EquateApprox(0, 0.00001)) {
+				t.Errorf("MaxDrawdown(%v) = %.5f, want %.5f", tc.values, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestMoments(t *testing.T) {
+	cases := []struct {
+		name         string
+		values       []float64
+		wantSkew     float64
+		wantKurtosis float64
+		wantAC       float64
+	}{
+		{
+			name:         "alternate",
+			values:       []float64{.05, -.05, .05, -.05, .05, -.05},
+			wantSkew:     0,
+			wantKurtosis: -2,
+			wantAC:       -5.0 / 6.0,
+		},
+		{
+			name:         "outlier",
+			values:       []float64{0, 0, 0, .04},
+			wantSkew:     2 / math.Sqrt(3),
+			wantKurtosis: -2.0 / 3.0,
+			wantAC:       -1.0 / 12.0,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := newTestData(tc.name, testStart, tc.values)
+			if got := d.Skewness(); !cmp.Equal(got, tc.wantSkew, cmpopts.EquateApprox(0, 0.00001)) {
+				t.Errorf("Skewness(%v) = %.5f, want %.5f", tc.values, got, tc.wantSkew)
+			}
+			if got := d.Kurtosis(); !cmp.Equal(got, tc.wantKurtosis, cmpopts.EquateApprox(0, 0.00001)) {
+				t.Errorf("Kurtosis(%v) = %.5f, want %.5f", tc.values, got, tc.wantKurtosis)
+			}
+			if got := d.Autocorrelation(1); !cmp.Equal(got, tc.wantAC, cmpopts.EquateApprox(0, 0.00001)) {
+				t.Errorf("Autocorrelation(%v, 1) = %.5f, want %.5f", tc.values, got, tc.wantAC)
+			}
+		})
+	}
+}
+
+func TestRelativeTo(t *testing.T) {
+	benchmark := newTestData("benchmark", testStart, []float64{.02, -.01, .03, -.02, .01, .02})
+
+	doubled := Data{Name: "doubled"}
+	for _, d := range benchmark.Data {
+		doubled.Data = append(doubled.Data, Datum{Date: d.Date, Value: 2 * d.Value})
+	}
+
+	got := doubled.RelativeTo(benchmark)
+	want := Relative{
+		Beta:             2,
+		Alpha:            0,
+		TrackingError:    benchmark.Volatility(),
+		InformationRatio: 100 * 12 * benchmark.average() / benchmark.Volatility(),
+		UpCapture:        100 * newTestData("", testStart, []float64{.04, .06, .02, .04}).Returns() / newTestData("", testStart, []float64{.02, .03, .01, .02}).Returns(),
+		DownCapture:      100 * newTestData("", testStart, []float64{-.02, -.04}).Returns() / newTestData("", testStart, []float64{-.01, -.02}).Returns(),
+	}
+
+	if diff := cmp.Diff(want, got, cmpopts.EquateApprox(0, 0.00001)); diff != "" {
+		t.Errorf("RelativeTo(): result differs (-want/+got):\n%s", diff)
+	}
+}
+
+func TestDistributionPercentile(t *testing.T) {
+	var results []Data
+	for i := 1; i <= 100; i++ {
+		results = append(results, newTestData("", testStart, []float64{float64(i) / 1000}))
+	}
+
+	returns := NewDistribution(Metric{Value: func(d Data) float64 { return d.Data[0].Value }}, results)
+	if got, want := returns.Percentile(95), 0.006; !cmp.Equal(got, want, cmpopts.EquateApprox(0, 0.00001)) {
+		t.Errorf("Percentile(95) = %g, want %g", got, want)
+	}
+
+	risk := returns
+	risk.LowerIsBetter = true
+	if got, want := risk.Percentile(95), 0.095; !cmp.Equal(got, want, cmpopts.EquateApprox(0, 0.00001)) {
+		t.Errorf("Percentile(95) = %g, want %g (lower is better)", got, want)
+	}
+}
+
+func TestDistributionConfidenceInterval(t *testing.T) {
+	var results []Data
+	for i := 0; i < 10000; i++ {
+		results = append(results, newTestData("", testStart, []float64{float64(i) / 10000}))
+	}
+	d := NewDistribution(Metric{Value: func(d Data) float64 { return d.Data[0].Value }}, results)
+
+	// n = 10000, q = 0.5: the standard deviation of the rank is 50, i.e. the
+	// 95% interval spans ±1.96*50 ranks.
+	lo, hi := d.ConfidenceInterval(50, 0.95)
+	if want := 0.4902; !cmp.Equal(lo, want, cmpopts.EquateApprox(0, 0.0001)) {
+		t.Errorf("ConfidenceInterval(50, 0.95) lower bound = %g, want %g", lo, want)
+	}
+	if want := 0.5098; !cmp.Equal(hi, want, cmpopts.EquateApprox(0, 0.0001)) {
+		t.Errorf("ConfidenceInterval(50, 0.95) upper bound = %g, want %g", hi, want)
+	}
+}
@@ -2,8 +2,10 @@ package timeseries
 
 import (
 	"encoding/csv"
+	"fmt"
 	"io"
 	"math"
+	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -14,6 +16,9 @@ import (
 type Datum struct {
 	Date  time.Time
 	Value float64
+	// Synthetic is true if the value was not observed but backfilled from
+	// other series, see Splice and Regress.
+	Synthetic bool
 }
 
 // Data holds timeseries data.
@@ -37,15 +42,26 @@ func (h Data) average() float64 {
 }
 
 func (h Data) variance() float64 {
-	var ret float64
-	avg := h.average()
-
-	for _, d := range h.Data {
-		dev := d.Value - avg
-		ret += dev * dev
+	values := make([]float64, len(h.Data))
+	for i, d := range h.Data {
+		values[i] = d.Value
 	}
+	return Variance(values)
+}
 
-	return ret / float64(len(h.Data))
+// Variance returns the population variance of values, i.e. the mean squared
+// deviation from their average. It is zero for a single value and NaN for
+// none.
+func Variance(values []float64) float64 {
+	var sum, sq float64
+	for _, v := range values {
+		sum += v
+	}
+	avg := sum / float64(len(values))
+	for _, v := range values {
+		sq += (v - avg) * (v - avg)
+	}
+	return sq / float64(len(values))
 }
 
 func (h Data) stdDev() float64 {
@@ -70,6 +86,16 @@ func (h Data) Returns() float64 {
 	return 100 * (annualized - 1)
 }
 
+// Growth returns the factor by which an investment grows over the entire
+// series, e.g. 1.5 for a total return of 50%.
+func (h Data) Growth() float64 {
+	ret := 1.0
+	for _, d := range h.Data {
+		ret *= 1 + d.Value
+	}
+	return ret
+}
+
 func (h Data) SharpeRatio() float64 {
 	return h.Returns() / h.Volatility()
 }
@@ -77,8 +103,8 @@ func (h Data) SharpeRatio() float64 {
 func (h Data) Min() float64 {
 	var ret float64
 
-	for _, d := range h.Data {
-		if ret == 0 || ret > d.Value {
+	for i, d := range h.Data {
+		if i == 0 || ret > d.Value {
 			ret = d.Value
 		}
 	}
@@ -89,8 +115,8 @@ func (h Data) Min() float64 {
 func (h Data) Max() float64 {
 	var ret float64
 
-	for _, d := range h.Data {
-		if ret == 0 || ret < d.Value {
+	for i, d := range h.Data {
+		if i == 0 || ret < d.Value {
 			ret = d.Value
 		}
 	}
@@ -106,7 +132,42 @@ func (h Data) Last() float64 {
 	return h.Data[len(h.Data)-1].Value
 }
 
-// Load loads timeseries data from an io.Reader.
+// LoadFile loads timeseries data from the file at path. With validate, the
+// file is checked with DefaultValidateOptions first: issues are written to
+// os.Stderr, and errors cause LoadFile to fail.
+func LoadFile(path string, validate bool) (map[string]Data, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if validate {
+		report, err := Validate(f, DefaultValidateOptions)
+		if err != nil {
+			return nil, fmt.Errorf("Validate(%q): %w", path, err)
+		}
+		if len(report.Issues) != 0 {
+			fmt.Fprint(os.Stderr, report)
+		}
+		if !report.OK() {
+			return nil, fmt.Errorf("%s: validation failed", path)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+
+	hist, err := Load(f)
+	if err != nil {
+		return nil, fmt.Errorf("Load(%q): %w", path, err)
+	}
+	return hist, nil
+}
+
+// Load loads timeseries data from an io.Reader. Empty cells at the beginning
+// or the end of a column are skipped, so that series may cover different
+// periods. Empty cells between values are an error.
 func Load(r io.Reader) (map[string]Data, error) {
 	data, err := csv.NewReader(r).ReadAll()
 	if err != nil {
@@ -119,23 +180,32 @@ func Load(r io.Reader) (map[string]Data, error) {
 	for i := 1; i < len(header); i++ {
 		ret[i-1].Name = header[i]
 	}
+	// ended is true for columns with an empty cell after their first value.
+	ended := make([]bool, len(header)-1)
 
 	for row := 1; row < len(data); row++ {
-		t, err := time.Parse("2006-01-02", data[row][0])
+		t, err := parseDate(data[row][0])
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("row %d, column %q: %w", row+1, header[0], err)
 		}
 
 		for col := 1; col < len(data[row]); col++ {
-			// accept comma as decimal separator, too.
-			v, err := strconv.ParseFloat(strings.Replace(data[row][col], ",", ".", -1), 64)
+			if strings.TrimSpace(data[row][col]) == "" {
+				ended[col-1] = len(ret[col-1].Data) != 0
+				continue
+			}
+			if ended[col-1] {
+				return nil, fmt.Errorf("row %d, column %q: value after missing values", row+1, header[col])
+			}
+
+			v, err := parseValue(data[row][col])
 			if err != nil {
-				return nil, err
+				return nil, fmt.Errorf("row %d, column %q: %w", row+1, header[col], err)
 			}
 
 			ret[col-1].Data = append(ret[col-1].Data, Datum{
 				Date:  t,
-				Value: v / 100,
+				Value: v,
 			})
 		}
 	}
@@ -148,6 +218,32 @@ func Load(r io.Reader) (map[string]Data, error) {
 	return m, nil
 }
 
+// Synthetic returns the number of backfilled values in h.
+func (h Data) Synthetic() int {
+	var n int
+	for _, d := range h.Data {
+		if d.Synthetic {
+			n++
+		}
+	}
+	return n
+}
+
+func parseDate(s string) (time.Time, error) {
+	return time.Parse("2006-01-02", s)
+}
+
+// parseValue parses a monthly return given in percent and returns it as a
+// fraction.
+func parseValue(s string) (float64, error) {
+	// accept comma as decimal separator, too.
+	v, err := strconv.ParseFloat(strings.Replace(s, ",", ".", -1), 64)
+	if err != nil {
+		return 0, err
+	}
+	return v / 100, nil
+}
+
 type QuoteProvider interface {
 	Next() (time.Time, bool)
 	RelativeValue(string) (float64, error)
@@ -2,6 +2,8 @@ package timeseries
 
 import (
 	"math"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -20,15 +22,15 @@ func TestLoad(t *testing.T) {
 		"FONDS 0": Data{
 			Name: "FONDS 0",
 			Data: []Datum{
-				{time.Date(1999, time.January, 29, 0, 0, 0, 0, time.UTC), 0.05648},
-				{time.Date(1999, time.February, 26, 0, 0, 0, 0, time.UTC), 0.00686},
+				{Date: time.Date(1999, time.January, 29, 0, 0, 0, 0, time.UTC), Value: 0.05648},
+				{Date: time.Date(1999, time.February, 26, 0, 0, 0, 0, time.UTC), Value: 0.00686},
 			},
 		},
 		"FONDS 1": Data{
 			Name: "FONDS 1",
 			Data: []Datum{
-				{time.Date(1999, time.January, 29, 0, 0, 0, 0, time.UTC), 0.04161},
-				{time.Date(1999, time.February, 26, 0, 0, 0, 0, time.UTC), 0.02190},
+				{Date: time.Date(1999, time.January, 29, 0, 0, 0, 0, time.UTC), Value: 0.04161},
+				{Date: time.Date(1999, time.February, 26, 0, 0, 0, 0, time.UTC), Value: 0.02190},
 			},
 		},
 	}
@@ -43,6 +45,29 @@ func TestLoad(t *testing.T) {
 	}
 }
 
+func TestLoadShortSeries(t *testing.T) {
+	input := `Date,LONG,SHORT
+1999-01-29,1,
+1999-02-26,1,2
+1999-03-31,1,
+`
+	got, err := Load(strings.NewReader(input))
+	if err != nil {
+		t.Fatal("Load(): ", err)
+	}
+	if n := len(got["LONG"].Data); n != 3 {
+		t.Errorf("len(LONG) = %d, want 3", n)
+	}
+	want := []Datum{{Date: time.Date(1999, time.February, 26, 0, 0, 0, 0, time.UTC), Value: .02}}
+	if diff := cmp.Diff(want, got["SHORT"].Data); diff != "" {
+		t.Errorf("Load(): SHORT differs (-want/+got):\n%s", diff)
+	}
+
+	if _, err := Load(strings.NewReader("Date,HOLE\n1999-01-29,1\n1999-02-26,\n1999-03-31,1\n")); err == nil {
+		t.Error("Load() = nil, want error for a hole in a series")
+	}
+}
+
 func TestReturns(t *testing.T) {
 	cases := []struct {
 		name   string
@@ -73,7 +98,7 @@ func TestReturns(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			got := newTestData(tc.name, tc.values).Returns()
+			got := newTestData(tc.name, testStart, tc.values).Returns()
 			if !cmp.Equal(got, tc.want, cmpopts.EquateApprox(0, 0.00001)) {
 				t.Errorf("Returns(%v) = %.5f, want %.5f", tc.values, got, tc.want)
 			}
@@ -121,7 +146,7 @@ func TestVolatility(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			ts := newTestData(tc.name, tc.values)
+			ts := newTestData(tc.name, testStart, tc.values)
 
 			if got := ts.average(); !cmp.Equal(got, tc.wantAvg, cmpopts.EquateApprox(0, 0.00001)) {
 				t.Errorf("average(%v) = %.5f, want %.5f", tc.values, got, tc.wantAvg)
@@ -136,19 +161,55 @@ func TestVolatility(t *testing.T) {
 	}
 }
 
-func newTestData(name string, values []float64) Data {
-	tm := time.Date(1999, time.January, 31, 0, 0, 0, 0, time.UTC)
+// testStart is the first month of test series whose dates do not matter.
+var testStart = time.Date(1999, time.January, 1, 0, 0, 0, 0, time.UTC)
 
+// newTestData returns a series with one value per calendar month, starting in
+// first.
+func newTestData(name string, first time.Time, values []float64) Data {
 	d := Data{
 		Name: name,
 	}
-	for _, v := range values {
+	for i, v := range values {
 		d.Data = append(d.Data, Datum{
-			Date:  tm,
+			Date:  first.AddDate(0, i, 0),
 			Value: v,
 		})
-		tm = tm.AddDate(0, 1, 0)
 	}
-
 	return d
 }
+
+func TestLoadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.csv")
+	input := `Date,FONDS 0
+1999-01-29,"5,648"
+1999-02-26,"0,686"
+1999-03-31,"1,000"
+`
+	if err := os.WriteFile(path, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hist, err := LoadFile(path, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(hist["FONDS 0"].Data), 3; got != want {
+		t.Errorf("len(LoadFile()) = %d, want %d", got, want)
+	}
+
+	if _, err := LoadFile(path, true); err != nil {
+		t.Errorf("LoadFile(validate) = %v", err)
+	}
+
+	// An additional row repeats the last month.
+	if err := os.WriteFile(path, []byte(input+"1999-03-31,\"1,000\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFile(path, true); err == nil {
+		t.Error("LoadFile(validate) = nil, want error")
+	}
+	if _, err := LoadFile(filepath.Join(t.TempDir(), "missing.csv"), false); err == nil {
+		t.Error("LoadFile(missing file) = nil, want error")
+	}
+}
@@ -0,0 +1,315 @@
+package timeseries
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ValidateOptions configures the checks performed by Validate.
+type ValidateOptions struct {
+	// MaxZScore flags values that are more than MaxZScore standard
+	// deviations away from the series' mean. Zero disables the check.
+	MaxZScore float64
+	// MaxAbsReturn flags monthly returns with an absolute value above this
+	// threshold, e.g. 0.5 for ±50%. Zero disables the check.
+	MaxAbsReturn float64
+}
+
+// DefaultValidateOptions are the options used by the commands' -validate flag.
+var DefaultValidateOptions = ValidateOptions{
+	MaxZScore:    5,
+	MaxAbsReturn: 0.5,
+}
+
+// Severity indicates whether an Issue prevents the data from being used.
+type Severity int
+
+const (
+	// Warning indicates suspicious data that can still be loaded.
+	Warning Severity = iota
+	// Error indicates data that Load rejects or that produces wrong results.
+	Error
+)
+
+func (s Severity) String() string {
+	if s == Error {
+		return "ERROR"
+	}
+	return "WARNING"
+}
+
+// Issue is a single problem found by Validate.
+type Issue struct {
+	Severity Severity
+	// Kind is a short, machine readable category, e.g. "gap" or "outlier".
+	Kind string
+	// Row is the 1-based row in the CSV file. The header is row 1.
+	Row int
+	// Column is the name of the affected series. Empty if the issue
+	// affects the entire row.
+	Column  string
+	Message string
+}
+
+func (i Issue) String() string {
+	loc := fmt.Sprintf("row %d", i.Row)
+	if i.Column != "" {
+		loc += fmt.Sprintf(", column %q", i.Column)
+	}
+	return fmt.Sprintf("%s %s: %s: %s", i.Severity, loc, i.Kind, i.Message)
+}
+
+// Coverage summarizes the usable values of one series.
+type Coverage struct {
+	Name        string
+	First, Last time.Time
+	Values      int
+	Missing     int
+}
+
+// ValidationReport is the result of Validate.
+type ValidationReport struct {
+	Rows     int
+	Issues   []Issue
+	Coverage []Coverage
+}
+
+// Errors returns the number of issues with severity Error.
+func (r *ValidationReport) Errors() int {
+	var n int
+	for _, i := range r.Issues {
+		if i.Severity == Error {
+			n++
+		}
+	}
+	return n
+}
+
+// OK returns true if the report contains no errors. Warnings are ignored.
+func (r *ValidationReport) OK() bool {
+	return r.Errors() == 0
+}
+
+func (r *ValidationReport) String() string {
+	var b strings.Builder
+	for _, i := range r.Issues {
+		fmt.Fprintln(&b, i)
+	}
+	fmt.Fprintf(&b, "%d rows, %d errors, %d warnings\n",
+		r.Rows, r.Errors(), len(r.Issues)-r.Errors())
+	return b.String()
+}
+
+// Validate reads CSV data in the format expected by Load and reports problems
+// with it: unparseable cells, holes within a series, ragged rows, duplicated
+// or unsorted dates, gaps between months and suspicious outliers. Unlike Load,
+// Validate does not stop at the first problem. The returned error is only
+// non-nil if r could not be read as CSV at all.
+func Validate(r io.Reader, opts ValidateOptions) (*ValidationReport, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	data, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &ValidationReport{
+		Rows: len(data),
+	}
+	if len(data) == 0 {
+		ret.Issues = append(ret.Issues, Issue{
+			Severity: Error,
+			Kind:     "empty",
+			Row:      1,
+			Message:  "no header found",
+		})
+		return ret, nil
+	}
+
+	header := data[0]
+	if len(header) < 2 {
+		ret.Issues = append(ret.Issues, Issue{
+			Severity: Error,
+			Kind:     "header",
+			Row:      1,
+			Message:  fmt.Sprintf("got %d columns, want a date column and at least one series", len(header)),
+		})
+		return ret, nil
+	}
+
+	type cell struct {
+		row   int
+		value float64
+	}
+	var (
+		cells    = make([][]cell, len(header)-1)
+		coverage = make([]Coverage, len(header)-1)
+		ended    = make([]bool, len(header)-1)
+		seen     = map[int]int{}
+		prev     time.Time
+		prevRow  int
+	)
+	for i := range coverage {
+		coverage[i].Name = header[i+1]
+	}
+
+	for i := 1; i < len(data); i++ {
+		row := i + 1
+		record := data[i]
+
+		if len(record) != len(header) {
+			ret.Issues = append(ret.Issues, Issue{
+				Severity: Error,
+				Kind:     "ragged",
+				Row:      row,
+				Message:  fmt.Sprintf("got %d fields, want %d", len(record), len(header)),
+			})
+		}
+
+		t, err := parseDate(record[0])
+		if err != nil {
+			ret.Issues = append(ret.Issues, Issue{
+				Severity: Error,
+				Kind:     "parse",
+				Row:      row,
+				Column:   header[0],
+				Message:  err.Error(),
+			})
+		} else {
+			month := monthIndex(t)
+			if r, ok := seen[month]; ok {
+				ret.Issues = append(ret.Issues, Issue{
+					Severity: Error,
+					Kind:     "duplicate",
+					Row:      row,
+					Message:  fmt.Sprintf("%s already occurred in row %d", t.Format("2006-01"), r),
+				})
+			} else {
+				seen[month] = row
+			}
+
+			if !prev.IsZero() {
+				switch d := month - monthIndex(prev); {
+				case d < 0:
+					ret.Issues = append(ret.Issues, Issue{
+						Severity: Error,
+						Kind:     "order",
+						Row:      row,
+						Message:  fmt.Sprintf("%s is before %s in row %d", t.Format("2006-01-02"), prev.Format("2006-01-02"), prevRow),
+					})
+				case d > 1:
+					ret.Issues = append(ret.Issues, Issue{
+						Severity: Error,
+						Kind:     "gap",
+						Row:      row,
+						Message:  fmt.Sprintf("%d months missing between %s and %s", d-1, prev.Format("2006-01"), t.Format("2006-01")),
+					})
+				}
+			}
+			// compare subsequent rows to the latest date seen so far, so
+			// that a single misplaced row is reported only once.
+			if prev.IsZero() || t.After(prev) {
+				prev, prevRow = t, row
+			}
+		}
+
+		for col := 1; col < len(header); col++ {
+			if col >= len(record) {
+				coverage[col-1].Missing++
+				continue
+			}
+
+			// Series may start late or end early, but must not have holes.
+			if strings.TrimSpace(record[col]) == "" {
+				coverage[col-1].Missing++
+				ended[col-1] = coverage[col-1].Values != 0
+				continue
+			}
+			if ended[col-1] {
+				ret.Issues = append(ret.Issues, Issue{
+					Severity: Error,
+					Kind:     "missing",
+					Row:      row,
+					Column:   header[col],
+					Message:  "value after missing values",
+				})
+				ended[col-1] = false
+			}
+
+			v, err := parseValue(record[col])
+			if err != nil {
+				coverage[col-1].Missing++
+				ret.Issues = append(ret.Issues, Issue{
+					Severity: Error,
+					Kind:     "parse",
+					Row:      row,
+					Column:   header[col],
+					Message:  err.Error(),
+				})
+				continue
+			}
+
+			cells[col-1] = append(cells[col-1], cell{row, v})
+			c := &coverage[col-1]
+			c.Values++
+			if !t.IsZero() {
+				if c.First.IsZero() || t.Before(c.First) {
+					c.First = t
+				}
+				if t.After(c.Last) {
+					c.Last = t
+				}
+			}
+		}
+	}
+
+	for col, cc := range cells {
+		var d Data
+		for _, c := range cc {
+			d.Data = append(d.Data, Datum{Value: c.value})
+		}
+		avg, sd := d.average(), d.stdDev()
+
+		for _, c := range cc {
+			if opts.MaxAbsReturn > 0 && math.Abs(c.value) > opts.MaxAbsReturn {
+				ret.Issues = append(ret.Issues, Issue{
+					Severity: Warning,
+					Kind:     "outlier",
+					Row:      c.row,
+					Column:   header[col+1],
+					Message:  fmt.Sprintf("monthly return of %.1f%% exceeds ±%.1f%%", 100*c.value, 100*opts.MaxAbsReturn),
+				})
+				continue
+			}
+			if opts.MaxZScore > 0 && sd > 0 {
+				if z := (c.value - avg) / sd; math.Abs(z) > opts.MaxZScore {
+					ret.Issues = append(ret.Issues, Issue{
+						Severity: Warning,
+						Kind:     "outlier",
+						Row:      c.row,
+						Column:   header[col+1],
+						Message:  fmt.Sprintf("monthly return of %.1f%% has a z-score of %.1f", 100*c.value, z),
+					})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(ret.Issues, func(i, j int) bool {
+		return ret.Issues[i].Row < ret.Issues[j].Row
+	})
+
+	ret.Coverage = coverage
+	return ret, nil
+}
+
+// monthIndex returns the number of months since year zero.
+func monthIndex(t time.Time) int {
+	return 12*t.Year() + int(t.Month()) - 1
+}
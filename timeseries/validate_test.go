@@ -0,0 +1,86 @@
+package timeseries
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestValidate(t *testing.T) {
+	input := `Date,FONDS 0,FONDS 1
+1999-01-29,"5,648","4,161"
+1999-02-26,"0,686","2,190"
+1999-02-28,"1,000","1,000"
+1999-05-31,"1,000","x"
+1999-04-30,"1,000"
+1999-06-30,"1,000","500"
+`
+
+	type issue struct {
+		Row    int
+		Column string
+		Kind   string
+	}
+	want := []issue{
+		{4, "", "duplicate"},
+		{5, "", "gap"},
+		{5, "FONDS 1", "parse"},
+		{6, "", "ragged"},
+		{6, "", "order"},
+		{7, "FONDS 1", "outlier"},
+	}
+
+	report, err := Validate(strings.NewReader(input), DefaultValidateOptions)
+	if err != nil {
+		t.Fatal("Validate(): ", err)
+	}
+
+	var got []issue
+	for _, i := range report.Issues {
+		got = append(got, issue{i.Row, i.Column, i.Kind})
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Validate(): issues differ (-want/+got):\n%s", diff)
+	}
+
+	if got, want := report.Errors(), 5; got != want {
+		t.Errorf("Validate().Errors() = %d, want %d", got, want)
+	}
+
+	wantCoverage := []struct{ values, missing int }{
+		{6, 0},
+		{4, 2},
+	}
+	for i, c := range report.Coverage {
+		if c.Values != wantCoverage[i].values || c.Missing != wantCoverage[i].missing {
+			t.Errorf("Coverage[%q] = (%d values, %d missing), want (%d values, %d missing)",
+				c.Name, c.Values, c.Missing, wantCoverage[i].values, wantCoverage[i].missing)
+		}
+	}
+}
+
+func TestValidateShortSeries(t *testing.T) {
+	input := `Date,LONG,SHORT,HOLE
+1999-01-29,1,,1
+1999-02-26,1,1,
+1999-03-31,1,1,1
+1999-04-30,1,,1
+`
+
+	report, err := Validate(strings.NewReader(input), DefaultValidateOptions)
+	if err != nil {
+		t.Fatal("Validate(): ", err)
+	}
+
+	got := report.Issues
+	want := []Issue{
+		{Severity: Error, Kind: "missing", Row: 4, Column: "HOLE", Message: "value after missing values"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Validate(): issues differ (-want/+got):\n%s", diff)
+	}
+	if got, want := report.Coverage[1].Missing, 2; got != want {
+		t.Errorf("Coverage[%q].Missing = %d, want %d", report.Coverage[1].Name, got, want)
+	}
+}
@@ -0,0 +1,45 @@
+package timeseries
+
+// Fold is one step of a walk-forward analysis. The months [TrainStart,
+// TrainEnd) are used for fitting and the following, unseen months [TrainEnd,
+// TestEnd) for evaluation. The values are indexes into aligned series.
+type Fold struct {
+	TrainStart, TrainEnd, TestEnd int
+}
+
+// WalkForward splits months into folds of train months for fitting, followed
+// by test months for evaluation. Each fold moves forward by test months, so
+// that the test windows cover the months after the first training window
+// once. Remaining months that do not fill a test window are dropped, so that
+// all folds are comparable. With rolling, the training windows have a
+// constant length; otherwise they expand from the first month.
+func WalkForward(months, train, test int, rolling bool) []Fold {
+	if train <= 0 || test <= 0 {
+		return nil
+	}
+
+	var ret []Fold
+	for end := train; end+test <= months; end += test {
+		f := Fold{
+			TrainEnd: end,
+			TestEnd:  end + test,
+		}
+		if rolling {
+			f.TrainStart = end - train
+		}
+		ret = append(ret, f)
+	}
+	return ret
+}
+
+// Slice returns the months [from, to) of the aligned series in hist.
+func Slice(hist map[string]Data, from, to int) map[string]Data {
+	ret := make(map[string]Data, len(hist))
+	for name, h := range hist {
+		ret[name] = Data{
+			Name: h.Name,
+			Data: h.Data[from:to],
+		}
+	}
+	return ret
+}
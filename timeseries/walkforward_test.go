@@ -0,0 +1,63 @@
+package timeseries
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestWalkForward(t *testing.T) {
+	cases := []struct {
+		name                string
+		months, train, test int
+		rolling             bool
+		want                []Fold
+	}{
+		{
+			name:   "expanding",
+			months: 10, train: 4, test: 3,
+			want: []Fold{{0, 4, 7}, {0, 7, 10}},
+		},
+		{
+			name:   "rolling",
+			months: 10, train: 4, test: 3,
+			rolling: true,
+			want:    []Fold{{0, 4, 7}, {3, 7, 10}},
+		},
+		{
+			name:   "short last fold",
+			months: 9, train: 4, test: 3,
+			rolling: true,
+			want:    []Fold{{0, 4, 7}},
+		},
+		{
+			name:   "too short",
+			months: 6, train: 4, test: 3,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := WalkForward(tc.months, tc.train, tc.test, tc.rolling)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("WalkForward() differs (-want/+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSlice(t *testing.T) {
+	hist := map[string]Data{
+		"a": newTestData("a", testStart, []float64{.01, .02, .03, .04}),
+		"b": newTestData("b", testStart, []float64{-.01, -.02, -.03, -.04}),
+	}
+
+	got := Slice(hist, 1, 3)
+	want := map[string]Data{
+		"a": {Name: "a", Data: hist["a"].Data[1:3]},
+		"b": {Name: "b", Data: hist["b"].Data[1:3]},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Slice() differs (-want/+got):\n%s", diff)
+	}
+}
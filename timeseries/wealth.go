@@ -0,0 +1,73 @@
+package timeseries
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Wealth returns the value of an investment of initial at the end of each
+// month.
+func (h Data) Wealth(initial float64) Data {
+	ret := Data{
+		Name: h.Name,
+	}
+
+	value := initial
+	for _, d := range h.Data {
+		value *= 1 + d.Value
+		ret.Data = append(ret.Data, Datum{
+			Date:  d.Date,
+			Value: value,
+		})
+	}
+
+	return ret
+}
+
+// WealthBands returns the percentiles of the wealth across all results for
+// each month, for an investment of initial. See ValueBands.
+func WealthBands(results []Data, initial float64, pcts []float64) []Data {
+	var wealth []Data
+	for _, res := range results {
+		wealth = append(wealth, res.Wealth(initial))
+	}
+	return ValueBands(wealth, pcts)
+}
+
+// ValueBands returns the percentiles of the values across all paths for each
+// month, e.g. of portfolio values that include cash flows. The percentiles
+// follow the convention of Distribution.Percentile, i.e. the P95 band is
+// exceeded by 95% of the paths. The returned slice has one element per
+// percentile; paths longer than the shortest one are truncated.
+func ValueBands(paths []Data, pcts []float64) []Data {
+	months := -1
+	for _, p := range paths {
+		if months == -1 || months > len(p.Data) {
+			months = len(p.Data)
+		}
+	}
+
+	ret := make([]Data, len(pcts))
+	for i, p := range pcts {
+		ret[i].Name = fmt.Sprintf("P%g", p)
+	}
+
+	for m := 0; m < months; m++ {
+		d := Distribution{
+			Metric: Growth,
+		}
+		for _, p := range paths {
+			d.Values = append(d.Values, p.Data[m].Value)
+		}
+		sort.Float64s(d.Values)
+
+		for i, p := range pcts {
+			ret[i].Data = append(ret[i].Data, Datum{
+				Date:  paths[0].Data[m].Date,
+				Value: d.Percentile(p),
+			})
+		}
+	}
+
+	return ret
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/octo/portfolio-mcmc/chart"
+	"github.com/octo/portfolio-mcmc/portfolio"
+	"github.com/octo/portfolio-mcmc/timeseries"
+)
+
+var (
+	input        = flag.String("input", "history.csv", "file containing historic returns")
+	maxZScore    = flag.Float64("max-z", timeseries.DefaultValidateOptions.MaxZScore, "flag values with a z-score above this threshold; 0 disables the check")
+	maxAbsReturn = flag.Float64("max-abs", 100*timeseries.DefaultValidateOptions.MaxAbsReturn, "flag monthly returns above this absolute value [%]; 0 disables the check")
+	chartFile    = flag.String("chart", "", "write a histogram of all monthly returns to this file; the format is determined by the extension (.svg or .png)")
+
+	portfolios []portfolio.Portfolio
+)
+
+func main() {
+	flag.Func("portfolio", `also check that the positions of the portfolios in this definition file, "file.json[:name]", exist in the input; may be repeated`, func(flagValue string) error {
+		ps, err := portfolio.LoadFile(flagValue)
+		if err != nil {
+			return err
+		}
+		portfolios = append(portfolios, ps...)
+		return nil
+	})
+	flag.Parse()
+
+	f, err := os.Open(*input)
+	if err != nil {
+		log.Fatalf("os.Open(%q): %v", *input, err)
+	}
+	defer f.Close()
+
+	report, err := timeseries.Validate(f, timeseries.ValidateOptions{
+		MaxZScore:    *maxZScore,
+		MaxAbsReturn: *maxAbsReturn / 100,
+	})
+	if err != nil {
+		log.Fatalf("timeseries.Validate(): %v", err)
+	}
+
+	fmt.Println("=== Issues ===")
+	fmt.Print(report)
+
+	fmt.Println()
+	fmt.Println("=== Coverage ===")
+	for _, c := range report.Coverage {
+		fmt.Printf("%-32s %s – %s (%d values, %d missing)\n",
+			c.Name, c.First.Format("2006-01"), c.Last.Format("2006-01"), c.Values, c.Missing)
+	}
+
+	ok := report.OK()
+	if len(portfolios) != 0 {
+		fmt.Println()
+		fmt.Println("=== Portfolios ===")
+		if !report.OK() {
+			fmt.Println("not checked: the input has errors")
+		} else if hist, err := load(f); err != nil {
+			log.Fatal(err)
+		} else {
+			for _, p := range portfolios {
+				name := p.Name
+				if name == "" {
+					name = "(unnamed)"
+				}
+				if err := p.Validate(hist); err != nil {
+					fmt.Printf("%s: %v\n", name, err)
+					ok = false
+					continue
+				}
+				fmt.Printf("%s: OK\n", name)
+			}
+		}
+	}
+
+	if *chartFile != "" {
+		// Load fails on errors that Validate reports; only chart valid files.
+		if hist, err := load(f); err != nil {
+			log.Printf("not writing chart: %v", err)
+		} else {
+			var values []float64
+			for _, h := range hist {
+				for _, d := range h.Data {
+					values = append(values, 100*d.Value)
+				}
+			}
+			if err := chart.Histogram("Monthly Returns (All Series)", values, 60, chart.Percent).WriteFile(*chartFile); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+func load(f *os.File) (map[string]timeseries.Data, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return timeseries.Load(f)
+}
@@ -0,0 +1,193 @@
+// Package withdrawal simulates withdrawals from a portfolio, e.g. during
+// retirement.
+package withdrawal
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/octo/portfolio-mcmc/timeseries"
+)
+
+// Rules for adjusting the withdrawal at the start of every year.
+const (
+	// ConstantReal withdraws the initial amount adjusted for inflation.
+	ConstantReal = "constant-real"
+	// ConstantPercentage withdraws the initial rate of the current value.
+	ConstantPercentage = "constant-percentage"
+	// Guardrails implements the decision rules by Guyton and Klinger: the
+	// withdrawal is adjusted for inflation, except after a year with
+	// negative returns if the current rate exceeds the initial rate. If the
+	// current rate rises more than 20% above the initial rate, the
+	// withdrawal is cut by 10%; if it falls more than 20% below, the
+	// withdrawal is raised by 10%.
+	Guardrails = "guardrails"
+)
+
+// CheckRule returns an error if rule is not one of the supported rules.
+func CheckRule(rule string) error {
+	switch rule {
+	case ConstantReal, ConstantPercentage, Guardrails:
+		return nil
+	}
+	return fmt.Errorf("unknown rule %q, want one of %q, %q and %q", rule, ConstantReal, ConstantPercentage, Guardrails)
+}
+
+// Guardrail parameters of the Guardrails rule.
+const (
+	guardrail  = 0.2
+	adjustment = 0.1
+)
+
+// Plan describes how much is withdrawn from a portfolio.
+type Plan struct {
+	// Wealth is the initial value of the portfolio.
+	Wealth float64
+	// Rate is the initial annual withdrawal in percent of Wealth.
+	Rate float64
+	// Rule is one of ConstantReal, ConstantPercentage and Guardrails.
+	Rule string
+	// Inflation is the annual inflation in percent.
+	Inflation float64
+	// Floor is the lowest acceptable annual withdrawal in real terms, in
+	// percent of the initial withdrawal. A path on which the withdrawal
+	// falls below the floor fails. Zero disables the floor.
+	Floor float64
+}
+
+// Result is the outcome of a plan on a single path. Amounts are in real
+// terms, i.e. in today's money.
+type Result struct {
+	// Survived is true if the portfolio was not depleted before the end
+	// of the path.
+	Survived bool
+	// Success is true if the portfolio survived and the withdrawals never
+	// fell below the floor.
+	Success bool
+	// Months is the number of months until the portfolio was depleted, or
+	// the length of the path if it survived.
+	Months int
+	// Terminal is the value at the end of the path.
+	Terminal float64
+	// MinWithdrawal is the lowest annual withdrawal.
+	MinWithdrawal float64
+	// Withdrawn is the sum of all withdrawals.
+	Withdrawn float64
+}
+
+// Simulate applies the plan to a path of monthly portfolio returns. One
+// twelfth of the annual withdrawal is taken at the start of every month.
+func (p Plan) Simulate(returns timeseries.Data) Result {
+	var (
+		value       = p.Wealth
+		initialRate = p.Rate / 100
+		annual      = initialRate * p.Wealth
+		inflation   = 1 + p.Inflation/100
+		yearGrowth  = 1.0
+		ret         = Result{
+			Survived:      true,
+			Months:        len(returns.Data),
+			MinWithdrawal: annual,
+		}
+	)
+
+	for i, d := range returns.Data {
+		// deflator converts nominal amounts to today's money.
+		deflator := math.Pow(inflation, float64(i)/12)
+
+		if i%12 == 0 && i > 0 {
+			annual = p.adjust(annual, value, yearGrowth)
+			yearGrowth = 1
+
+			if real := annual / deflator; real < ret.MinWithdrawal {
+				ret.MinWithdrawal = real
+			}
+		}
+
+		w := annual / 12
+		if w > value*(1+1e-9) {
+			ret.Withdrawn += value / deflator
+			ret.Survived = false
+			ret.Months = i
+			value = 0
+			break
+		}
+		ret.Withdrawn += w / deflator
+		value = math.Max(0, value-w) * (1 + d.Value)
+		yearGrowth *= 1 + d.Value
+	}
+
+	ret.Terminal = value / math.Pow(inflation, float64(len(returns.Data))/12)
+	ret.Success = ret.Survived &&
+		(p.Floor == 0 || ret.MinWithdrawal >= p.Floor/100*initialRate*p.Wealth*(1-1e-9))
+	return ret
+}
+
+// adjust returns the annual withdrawal for the next year.
+func (p Plan) adjust(annual, value, yearGrowth float64) float64 {
+	inflation := 1 + p.Inflation/100
+	initialRate := p.Rate / 100
+
+	switch p.Rule {
+	case ConstantPercentage:
+		return initialRate * value
+	case Guardrails:
+		if value <= 0 {
+			return annual
+		}
+		if yearGrowth >= 1 || annual/value <= initialRate {
+			annual *= inflation
+		}
+		switch rate := annual / value; {
+		case rate > initialRate*(1+guardrail):
+			annual *= 1 - adjustment
+		case rate < initialRate*(1-guardrail):
+			annual *= 1 + adjustment
+		}
+		return annual
+	default:
+		return annual * inflation
+	}
+}
+
+// SuccessRate returns the share of paths, in percent, on which the plan
+// succeeds.
+func (p Plan) SuccessRate(paths []timeseries.Data) float64 {
+	if len(paths) == 0 {
+		return math.NaN()
+	}
+
+	var n int
+	for _, path := range paths {
+		if p.Simulate(path).Success {
+			n++
+		}
+	}
+	return 100 * float64(n) / float64(len(paths))
+}
+
+// MaxRate returns the highest initial withdrawal rate, in percent, for which
+// the plan succeeds on at least target percent of the paths. The rate is
+// searched between zero and 100% with a precision of 0.01 percentage points.
+// Evaluating all rates on the same paths keeps the success rate monotonic.
+// With ConstantPercentage and no Floor every rate succeeds and MaxRate returns
+// the upper bound of the search.
+func (p Plan) MaxRate(paths []timeseries.Data, target float64) float64 {
+	lo, hi := 0.0, 100.0
+
+	p.Rate = hi
+	if p.SuccessRate(paths) >= target {
+		return hi
+	}
+
+	for hi-lo > 0.01 {
+		p.Rate = (lo + hi) / 2
+		if p.SuccessRate(paths) >= target {
+			lo = p.Rate
+		} else {
+			hi = p.Rate
+		}
+	}
+
+	return lo
+}
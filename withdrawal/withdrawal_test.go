@@ -0,0 +1,142 @@
+package withdrawal
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/octo/portfolio-mcmc/timeseries"
+)
+
+// constant returns months returns of r, starting in January 2000.
+func constant(r float64, months int) timeseries.Data {
+	ret := timeseries.Data{Name: "test"}
+	for i := 0; i < months; i++ {
+		ret.Data = append(ret.Data, timeseries.Datum{
+			Date:  time.Date(2000, time.Month(1+i), 1, 0, 0, 0, 0, time.UTC),
+			Value: r,
+		})
+	}
+	return ret
+}
+
+func TestSimulate(t *testing.T) {
+	cases := []struct {
+		name    string
+		plan    Plan
+		returns timeseries.Data
+		want    Result
+	}{
+		{
+			name:    "constant real without returns",
+			plan:    Plan{Wealth: 1000, Rate: 10, Rule: ConstantReal},
+			returns: constant(0, 60),
+			want: Result{
+				Survived:      true,
+				Success:       true,
+				Months:        60,
+				Terminal:      500,
+				MinWithdrawal: 100,
+				Withdrawn:     500,
+			},
+		},
+		{
+			name:    "depleted",
+			plan:    Plan{Wealth: 1000, Rate: 12, Rule: ConstantReal},
+			returns: constant(0, 180),
+			want: Result{
+				Months:        100,
+				MinWithdrawal: 120,
+				Withdrawn:     1000,
+			},
+		},
+		{
+			name:    "constant percentage never depletes",
+			plan:    Plan{Wealth: 1200, Rate: 12, Rule: ConstantPercentage},
+			returns: constant(0, 24),
+			want: Result{
+				Survived:      true,
+				Success:       true,
+				Months:        24,
+				Terminal:      1056 * .88,
+				MinWithdrawal: 1056 * .12,
+				Withdrawn:     144 + 1056*.12,
+			},
+		},
+		{
+			name:    "floor",
+			plan:    Plan{Wealth: 1200, Rate: 12, Rule: ConstantPercentage, Floor: 95},
+			returns: constant(0, 24),
+			want: Result{
+				Survived:      true,
+				Months:        24,
+				Terminal:      1056 * .88,
+				MinWithdrawal: 1056 * .12,
+				Withdrawn:     144 + 1056*.12,
+			},
+		},
+		{
+			// The rate rises to 200/800 = 25% > 1.2 * 20%, so the
+			// withdrawal is cut by 10%.
+			name:    "guardrails cut",
+			plan:    Plan{Wealth: 1000, Rate: 20, Rule: Guardrails},
+			returns: constant(0, 24),
+			want: Result{
+				Survived:      true,
+				Success:       true,
+				Months:        24,
+				Terminal:      620,
+				MinWithdrawal: 180,
+				Withdrawn:     380,
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := tc.plan.Simulate(tc.returns)
+			if diff := cmp.Diff(tc.want, got, cmpopts.EquateApprox(0, 1e-9)); diff != "" {
+				t.Errorf("Simulate() differs (-want/+got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSimulateInflation(t *testing.T) {
+	p := Plan{Wealth: 1000, Rate: 4, Rule: ConstantReal, Inflation: 2}
+
+	// With returns equal to inflation, the real value only declines by the
+	// withdrawals.
+	monthly := math.Pow(1.02, 1.0/12) - 1
+	got := p.Simulate(constant(monthly, 120))
+	if !got.Success || math.Abs(got.MinWithdrawal-40) > 1e-9 {
+		t.Errorf("Simulate() = %+v, want success with MinWithdrawal 40", got)
+	}
+	if sum := got.Terminal + got.Withdrawn; math.Abs(sum-1000) > 1e-9 {
+		t.Errorf("Terminal + Withdrawn = %g, want 1000", sum)
+	}
+}
+
+func TestMaxRate(t *testing.T) {
+	paths := []timeseries.Data{
+		constant(0, 240),
+		constant(0, 120),
+	}
+	p := Plan{Wealth: 1000, Rule: ConstantReal}
+
+	// Withdrawing 5% p.a. for 20 years or 10% p.a. for 10 years depletes
+	// the portfolio exactly.
+	if got, want := p.MaxRate(paths, 100), 5.0; math.Abs(got-want) > 0.01 {
+		t.Errorf("MaxRate(100) = %g, want %g", got, want)
+	}
+	if got, want := p.MaxRate(paths, 50), 10.0; math.Abs(got-want) > 0.01 {
+		t.Errorf("MaxRate(50) = %g, want %g", got, want)
+	}
+
+	p.Rate = 7
+	if got, want := p.SuccessRate(paths), 50.0; got != want {
+		t.Errorf("SuccessRate() = %g, want %g", got, want)
+	}
+}